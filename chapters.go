@@ -0,0 +1,63 @@
+package youtube
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Chapter is a section of a video, YouTube derives them from timestamps in the description
+type Chapter struct {
+	Title string
+	Start time.Duration
+	End   time.Duration
+}
+
+// minChapters is the number of timestamps a description needs for YouTube to show chapters
+const minChapters = 3
+
+// chapterLinePattern matches description lines starting with a timestamp like "1:02:03", "(12:34)" or "- 0:00",
+// followed by the title
+var chapterLinePattern = regexp.MustCompile(`^[\s\-–•*]*[(\[]?(?:(\d+):)?(\d{1,2}):(\d{2})[)\]]?\s*[\-–:|]?\s*(.*)$`)
+
+// parseChapters returns the chapters in the description of a video with the duration.
+// Like YouTube it requires at least three timestamps in ascending order, the first one at 0:00,
+// otherwise there are no chapters.
+func parseChapters(description string, duration time.Duration) []Chapter {
+	if duration <= 0 {
+		return nil
+	}
+
+	var chapters []Chapter
+
+	for _, line := range strings.Split(description, "\n") {
+		match := chapterLinePattern.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+
+		hours, _ := strconv.Atoi(match[1])
+		minutes, _ := strconv.Atoi(match[2])
+		seconds, _ := strconv.Atoi(match[3])
+		start := time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second
+
+		switch {
+		case len(chapters) == 0 && start != 0,
+			len(chapters) > 0 && start <= chapters[len(chapters)-1].Start,
+			start >= duration:
+			return nil
+		}
+
+		if len(chapters) > 0 {
+			chapters[len(chapters)-1].End = start
+		}
+		chapters = append(chapters, Chapter{Title: strings.TrimSpace(match[4]), Start: start, End: duration})
+	}
+
+	if len(chapters) < minChapters {
+		return nil
+	}
+
+	return chapters
+}
@@ -0,0 +1,42 @@
+package youtube
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseChapters(t *testing.T) {
+	description := `My trip to the mountains
+
+0:00 Intro
+(1:05) - The ascent
+• 12:30 | At the top
+1:02:03 Way back
+
+Music: 3:20 is my favourite part`
+
+	assert.Equal(t, []Chapter{
+		{Title: "Intro", Start: 0, End: time.Minute + 5*time.Second},
+		{Title: "The ascent", Start: time.Minute + 5*time.Second, End: 12*time.Minute + 30*time.Second},
+		{Title: "At the top", Start: 12*time.Minute + 30*time.Second, End: time.Hour + 2*time.Minute + 3*time.Second},
+		{Title: "Way back", Start: time.Hour + 2*time.Minute + 3*time.Second, End: 2 * time.Hour},
+	}, parseChapters(description, 2*time.Hour))
+}
+
+func TestParseChapters_none(t *testing.T) {
+	tests := map[string]string{
+		"no timestamps":      "just a video",
+		"too few":            "0:00 Intro\n1:00 End",
+		"not from the start": "0:10 Intro\n1:00 Middle\n2:00 End",
+		"not ascending":      "0:00 Intro\n2:00 Middle\n1:00 End",
+		"beyond the end":     "0:00 Intro\n1:00 Middle\n20:00 End",
+	}
+
+	for name, description := range tests {
+		assert.Nil(t, parseChapters(description, 10*time.Minute), name)
+	}
+
+	assert.Nil(t, parseChapters("0:00 Intro\n1:00 Middle\n2:00 End", 0), "unknown duration")
+}
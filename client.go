@@ -43,6 +43,10 @@ type Client struct {
 	// ChunkSize to use when downloading videos in chunks. Default is Size10Mb.
 	ChunkSize int64
 
+	// UserAgent overrides the User-Agent header of all requests, including the ones of the streams, e.g. BrowserUserAgent.
+	// Default is the one of the YouTube client in use. Changing it can help when requests are blocked with 403 Forbidden.
+	UserAgent string
+
 	// playerCache caches the JavaScript code of a player response
 	playerCache playerCache
 
@@ -55,6 +59,16 @@ func (c *Client) assureClient() {
 	if c.client == nil {
 		c.client = &DefaultClient
 	}
+
+	if len(c.consentID) == 0 {
+		c.consentID = strconv.Itoa(rand.Intn(899) + 100) //nolint:gosec
+	}
+}
+
+// ClearPlayerCache drops the cached player, so it is fetched again for deciphering the next stream URL.
+// This helps when YouTube rotated the player and the cached one produces forbidden URLs.
+func (c *Client) ClearPlayerCache() {
+	c.playerCache = playerCache{}
 }
 
 // GetVideo fetches video metadata
@@ -119,7 +133,7 @@ func (c *Client) videoFromID(ctx context.Context, id string) (*Video, error) {
 		}
 
 		// wrapping error so its clear whats happened
-		return &v, fmt.Errorf("can't bypass age restriction: %w", errEmbed)
+		return &v, ageRestrictionError{errEmbed}
 	}
 
 	// undefined error
@@ -170,6 +184,9 @@ type clientInfo struct {
 	androidVersion int
 }
 
+// BrowserUserAgent is the User-Agent of a recent desktop browser, for Client.UserAgent
+const BrowserUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36"
+
 var (
 	// WebClient, better to use Android client but go ahead.
 	WebClient = clientInfo{
@@ -317,6 +334,16 @@ func (c *Client) GetStream(video *Video, format *Format) (io.ReadCloser, int64,
 
 // GetStreamContext returns the stream and the total size for a specific format with a context.
 func (c *Client) GetStreamContext(ctx context.Context, video *Video, format *Format) (io.ReadCloser, int64, error) {
+	return c.GetStreamFromContext(ctx, video, format, 0)
+}
+
+// GetStreamFromContext returns the stream starting at offset and its remaining size, for resuming a download.
+// Only formats with a ContentLength can be started at an offset.
+func (c *Client) GetStreamFromContext(ctx context.Context, video *Video, format *Format, offset int64) (io.ReadCloser, int64, error) {
+	if offset > 0 && format.ContentLength == 0 {
+		return nil, 0, fmt.Errorf("can't start stream of unknown size at offset %d", offset)
+	}
+
 	url, err := c.GetStreamURL(video, format)
 	if err != nil {
 		return nil, 0, err
@@ -328,19 +355,54 @@ func (c *Client) GetStreamContext(ctx context.Context, video *Video, format *For
 	}
 
 	r, w := io.Pipe()
-	contentLength := format.ContentLength
+	contentLength := format.ContentLength - offset
 
 	if contentLength == 0 {
 		// some videos don't have length information
 		contentLength = c.downloadOnce(req, w, format)
 	} else {
 		// we have length information, let's download by chunks!
-		c.downloadChunked(ctx, req, w, format)
+		c.downloadChunked(ctx, req, w, format, offset)
 	}
 
 	return r, contentLength, nil
 }
 
+// GetStreamRangeContext returns the bytes from start to end, inclusive, of the stream for a specific format in a single request.
+// It fails with ErrRangeNotSupported if the server doesn't respond with the requested range.
+func (c *Client) GetStreamRangeContext(ctx context.Context, video *Video, format *Format, start, end int64) (io.ReadCloser, error) {
+	url, err := c.GetStreamURLContext(ctx, video, format)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	q := req.URL.Query()
+	q.Set("range", fmt.Sprintf("%d-%d", start, end))
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := c.httpDo(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, ErrUnexpectedStatusCode(resp.StatusCode)
+	}
+
+	if resp.ContentLength >= 0 && resp.ContentLength != end-start+1 {
+		resp.Body.Close()
+		return nil, ErrRangeNotSupported
+	}
+
+	return resp.Body, nil
+}
+
 func (c *Client) downloadOnce(req *http.Request, w *io.PipeWriter, _ *Format) int64 {
 	resp, err := c.httpDo(req)
 	if err != nil {
@@ -348,6 +410,12 @@ func (c *Client) downloadOnce(req *http.Request, w *io.PipeWriter, _ *Format) in
 		return 0
 	}
 
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		w.CloseWithError(ErrUnexpectedStatusCode(resp.StatusCode)) //nolint:errcheck
+		return 0
+	}
+
 	go func() {
 		defer resp.Body.Close()
 		_, err := io.Copy(w, resp.Body)
@@ -386,8 +454,8 @@ func (c *Client) getMaxRoutines(limit int) int {
 	return routines
 }
 
-func (c *Client) downloadChunked(ctx context.Context, req *http.Request, w *io.PipeWriter, format *Format) {
-	chunks := getChunks(format.ContentLength, c.getChunkSize())
+func (c *Client) downloadChunked(ctx context.Context, req *http.Request, w *io.PipeWriter, format *Format, offset int64) {
+	chunks := getChunks(offset, format.ContentLength, c.getChunkSize())
 	maxRoutines := c.getMaxRoutines(len(chunks))
 
 	cancelCtx, cancel := context.WithCancel(ctx)
@@ -482,14 +550,15 @@ func (c *Client) httpDo(req *http.Request) (*http.Response, error) {
 		client = http.DefaultClient
 	}
 
-	req.Header.Set("User-Agent", c.client.userAgent)
+	userAgent := c.client.userAgent
+	if c.UserAgent != "" {
+		userAgent = c.UserAgent
+	}
+
+	req.Header.Set("User-Agent", userAgent)
 	req.Header.Set("Origin", "https://youtube.com")
 	req.Header.Set("Sec-Fetch-Mode", "navigate")
 
-	if len(c.consentID) == 0 {
-		c.consentID = strconv.Itoa(rand.Intn(899) + 100) //nolint:gosec
-	}
-
 	req.AddCookie(&http.Cookie{
 		Name:   "CONSENT",
 		Value:  "YES+cb.20210328-17-p0.en+FX+" + c.consentID,
@@ -596,7 +665,7 @@ func (c *Client) downloadChunk(req *http.Request, chunk *chunk) error {
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode < http.StatusOK && resp.StatusCode >= 300 {
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= 300 {
 		return ErrUnexpectedStatusCode(resp.StatusCode)
 	}
 
@@ -609,7 +678,7 @@ func (c *Client) downloadChunk(req *http.Request, chunk *chunk) error {
 	}
 
 	if n != expected {
-		return fmt.Errorf("chunk at offset %d has invalid size: expected=%d actual=%d", chunk.start, expected, n)
+		return fmt.Errorf("%w: offset=%d expected=%d actual=%d", ErrInvalidChunkSize, chunk.start, expected, n)
 	}
 
 	chunk.data <- data
@@ -1,7 +1,10 @@
 package youtube
 
 import (
+	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -249,3 +252,87 @@ func TestClient_httpGetBodyBytes(t *testing.T) {
 		})
 	}
 }
+
+func TestGetStream_unexpectedStatusCode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	video := &Video{ID: "BaW_jenozKc"}
+
+	for _, contentLength := range []int64{0, 100} {
+		client := Client{}
+		stream, _, err := client.GetStream(video, &Format{URL: server.URL, ContentLength: contentLength})
+		require.NoError(t, err)
+
+		_, err = io.ReadAll(stream)
+		assert.Equal(t, ErrUnexpectedStatusCode(http.StatusForbidden), err, "content length %d", contentLength)
+	}
+}
+
+func TestGetStream_userAgent(t *testing.T) {
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.UserAgent()
+		w.Write([]byte("stream"))
+	}))
+	defer server.Close()
+
+	video := &Video{ID: "BaW_jenozKc"}
+
+	client := Client{}
+	stream, _, err := client.GetStream(video, &Format{URL: server.URL, ContentLength: 6})
+	require.NoError(t, err)
+	_, err = io.ReadAll(stream)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultClient.userAgent, userAgent, "default")
+
+	client.UserAgent = BrowserUserAgent
+	stream, _, err = client.GetStream(video, &Format{URL: server.URL, ContentLength: 6})
+	require.NoError(t, err)
+	_, err = io.ReadAll(stream)
+	require.NoError(t, err)
+	assert.Equal(t, BrowserUserAgent, userAgent)
+}
+
+func TestClient_ClearPlayerCache(t *testing.T) {
+	client := Client{}
+	client.playerCache.Set("player", playerConfig("config"))
+	require.NotNil(t, client.playerCache.Get("player"))
+
+	client.ClearPlayerCache()
+	assert.Nil(t, client.playerCache.Get("player"))
+}
+
+func TestGetStreamRange(t *testing.T) {
+	const content = "0123456789"
+	ignoreRange := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ignoreRange {
+			w.Write([]byte(content)) //nolint:errcheck
+			return
+		}
+
+		var start, end int
+		_, err := fmt.Sscanf(r.URL.Query().Get("range"), "%d-%d", &start, &end)
+		require.NoError(t, err)
+		w.Write([]byte(content[start : end+1])) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	video := &Video{ID: "BaW_jenozKc"}
+	format := &Format{URL: server.URL, ContentLength: int64(len(content))}
+	client := Client{}
+
+	stream, err := client.GetStreamRangeContext(context.Background(), video, format, 3, 6)
+	require.NoError(t, err)
+	data, err := io.ReadAll(stream)
+	stream.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "3456", string(data))
+
+	ignoreRange = true
+	_, err = client.GetStreamRangeContext(context.Background(), video, format, 3, 6)
+	assert.Equal(t, ErrRangeNotSupported, err)
+}
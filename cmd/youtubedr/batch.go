@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+
+	ytdl "github.com/kkdai/youtube/v2/downloader"
+)
+
+// maximum number of simultaneous downloads
+var concurrency int
+
+const (
+	statusOK      = "ok"
+	statusSkipped = "skipped"
+	statusFailed  = "failed"
+)
+
+// DownloadStats describes the outcome of a single download of a batch
+type DownloadStats struct {
+	ID      string
+	Status  string
+	Error   string `json:",omitempty" xml:",omitempty"`
+	Path    string `json:",omitempty" xml:",omitempty"`
+	Itag    int    `json:",omitempty" xml:",omitempty"`
+	Size    int64
+	Elapsed string
+	Speed   int64 // average bytes per second
+}
+
+// PlaylistResult summarizes the downloads of a playlist or a batch of videos
+type PlaylistResult struct {
+	Downloads  []DownloadStats
+	Succeeded  int
+	Failed     int
+	TotalBytes int64
+	TotalTime  string
+}
+
+// runConcurrently calls fn for the indexes 0 to n-1 with a pool of `concurrency` workers.
+// All calls are made, the failures are returned together once every call has finished.
+// fn is expected to return early once ctx is done.
+func runConcurrently(ctx context.Context, n int, fn func(ctx context.Context, i int) error) error {
+	limit := concurrency
+	if limit < 1 {
+		limit = 1
+	}
+
+	indexes := make(chan int)
+	errs := make([]error, n)
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(limit, n); w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+				errs[i] = fn(ctx, i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// downloadBatch downloads all videos and returns the summary of the run,
+// downloadFunc gets the index of the video among the ids
+func downloadBatch(ctx context.Context, ids []string, downloadFunc func(ctx context.Context, i int, id string) (*ytdl.DownloadResult, error)) (*PlaylistResult, error) {
+	start := time.Now()
+	result := PlaylistResult{
+		Downloads: make([]DownloadStats, len(ids)),
+	}
+
+	// the overall progress above the bars of the running downloads
+	batch := getDownloader().StartBatch(len(ids))
+
+	err := runConcurrently(ctx, len(ids), func(ctx context.Context, i int) error {
+		defer batch.FileDone()
+
+		stats := &result.Downloads[i]
+		stats.ID = ids[i]
+
+		// don't start downloads after an interrupt
+		err := ctx.Err()
+		var res *ytdl.DownloadResult
+		if err == nil {
+			res, err = downloadFunc(ctx, i, ids[i])
+		}
+		if err != nil {
+			stats.Status = statusFailed
+			stats.Error = err.Error()
+			return fmt.Errorf("%s: %w", ids[i], err)
+		}
+
+		stats.Status = statusOK
+		if res.Skipped {
+			stats.Status = statusSkipped
+		}
+		stats.Path = res.Path
+		stats.Itag = res.Itag
+		stats.Size = res.Bytes
+		stats.Elapsed = res.Elapsed.Round(time.Millisecond).String()
+		stats.Speed = int64(res.BytesPerSecond())
+		return nil
+	})
+	batch.Finish()
+
+	for _, stats := range result.Downloads {
+		if stats.Status != statusFailed {
+			result.Succeeded++
+		} else {
+			result.Failed++
+		}
+		result.TotalBytes += stats.Size
+	}
+	result.TotalTime = time.Since(start).Round(time.Millisecond).String()
+
+	return &result, err
+}
+
+// writeSummary prints the summary of a batch to stdout, progress bars are rendered to stderr
+func writeSummary(result *PlaylistResult) error {
+	return writeOutput(os.Stdout, result, func(w io.Writer) {
+		writeSummaryOutput(w, result)
+	})
+}
+
+func writeSummaryOutput(w io.Writer, result *PlaylistResult) {
+	table := tablewriter.NewWriter(w)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"ID", "Status", "size [MB]", "Time", "Path"})
+
+	for _, stats := range result.Downloads {
+		path := stats.Path
+		if stats.Error != "" {
+			path = stats.Error
+		}
+
+		table.Append([]string{
+			stats.ID,
+			stats.Status,
+			fmt.Sprintf("%0.1f", float64(stats.Size)/1024/1024),
+			stats.Elapsed,
+			path,
+		})
+	}
+
+	table.SetFooter([]string{
+		"Total",
+		fmt.Sprintf("%d ok, %d failed", result.Succeeded, result.Failed),
+		fmt.Sprintf("%0.1f", float64(result.TotalBytes)/1024/1024),
+		result.TotalTime,
+		"",
+	})
+	table.Render()
+}
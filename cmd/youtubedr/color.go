@@ -0,0 +1,23 @@
+package main
+
+import (
+	"os"
+)
+
+var noColor bool
+
+// useColor reports whether colored output is enabled.
+// Colors are disabled by --no-color, the NO_COLOR environment variable (see https://no-color.org)
+// and when stderr is not a terminal, e.g. in CI or journald.
+func useColor() bool {
+	if noColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+
+	info, err := os.Stderr.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
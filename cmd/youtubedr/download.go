@@ -2,63 +2,470 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"os/exec"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+
+	"github.com/kkdai/youtube/v2"
+	ytdl "github.com/kkdai/youtube/v2/downloader"
 )
 
 // downloadCmd represents the download command
 var downloadCmd = &cobra.Command{
 	Use:     "download",
-	Short:   "Downloads a video from youtube",
+	Short:   "Downloads one or more videos from youtube",
 	Example: `youtubedr -o "Campaign Diary".mp4 https://www.youtube.com/watch\?v\=XbNghLqsVwU`,
-	Args:    cobra.ExactArgs(1),
+	Args:    cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		exitOnError(download(args[0]))
+		if len(args) > 1 && outputFile != "" {
+			exitOnError(errors.New("--filename can't be used when downloading multiple videos"))
+		}
+		if outputFile == ytdl.Stdout && (subtitlesLang != "" || subtitlesTranslate != "" || thumbnail ||
+			embedMetadata || embedDescription || embedSourceURL || embedThumbnail || embedChapters || normalizeAudio || len(skipSegments) > 0 || remux != "" || verifyMedia || setMtime || writeInfoJSON || writeDescription || writeThumbnail) {
+			exitOnError(errors.New("--filename - writes the video to stdout, it can't be combined with subtitles, thumbnails, embedding, --remux, --verify-media, --set-mtime or the --write flags"))
+		}
+		if remux != "" && remux != ytdl.ContainerMP4 && remux != ytdl.ContainerWebM && remux != ytdl.ContainerMKV {
+			exitOnError(fmt.Errorf("unsupported --remux container %s, use mp4, webm or mkv", remux))
+		}
+		if len(skipSegments) > 0 {
+			switch {
+			case len(args) > 1:
+				exitOnError(errors.New("--skip-segments can't be used when downloading multiple videos"))
+			case clipFrom > 0 || clipTo > 0:
+				exitOnError(errors.New("--skip-segments can't be combined with --from and --to"))
+			case embedChapters:
+				exitOnError(errors.New("--skip-segments can't be combined with --embed-chapters, the chapters would no longer match"))
+			}
+		}
+		if interactive && len(args) > 1 {
+			exitOnError(errors.New("--interactive can't be used when downloading multiple videos"))
+		}
+		if interactive && (cmd.Flags().Changed("quality") || mimetype != "" || formatExpression != "" || audioOnly || audioFormat != "" || clipFrom > 0 || clipTo > 0) {
+			exitOnError(errors.New("--interactive picks the format, it can't be combined with --quality, --mimetype, --format, --audio-only, --audio-format, --from or --to"))
+		}
+		if formatExpression != "" && (audioOnly || audioFormat != "" || clipFrom > 0 || clipTo > 0) {
+			exitOnError(errors.New("--format selects the format, it can't be combined with --audio-only, --audio-format, --from or --to"))
+		}
+		if (videoItag > 0) != (audioItag > 0) {
+			exitOnError(errors.New("--video-itag and --audio-itag select the formats to merge, set both of them"))
+		}
+		if videoItag > 0 && (formatExpression != "" || interactive || audioOnly || audioFormat != "" || clipFrom > 0 || clipTo > 0) {
+			exitOnError(errors.New("--video-itag and --audio-itag select the formats, they can't be combined with --format, --interactive, --audio-only, --audio-format, --from or --to"))
+		}
+		exitOnError(parseFormatSelector())
+		if subsOnly && subtitlesLang == "" && subtitlesTranslate == "" {
+			exitOnError(errors.New("--subs-only requires --subs or --subtitles-translate"))
+		}
+		if subsFormat != ytdl.CaptionsVTT && subsFormat != ytdl.CaptionsSRT {
+			exitOnError(fmt.Errorf("unsupported subtitles format: %s", subsFormat))
+		}
+
+		// make sure all downloads share the same downloader
+		getDownloader()
+
+		if len(args) == 1 {
+			_, err := download(cmd.Context(), args[0])
+			exitOnError(err)
+			return
+		}
+
+		outputFormat = outputFormatPlain
+		if summaryJSON {
+			outputFormat = outputFormatJSON
+		}
+
+		result, err := downloadBatch(cmd.Context(), args, func(ctx context.Context, _ int, id string) (*ytdl.DownloadResult, error) {
+			return download(ctx, id)
+		})
+		exitOnError(writeSummary(result))
+		exitOnError(err)
 	},
 }
 
 var (
-	ffmpegCheck error
-	outputFile  string
-	outputDir   string
+	outputFile         string
+	outputDir          string
+	subtitlesLang      string
+	subtitlesTranslate string
+	subsOnly           bool
+	subsFormat         string
+	summaryJSON        bool
+	testMode           bool
+	embedMetadata      bool
+	embedDescription   bool
+	embedSourceURL     bool
+	embedThumbnail     bool
+	embedChapters      bool
+	normalizeAudio     bool
+	targetLUFS         float64
+	writeInfoJSON      bool
+	writeDescription   bool
+	writeThumbnail     bool
+	audioOnly          bool
+	audioFormat        string
+	resolutionSuffix   bool
+	audioLang          string
+	strictAudioLang    bool
+	mergeRetry         bool
+	keepStreams        bool
+	minFilesize        byteSize
+	maxFilesize        byteSize
+	alternateHosts     bool
+	preferFPS          int
+	minFPS             int
+	strictFPS          bool
+	printFFmpegCmd     bool
+	thumbnail          bool
+	thumbnailFormat    string
+	refreshPlayer      bool
+	refreshURLs        bool
+	tempDir            string
+	retries            int
+	concurrentChunks   int
+	useMmap            bool
+	chunkState         bool
+	limitRate          byteSize
+	clipFrom           timestamp
+	clipTo             timestamp
+	skipSegments       segments
+	execCommand        string
+	interactive        bool
+	quiet              bool
+	outputTemplate     string
+	noOverwrite        bool
+	uniqueNames        bool
+	noSpaceCheck       bool
+	downloadTimeout    time.Duration
+	container          string
+	ffmpegBinary       string
+	ffprobeBinary      string
+	ffmpegArgs         []string
 )
 
+// remux is the container downloads are remuxed into, see ytdl.Remux
+var remux string
+
+// verifyMedia probes the finished files with ffprobe, see ytdl.Downloader.VerifyMedia
+var verifyMedia bool
+
+// the itags of the formats to merge, see ytdl.Downloader.DownloadCompositeByItags
+var (
+	videoItag int
+	audioItag int
+)
+
+// setMtime sets the modification time of the files to the publish date, see ytdl.SetMtime
+var setMtime bool
+
+// sleepRequests is the least time between the video fetches, see ytdl.Downloader.RequestInterval
+var sleepRequests time.Duration
+
+// audioFormatBest downloads the best audio-only stream as it is
+const audioFormatBest = "best"
+
+// audioFormats returns the video with the audio formats of the mime type
+func audioFormats(video *youtube.Video) *youtube.Video {
+	if mimetype == "" {
+		return video
+	}
+
+	filtered := *video
+	filtered.Formats = video.Formats.Type(mimetype)
+
+	return &filtered
+}
+
 func init() {
 	rootCmd.AddCommand(downloadCmd)
 
-	downloadCmd.Flags().StringVarP(&outputFile, "filename", "o", "", "The output file, the default is genated by the video title.")
+	downloadCmd.Flags().StringVarP(&outputFile, "filename", "o", "", "The output file, the default is genated by the video title.\nUse - to write the video to stdout, e.g. for piping it into a player")
 	downloadCmd.Flags().StringVarP(&outputDir, "directory", "d", ".", "The output directory.")
+	downloadCmd.Flags().StringVar(&outputTemplate, "output-template", "", outputTemplateUsage())
+	downloadCmd.Flags().StringVar(&tempDir, "temp-dir", "", "Directory for incomplete downloads and intermediate files, the default is the output directory")
+	downloadCmd.Flags().StringVar(&subtitlesLang, "subs", "", "Also download the subtitles of the given language (see 'subtitles list')")
+	downloadCmd.Flags().StringVar(&subtitlesLang, "subtitles", "", "Also download the subtitles of the given language")
+	downloadCmd.Flags().MarkDeprecated("subtitles", "use --subs instead") //nolint:errcheck
+	downloadCmd.Flags().StringVar(&subtitlesTranslate, "subtitles-translate", "", "Also download subtitles auto-translated to the given language")
+	downloadCmd.Flags().BoolVar(&subsOnly, "subs-only", false, "Only download the subtitles, not the video")
+	downloadCmd.Flags().StringVar(&subsFormat, "subs-format", ytdl.CaptionsVTT, "The file format of the subtitles (vtt, srt)")
+	downloadCmd.Flags().BoolVar(&noOverwrite, "no-overwrite", false, "Skip videos whose output file already exists, unless its size doesn't match")
+	downloadCmd.Flags().BoolVar(&uniqueNames, "unique-names", false, "Add \" (1)\", \" (2)\" and so on to the names of output files that already exist instead of overwriting them")
+	downloadCmd.Flags().BoolVar(&noSpaceCheck, "no-space-check", false, "Don't check for enough free disk space before downloading, for file systems reporting it unreliably")
+	downloadCmd.Flags().BoolVar(&summaryJSON, "json", false, "Print the summary of multiple downloads as JSON")
+	downloadCmd.Flags().BoolVar(&testMode, "test", false, "Only download the first seconds of the video, for testing the selected format")
+	downloadCmd.Flags().BoolVar(&audioOnly, "audio-only", false, "Only download the best audio stream")
+	downloadCmd.Flags().StringVar(&audioFormat, "audio-format", "", "Only download audio, converted to mp3, opus, aac or m4a with ffmpeg: \"best\" keeps the best audio stream untouched")
+	downloadCmd.Flags().BoolVar(&embedMetadata, "embed-metadata", false, "Write title, author and publish date into the file metadata (requires ffmpeg)")
+	downloadCmd.Flags().BoolVar(&embedDescription, "embed-description", false, "Also write the video description into the file metadata, implies --embed-metadata")
+	downloadCmd.Flags().BoolVar(&embedSourceURL, "embed-source-url", false, "Also write the video URL into the file metadata, implies --embed-metadata")
+	downloadCmd.Flags().BoolVar(&embedThumbnail, "embed-thumbnail", false, "Embed the largest thumbnail of the video as cover art (requires ffmpeg)")
+	downloadCmd.Flags().BoolVar(&embedChapters, "embed-chapters", false, "Embed the chapters from the timestamps in the video description (requires ffmpeg)")
+	downloadCmd.Flags().BoolVar(&normalizeAudio, "normalize-audio", false, "Normalize the loudness of the audio with the loudnorm filter, re-encoding it (requires ffmpeg)")
+	downloadCmd.Flags().Float64Var(&targetLUFS, "target-lufs", ytdl.DefaultTargetLUFS, "The integrated loudness of --normalize-audio, from -70 to -5")
+	downloadCmd.Flags().BoolVar(&writeInfoJSON, "write-info-json", false, "Write the metadata and available formats of the video next to the file, e.g. \"Title.info.json\" for \"Title.mp4\"")
+	downloadCmd.Flags().BoolVar(&writeDescription, "write-description", false, "Write the description of the video next to the file, e.g. \"Title.description\" for \"Title.mp4\"")
+	downloadCmd.Flags().BoolVar(&writeThumbnail, "write-thumbnail", false, "Write the largest thumbnail of the video next to the file as it is, e.g. \"Title.webp\" for \"Title.mp4\", unlike --thumbnail it is never converted")
+	downloadCmd.Flags().BoolVar(&setMtime, "set-mtime", false, "Set the modification time of the file to the publish date of the video")
+	downloadCmd.Flags().BoolVar(&resolutionSuffix, "resolution-suffix", false, "Append the resolution to the generated file name, e.g. \"Title [1080p].mp4\"")
+	downloadCmd.Flags().StringVar(&audioLang, "audio-lang", "", "The language of the audio track for videos with multiple tracks, e.g. \"es\"")
+	downloadCmd.Flags().BoolVar(&strictAudioLang, "strict-audio-lang", false, "Fail if the --audio-lang track is not available instead of using the default track")
+	downloadCmd.Flags().StringVar(&container, "container", "", "The container hd videos are merged into (mp4, webm, mkv), the default is the one of the video, or mkv for incompatible audio")
+	downloadCmd.Flags().StringVar(&remux, "remux", "", "Copy the streams of the downloaded file into this container (mp4, webm, mkv) and rename it, failing if the container can't hold the codecs (requires ffmpeg and ffprobe).\n"+
+		"hd videos are merged into it directly unless --container is set")
+	downloadCmd.Flags().IntVar(&videoItag, "video-itag", 0, "Merge the video only format of this itag with the one of --audio-itag, see youtubedr formats (requires ffmpeg)")
+	downloadCmd.Flags().IntVar(&audioItag, "audio-itag", 0, "Merge the audio only format of this itag with the one of --video-itag")
+	downloadCmd.Flags().BoolVar(&mergeRetry, "merge-retry", false, "Retry a failed merge of video and audio with re-encoding")
+	downloadCmd.Flags().BoolVar(&keepStreams, "keep-streams", false, "Keep the downloaded video and audio of hd qualities next to the merged file, e.g. \"Title.f137.mp4\" (always kept if the merge fails), and files failing --verify-media")
+	downloadCmd.Flags().BoolVar(&verifyMedia, "verify-media", false, "Check the finished file with ffprobe has a video or audio stream and about the length of the video, removing it otherwise (requires ffprobe)")
+	downloadCmd.Flags().Var(&minFilesize, "min-filesize", "Only select formats with an estimated size of at least this, e.g. 50M")
+	downloadCmd.Flags().Var(&maxFilesize, "max-filesize", "Only select formats with an estimated size of at most this, e.g. 1.5G")
+	downloadCmd.Flags().BoolVar(&alternateHosts, "try-alternate-hosts", false, "Retry failed downloads from alternate CDN hosts (best-effort)")
+	downloadCmd.Flags().IntVar(&preferFPS, "prefer-fps", 0, "Prefer formats with this frame rate, e.g. 60, falling back to others")
+	downloadCmd.Flags().IntVar(&minFPS, "fps", 0, "Only select hd formats with a frame rate of at least this, e.g. 60, falling back to lower frame rates with a warning")
+	downloadCmd.Flags().BoolVar(&strictFPS, "strict-fps", false, "Fail if no format has the --fps frame rate instead of falling back")
+	downloadCmd.Flags().StringVar(&ffmpegBinary, "ffmpeg-path", "", "The ffmpeg executable, the default is ffmpeg from PATH")
+	downloadCmd.Flags().StringVar(&ffprobeBinary, "ffprobe-path", "", "The ffprobe executable, the default is the ffprobe next to --ffmpeg-path, or ffprobe from PATH")
+	downloadCmd.Flags().StringArrayVar(&ffmpegArgs, "ffmpeg-arg", nil, "Add an argument to the ffmpeg merge of video and audio before the output file, e.g. --ffmpeg-arg=-movflags --ffmpeg-arg=+faststart.\nIt adds to or overrides the \"-c copy -shortest\" of the merge, inputs can't be added")
+	downloadCmd.Flags().BoolVar(&printFFmpegCmd, "print-ffmpeg-cmd", false, "Print the ffmpeg commands instead of running them, keeping their input files")
+	downloadCmd.Flags().BoolVar(&thumbnail, "thumbnail", false, "Also download the largest thumbnail of the video")
+	downloadCmd.Flags().StringVar(&thumbnailFormat, "thumbnail-format", ytdl.ThumbnailJPG, "The image format of the thumbnail (jpg, webp), others are converted with ffmpeg")
+	downloadCmd.Flags().BoolVar(&refreshPlayer, "refresh-player-on-403", false, "Retry forbidden downloads once with a freshly fetched player")
+	downloadCmd.Flags().BoolVar(&refreshURLs, "refresh-urls-on-403", false, "Fetch the video again when a stream URL expires during a download and resume it with a fresh URL")
+	downloadCmd.Flags().IntVar(&retries, "retries", 0, "Retry streams failing with network or server errors this many times, resuming where they stopped")
+	downloadCmd.Flags().IntVar(&concurrentChunks, "concurrent-chunks", 1, "Download each stream in this many parts at once, with separate ranged requests")
+	downloadCmd.Flags().BoolVar(&useMmap, "mmap", false, "Write the parts of --concurrent-chunks into a memory mapping of the output file instead of writing them at offsets")
+	downloadCmd.Flags().BoolVar(&chunkState, "chunk-state", false, "Record the finished parts of --concurrent-chunks next to the .part file, so downloading the video again resumes a failed download")
+	downloadCmd.Flags().DurationVar(&downloadTimeout, "timeout", 0, "Abort the download of a video taking longer than this, e.g. 10m, removing its incomplete files")
+	downloadCmd.Flags().DurationVar(&sleepRequests, "sleep-requests", 0, "Wait at least this long between fetching videos, e.g. 2s, so large batches don't get throttled. Streams aren't delayed")
+	downloadCmd.Flags().Var(&limitRate, "limit-rate", "Limit the total download rate of all streams to this many bytes per second, e.g. 2M")
+	downloadCmd.Flags().Var(&clipFrom, "from", "Only download the clip of the video from this position on, e.g. 1:30")
+	downloadCmd.Flags().Var(&clipTo, "to", "Only download the clip of the video up to this position, e.g. 2:00, the default is the end")
+	downloadCmd.Flags().StringVar(&execCommand, "exec", "", execUsage)
+	downloadCmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "List the formats of the video and ask which to download, a video only format is merged with an audio format")
+	downloadCmd.Flags().Var(&skipSegments, "skip-segments", "Cut these ranges, e.g. sponsored ones, out of the video after downloading it, e.g. \"30-45,2:00-2:15\" (requires ffmpeg).\n"+
+		"The remaining parts are joined without re-encoding, each beginning at the keyframe before its start")
+	addProgressFlags(downloadCmd.Flags())
 	addQualityFlag(downloadCmd.Flags())
 	addMimeTypeFlag(downloadCmd.Flags())
+	addFormatSelectorFlag(downloadCmd.Flags())
 }
 
-func download(id string) error {
-	video, format, err := getVideoWithFormat(id)
+// outputTemplateUsage describes --output-template with its tokens
+func outputTemplateUsage() string {
+	tokens := make([]string, 0, len(ytdl.OutputTemplateTokens))
+	for token := range ytdl.OutputTemplateTokens {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+
+	var usage strings.Builder
+	usage.WriteString("Name the files by this template instead of the title, e.g. \"{author}/{title}-{id}.{ext}\".\nSlashes create directories, the tokens are:")
+	for _, token := range tokens {
+		fmt.Fprintf(&usage, "\n  {%s}: %s", token, ytdl.OutputTemplateTokens[token])
+	}
+
+	return usage.String()
+}
+
+func download(ctx context.Context, id string) (*ytdl.DownloadResult, error) {
+	id, err := videoID(id)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// the timeout includes fetching the video and the wait of --sleep-requests before it
+	if downloadTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, downloadTimeout)
+		defer cancel()
+	}
+
+	if subsOnly {
+		return downloadSubtitlesOnly(ctx, id)
+	}
+
+	var (
+		video               *youtube.Video
+		format              *youtube.Format
+		picked, pickedAudio *youtube.Format
+		quality             string // the one of --quality matching the formats
+	)
+	if interactive {
+		if video, err = getVideo(ctx, id); err != nil {
+			return nil, err
+		}
+		if video.IsLive {
+			return nil, errors.New("--interactive doesn't work for live streams, they are recorded from the HLS manifest")
+		}
+		if picked, pickedAudio, err = pickFormats(video, os.Stdin, os.Stderr); err != nil {
+			return nil, err
+		}
+	} else if formatSelector != nil || videoItag > 0 {
+		if video, err = getVideo(ctx, id); err != nil {
+			return nil, err
+		}
+		if !video.IsLive && formatSelector != nil {
+			if picked, pickedAudio, err = getDownloader().SelectFormats(video.Formats, formatSelector); err != nil {
+				return nil, err
+			}
+		}
+	} else if video, format, quality, err = getVideoWithFormat(ctx, id); err != nil {
+		return nil, err
 	}
 
 	log.Println("download to directory", outputDir)
 
-	if strings.HasPrefix(outputQuality, "hd") {
-		if err := checkFFMPEG(); err != nil {
+	if embedMetadata || embedDescription || embedSourceURL || embedThumbnail || embedChapters || normalizeAudio || len(skipSegments) > 0 || remux != "" || verifyMedia {
+		if err := getDownloader().CheckFFmpeg(); err != nil {
+			return nil, err
+		}
+	}
+
+	var result *ytdl.DownloadResult
+	switch {
+	case video.IsLive:
+		if audioOnly || audioFormat != "" || clipFrom > 0 || clipTo > 0 || len(skipSegments) > 0 {
+			return nil, errors.New("live streams are recorded as they are, --audio-only, --audio-format, --from, --to and --skip-segments don't work for them")
+		}
+		if err := getDownloader().CheckFFmpeg(); err != nil {
+			return nil, err
+		}
+		log.Println("recording the live stream, press Ctrl+C to stop")
+		result, err = downloader.DownloadLive(ctx, outputFile, video)
+	case videoItag > 0:
+		if err := getDownloader().CheckFFmpeg(); err != nil {
+			return nil, err
+		}
+		result, err = downloader.DownloadCompositeByItags(ctx, outputFile, video, videoItag, audioItag)
+	case pickedAudio != nil:
+		if err := getDownloader().CheckFFmpeg(); err != nil {
+			return nil, err
+		}
+		result, err = downloader.DownloadCompositeFormats(ctx, outputFile, video, picked, pickedAudio)
+	case picked != nil:
+		result, err = downloader.Download(ctx, video, picked, outputFile)
+	case clipFrom > 0 || clipTo > 0:
+		if audioOnly || audioFormat != "" || isCompositeQuality(quality) {
+			return nil, errors.New("--from and --to only work for formats with video and audio, not with --audio-only, --audio-format, hd qualities, best or worst")
+		}
+		if err := getDownloader().CheckFFmpeg(); err != nil {
+			return nil, err
+		}
+		end := time.Duration(clipTo)
+		if end == 0 {
+			end = video.Duration
+		}
+		result, err = downloader.DownloadClip(ctx, outputFile, video, format, time.Duration(clipFrom), end)
+	case audioOnly || audioFormat != "":
+		if audioFormat != "" && audioFormat != audioFormatBest {
+			if err := getDownloader().CheckFFmpeg(); err != nil {
+				return nil, err
+			}
+		}
+		result, err = downloader.DownloadAudio(ctx, outputFile, audioFormats(video), "")
+	case isCompositeQuality(quality):
+		if err := getDownloader().CheckFFmpeg(); err != nil {
+			return nil, err
+		}
+		result, err = downloader.DownloadComposite(ctx, outputFile, video, outputQuality, mimetype)
+	default:
+		result, err = downloader.Download(ctx, video, format, outputFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	writeFinishedProgress(video.ID, result)
+
+	if err = downloadSubtitles(ctx, video); err != nil {
+		return nil, err
+	}
+
+	return result, downloadThumbnail(ctx, video)
+}
+
+// isCompositeQuality reports whether the quality of --quality matching the formats selects separate video and audio
+// formats to merge: hd qualities, best and worst
+func isCompositeQuality(quality string) bool {
+	return strings.HasPrefix(quality, "hd") || quality == ytdl.QualityBest || quality == ytdl.QualityWorst
+}
+
+// videoID returns the ID of the video a URL like youtu.be/<id> or youtube.com/shorts/<id> points to.
+// For the URL of a video in a playlist it warns that only the video is downloaded.
+func videoID(arg string) (string, error) {
+	id, err := youtube.ExtractVideoID(arg)
+	if err != nil {
+		return "", err
+	}
+
+	if u, err := url.Parse(arg); err == nil && u.Query().Has("list") {
+		youtube.Logger.Warn("downloading only the video of the playlist URL, the playlist command downloads the whole list",
+			"id", id,
+			"playlist", u.Query().Get("list"),
+		)
+	}
+
+	return id, nil
+}
+
+// downloadSubtitlesOnly downloads the subtitles of the video, skipping the streams
+func downloadSubtitlesOnly(ctx context.Context, id string) (*ytdl.DownloadResult, error) {
+	start := time.Now()
+
+	video, err := getVideo(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = downloadSubtitles(ctx, video); err != nil {
+		return nil, err
+	}
+
+	return &ytdl.DownloadResult{Elapsed: time.Since(start)}, nil
+}
+
+func downloadSubtitles(ctx context.Context, video *youtube.Video) error {
+	if subtitlesLang != "" {
+		err := downloader.DownloadCaptions(ctx, video, subtitlesLang, subtitlesFile(subtitlesLang))
+		if err != nil {
 			return err
 		}
-		return downloader.DownloadComposite(context.Background(), outputFile, video, outputQuality, mimetype)
 	}
 
-	return downloader.Download(context.Background(), video, format, outputFile)
+	if subtitlesTranslate != "" {
+		return downloader.DownloadTranslatedCaptions(ctx, video, subtitlesLang, subtitlesTranslate, subtitlesFile(subtitlesTranslate))
+	}
+
+	return nil
 }
 
-func checkFFMPEG() error {
-	fmt.Println("check ffmpeg is installed....")
-	if err := exec.Command("ffmpeg", "-version").Run(); err != nil {
-		ffmpegCheck = fmt.Errorf("please check ffmpegCheck is installed correctly")
+// subtitlesFile returns the name of the subtitles file next to the output file, if it is set
+func subtitlesFile(lang string) string {
+	if outputFile == "" {
+		return ""
+	}
+
+	return strings.TrimSuffix(outputFile, filepath.Ext(outputFile)) + "." + lang + "." + subsFormat
+}
+
+func downloadThumbnail(ctx context.Context, video *youtube.Video) error {
+	if !thumbnail {
+		return nil
+	}
+
+	var thumbnailFile string
+	if outputFile != "" {
+		thumbnailFile = strings.TrimSuffix(outputFile, filepath.Ext(outputFile)) + "." + thumbnailFormat
 	}
 
-	return ffmpegCheck
+	_, err := downloader.DownloadThumbnail(ctx, video, thumbnailFile)
+	return err
 }
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/spf13/pflag"
@@ -18,18 +22,85 @@ import (
 )
 
 var (
-	insecureSkipVerify bool   // skip TLS server validation
-	outputQuality      string // itag number or quality string
-	mimetype           string // mimetype
+	insecureSkipVerify bool     // skip TLS server validation
+	dnsServer          string   // custom DNS server
+	proxyURL           string   // proxy for all requests
+	cookiesFile        string   // Netscape cookies.txt file
+	addHeaders         []string // "Key: Value" headers of all requests
+	userAgent          string   // User-Agent of all requests, "browser" for youtube.BrowserUserAgent
+	printTraffic       bool     // log HTTP requests and responses
+	outputQuality      string   // itag number or quality string
+	mimetype           string   // mimetype
+	preferFreeFormats  bool     // prefer webm over mp4
+	videoCodec         string   // codec of the video stream
+	audioCodec         string   // codec of the audio stream
+	progressJSON       bool     // write the progress as JSON
 	downloader         *ytdl.Downloader
 )
 
+// the videos cached between commands, see Downloader.GetVideoCached
+var (
+	cacheDir string        // directory of cached videos
+	cacheTTL time.Duration // how long cached videos are used
+)
+
+// the --format expression, see ytdl.ParseFormatSelector
+var (
+	formatExpression string
+	formatSelector   *ytdl.FormatSelector // parsed by parseFormatSelector
+)
+
+func addFormatSelectorFlag(flagSet *pflag.FlagSet) {
+	flagSet.StringVarP(&formatExpression, "format", "f", "", "Select the format by an expression like \"bestvideo[height<=1080][vcodec^=avc1]+bestaudio[ext=m4a]\", overriding --quality and --mimetype.\n"+
+		"The selectors are best, worst, bestvideo, worstvideo, bestaudio, worstaudio or an itag, filtered by height, fps, vcodec, acodec and ext.\n"+
+		"\"+\" merges a video and an audio format (requires ffmpeg)")
+}
+
+// parseFormatSelector parses --format, if set, once before the downloads
+func parseFormatSelector() error {
+	if formatExpression == "" {
+		return nil
+	}
+
+	var err error
+	formatSelector, err = ytdl.ParseFormatSelector(formatExpression)
+
+	return err
+}
+
 func addQualityFlag(flagSet *pflag.FlagSet) {
-	flagSet.StringVarP(&outputQuality, "quality", "q", "medium", "The itag number or quality label (hd720, medium)")
+	flagSet.StringVarP(&outputQuality, "quality", "q", "medium", "The itag number or quality label (hd720, medium), or a list of qualities to try in order (hd1080,hd720,medium).\n"+
+		"best merges the video of the highest resolution with the best audio, worst the lowest ones")
+}
+
+func addProgressFlags(flagSet *pflag.FlagSet) {
+	flagSet.BoolVar(&quiet, "quiet", false, "Do not render progress bars, e.g. when logging to a file")
+	flagSet.BoolVar(&progressJSON, "progress-json", false, "Write the progress to stderr as lines of JSON instead of progress bars, for scripts.\n"+
+		"The objects have the fields status (downloading, complete, failed or finished), id, itag, downloaded_bytes,\n"+
+		"total_bytes, percent and speed in bytes per second. The last object of a video has the status finished and its path")
+}
+
+// writeFinishedProgress ends the JSON progress of a video with the path of its output file
+func writeFinishedProgress(id string, result *ytdl.DownloadResult) {
+	if !progressJSON || result == nil {
+		return
+	}
+
+	json.NewEncoder(os.Stderr).Encode(ytdl.ProgressEvent{ //nolint:errcheck
+		Status:     ytdl.ProgressFinished,
+		ID:         id,
+		Downloaded: result.Bytes,
+		Total:      result.Bytes,
+		Percent:    100,
+		Path:       result.Path,
+	})
 }
 
 func addMimeTypeFlag(flagSet *pflag.FlagSet) {
-	flagSet.StringVarP(&mimetype, "mimetype", "m", "mp4", "Mime-Type to filter (mp4, webm, av01, avc1) - applicable if --quality used is quality label")
+	flagSet.StringVarP(&mimetype, "mimetype", "m", "", "Mime-Type to filter (mp4, webm, av01, avc1) - applicable if --quality used is quality label.\nBy default mp4 is preferred over webm, see --prefer-free-formats")
+	flagSet.BoolVar(&preferFreeFormats, "prefer-free-formats", false, "Prefer webm over mp4 formats of the same quality")
+	flagSet.StringVar(&videoCodec, "video-codec", "", "Only select formats with this video codec (h264, vp9, av1), e.g. for devices without AV1 support")
+	flagSet.StringVar(&audioCodec, "audio-codec", "", "Only select formats with this audio codec (aac, opus)")
 }
 
 func getDownloader() *ytdl.Downloader {
@@ -44,6 +115,7 @@ func getDownloader() *ytdl.Downloader {
 			return proxyFunc(r.URL)
 		},
 		IdleConnTimeout:       60 * time.Second,
+		MaxIdleConnsPerHost:   10, // one per chunk download routine
 		TLSHandshakeTimeout:   10 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
 		ForceAttemptHTTP2:     true,
@@ -54,6 +126,7 @@ func getDownloader() *ytdl.Downloader {
 	}
 
 	youtube.SetLogLevel(logLevel)
+	youtube.SetLogColor(useColor())
 
 	if insecureSkipVerify {
 		youtube.Logger.Info("Skip server certificate verification")
@@ -63,48 +136,232 @@ func getDownloader() *ytdl.Downloader {
 	}
 
 	downloader = &ytdl.Downloader{
-		OutputDir: outputDir,
+		OutputDir:           outputDir,
+		OutputTemplate:      outputTemplate,
+		TempDir:             tempDir,
+		ShowFFmpegOutput:    true,
+		TestMode:            testMode,
+		SkipExisting:        noOverwrite,
+		UniqueNames:         uniqueNames,
+		SkipSpaceCheck:      noSpaceCheck,
+		ResolutionSuffix:    resolutionSuffix,
+		PlaylistDir:         playlistDir,
+		AudioLanguage:       audioLang,
+		StrictAudioLang:     strictAudioLang,
+		MergeRetry:          mergeRetry,
+		KeepStreams:         keepStreams,
+		VerifyMedia:         verifyMedia,
+		Container:           container,
+		DNSServer:           dnsServer,
+		ProxyURL:            proxyURL,
+		CookiesFile:         cookiesFile,
+		MinFilesize:         int64(minFilesize),
+		MaxFilesize:         int64(maxFilesize),
+		TryAlternateHosts:   alternateHosts,
+		PreferFPS:           preferFPS,
+		MinFPS:              minFPS,
+		StrictFPS:           strictFPS,
+		PreferFreeFormats:   preferFreeFormats,
+		VideoCodec:          videoCodec,
+		AudioCodec:          audioCodec,
+		PrintFFmpegCommands: printFFmpegCmd,
+		FFmpegPath:          ffmpegBinary,
+		FFprobePath:         ffprobeBinary,
+		FFmpegArgs:          ffmpegArgs,
+		ThumbnailFormat:     thumbnailFormat,
+		CaptionsFormat:      subsFormat,
+		RefreshPlayerOn403:  refreshPlayer,
+		RefreshURLsOn403:    refreshURLs,
+		MaxRetries:          retries,
+		Concurrency:         concurrentChunks,
+		UseMmap:             useMmap,
+		ChunkState:          chunkState,
+		MaxBytesPerSecond:   int64(limitRate),
+		RequestInterval:     sleepRequests,
+		Silent:              quiet,
+		ProgressJSON:        progressJSON,
+		EmbedChapters:       embedChapters,
+		NormalizeAudio:      normalizeAudio,
+		TargetLUFS:          targetLUFS,
+	}
+	if audioFormat != audioFormatBest {
+		downloader.AudioFormat = audioFormat
+	}
+	headers, err := parseHeaders(addHeaders)
+	exitOnError(err)
+	downloader.Headers = headers
+	downloader.UserAgent = userAgent
+	if userAgent == "browser" {
+		downloader.UserAgent = youtube.BrowserUserAgent
+	}
+	downloader.CacheDir = cacheDir
+	if container == "" {
+		// merging into the container saves the remux
+		downloader.Container = remux
 	}
+	downloader.CacheTTL = cacheTTL
+
 	downloader.HTTPClient = &http.Client{Transport: httpTransport}
+	if printTraffic {
+		downloader.TrafficLog = os.Stderr
+	}
+	exitOnError(downloader.SetupHTTPClient())
+
+	if execCommand != "" {
+		downloader.OnComplete = func(result ytdl.DownloadResult) {
+			runExec(execCommand, result)
+		}
+	}
+
+	// the segments are cut first, the others process the final video
+	if len(skipSegments) > 0 {
+		downloader.PostProcessors = append(downloader.PostProcessors, ytdl.SkipSegments{Segments: skipSegments})
+	}
+	if remux != "" {
+		downloader.PostProcessors = append(downloader.PostProcessors, ytdl.Remux{Container: remux})
+	}
+	if embedMetadata || embedDescription || embedSourceURL {
+		downloader.PostProcessors = append(downloader.PostProcessors, ytdl.WriteMetadata{
+			IncludeDescription: embedDescription,
+			IncludeSourceURL:   embedSourceURL,
+		})
+	}
+	if embedThumbnail {
+		downloader.PostProcessors = append(downloader.PostProcessors, ytdl.EmbedThumbnail{})
+	}
+	if writeInfoJSON {
+		downloader.PostProcessors = append(downloader.PostProcessors, ytdl.WriteInfoJSON{})
+	}
+	if writeDescription {
+		downloader.PostProcessors = append(downloader.PostProcessors, ytdl.WriteDescription{})
+	}
+	if writeThumbnail {
+		downloader.PostProcessors = append(downloader.PostProcessors, ytdl.WriteThumbnail{})
+	}
+	// last, the others rewrite the file
+	if setMtime {
+		downloader.PostProcessors = append(downloader.PostProcessors, ytdl.SetMtime{})
+	}
 
 	return downloader
 }
 
-func getVideoWithFormat(id string) (*youtube.Video, *youtube.Format, error) {
+// parseHeaders parses headers like "Accept-Language: de", the validation of names and values is left to SetupHTTPClient
+func parseHeaders(values []string) (http.Header, error) {
+	headers := http.Header{}
+
+	for _, value := range values {
+		key, val, ok := strings.Cut(value, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid header %q, expected \"Key: Value\"", value)
+		}
+		headers.Add(key, strings.TrimSpace(val))
+	}
+
+	return headers, nil
+}
+
+// getVideo fetches a video, hinting at expired cookies when it still requires signing in
+func getVideo(ctx context.Context, id string) (*youtube.Video, error) {
+	video, err := getDownloader().GetVideoCached(ctx, id)
+	if err != nil {
+		return nil, unavailableError(err)
+	}
+
+	return video, nil
+}
+
+// unavailableError replaces the error of a video that is unavailable with a line telling why and what may help
+func unavailableError(err error) error {
+	var reason error
+	var hint string
+
+	switch {
+	case errors.Is(err, youtube.ErrGeoBlocked):
+		reason, hint = youtube.ErrGeoBlocked, "try --proxy with a server in another country"
+	case errors.Is(err, youtube.ErrPrivate):
+		reason, hint = youtube.ErrPrivate, "try --cookies of an account it is shared with"
+	case errors.Is(err, youtube.ErrAgeRestricted), errors.Is(err, youtube.ErrLoginRequired):
+		reason, hint = youtube.ErrAgeRestricted, "try --cookies of a signed in account"
+	case errors.Is(err, youtube.ErrRemoved):
+		reason, hint = youtube.ErrRemoved, "check the URL or ID"
+	default:
+		return err
+	}
+
+	if cookiesFile != "" && isAuthError(err) {
+		hint = fmt.Sprintf("the cookies of %s may have expired", cookiesFile)
+	}
+	youtube.Logger.Debug("video unavailable", "error", err)
+
+	return fmt.Errorf("%w, %s", reason, hint)
+}
+
+// isAuthError reports whether the error is caused by a video only available to signed in users
+func isAuthError(err error) bool {
+	return errors.Is(err, youtube.ErrLoginRequired) || errors.Is(err, youtube.ErrVideoPrivate) ||
+		errors.Is(err, youtube.ErrAgeRestricted)
+}
+
+// getVideoWithFormat returns the video with the format selected by the flags and the quality of --quality it matched,
+// or without a format for live streams, which are recorded from the HLS manifest
+func getVideoWithFormat(ctx context.Context, id string) (*youtube.Video, *youtube.Format, string, error) {
 	dl := getDownloader()
-	video, err := dl.GetVideo(id)
+	video, err := getVideo(ctx, id)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, "", err
+	}
+	if video.IsLive {
+		return video, nil, "", nil
 	}
 	formats := video.Formats
 	if mimetype != "" {
 		formats = formats.Type(mimetype)
 	}
 	if len(formats) == 0 {
-		return nil, nil, errors.New("no formats found")
+		return nil, nil, "", &ytdl.DownloadError{Kind: ytdl.KindNoFormat, Err: errors.New("no formats found")}
 	}
 
-	var format *youtube.Format
+	formats, err = dl.FilterFilesize(formats)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	formats, err = dl.FilterCodecs(formats)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	var (
+		format  *youtube.Format
+		quality string
+	)
 	itag, _ := strconv.Atoi(outputQuality)
 	switch {
 	case itag > 0:
 		// When an itag is specified, do not filter format with mime-type
 		format = video.Formats.FindByItag(itag)
 		if format == nil {
-			return nil, nil, fmt.Errorf("unable to find format with itag %d", itag)
+			return nil, nil, "", &ytdl.DownloadError{Kind: ytdl.KindNoFormat, Err: fmt.Errorf("unable to find format with itag %d", itag)}
 		}
 
 	case outputQuality != "":
-		format = formats.FindByQuality(outputQuality)
-		if format == nil {
-			return nil, nil, fmt.Errorf("unable to find format with quality %s", outputQuality)
+		formats, quality = ytdl.FilterQuality(formats, outputQuality)
+		if len(formats) == 0 {
+			return nil, nil, "", &ytdl.DownloadError{Kind: ytdl.KindNoFormat, Err: fmt.Errorf("unable to find format with quality %s", outputQuality)}
+		}
+		dl.SortFormats(formats)
+		format = &formats[0]
+		if quality == ytdl.QualityWorst {
+			format = &formats[len(formats)-1]
 		}
 
 	default:
 		// select the first format
-		formats.Sort()
+		dl.SortFormats(formats)
 		format = &formats[0]
 	}
 
-	return video, format, nil
+	return video, format, quality, nil
 }
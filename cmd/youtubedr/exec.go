@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/kkdai/youtube/v2"
+	ytdl "github.com/kkdai/youtube/v2/downloader"
+)
+
+// execPlaceholder is replaced by the path of the downloaded file in the --exec command
+const execPlaceholder = "{filepath}"
+
+const execUsage = "Run this shell command after each successful download, e.g. --exec \"mv {filepath} /media/done\".\n" +
+	execPlaceholder + " is replaced by the quoted path of the file, or the path is appended if it is missing. Failures are logged"
+
+// runExec runs the --exec command for the result of a download, a failing command doesn't fail the download
+func runExec(command string, result ytdl.DownloadResult) {
+	if result.Path == ytdl.Stdout {
+		youtube.Logger.Warn("not running the --exec command for a video written to stdout")
+		return
+	}
+
+	line := execCommandLine(command, result.Path)
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", line)
+	} else {
+		cmd = exec.Command("sh", "-c", line)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	youtube.Logger.Debug("running --exec command", "command", line)
+
+	if err := cmd.Run(); err != nil {
+		youtube.Logger.Error("the --exec command failed", "command", line, "error", err)
+	}
+}
+
+// execCommandLine replaces the placeholder of the command with the quoted path, or appends it
+func execCommandLine(command, path string) string {
+	quoted := quoteArg(path)
+	if !strings.Contains(command, execPlaceholder) {
+		return command + " " + quoted
+	}
+
+	return strings.ReplaceAll(command, execPlaceholder, quoted)
+}
+
+// quoteArg quotes the argument for the shell of runExec
+func quoteArg(arg string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + arg + `"`
+	}
+
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
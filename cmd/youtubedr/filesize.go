@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// byteSize is a flag value for sizes like "500K", "1.5M" or "2G" (binary units)
+type byteSize int64
+
+var byteSizeUnits = map[string]float64{
+	"":  1,
+	"K": 1 << 10,
+	"M": 1 << 20,
+	"G": 1 << 30,
+}
+
+func (s *byteSize) Set(value string) error {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	value = strings.TrimSuffix(strings.TrimSuffix(value, "B"), "I")
+
+	number, unit := value, ""
+	if n := len(value); n > 0 && strings.ContainsAny(value[n-1:], "KMG") {
+		number, unit = value[:n-1], value[n-1:]
+	}
+
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil || f < 0 {
+		return fmt.Errorf("invalid size %q", value)
+	}
+
+	*s = byteSize(f * byteSizeUnits[unit])
+	return nil
+}
+
+func (s *byteSize) String() string {
+	return strconv.FormatInt(int64(*s), 10)
+}
+
+func (s *byteSize) Type() string {
+	return "size"
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// the kinds of streams a format contains
+const (
+	formatKindCombined  = "video+audio"
+	formatKindVideoOnly = "video only"
+	formatKindAudioOnly = "audio only"
+)
+
+// FormatInfo is a row of the formats command, encoded as it is with --output json and xml.
+// Size is in bytes, estimated from the bitrate and duration for formats without a content length.
+type FormatInfo struct {
+	Itag          int
+	Kind          string
+	MimeType      string
+	Quality       string
+	FPS           int
+	AudioChannels int
+	Bitrate       int
+	Size          int64
+}
+
+// formatsCmd represents the formats command
+var formatsCmd = &cobra.Command{
+	Use:     "formats",
+	Short:   "Print all available formats of the desired video, to download one with -q <itag>",
+	Example: `youtubedr formats https://www.youtube.com/watch?v=rFejpH_tAHM`,
+	Args:    cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return checkOutputFormat()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		video, err := getVideo(cmd.Context(), args[0])
+		exitOnError(err)
+
+		formats := append(youtube.FormatList(nil), video.Formats...)
+		formats.Sort()
+
+		infos := make([]FormatInfo, 0, len(formats))
+		for i := range formats {
+			format := &formats[i]
+			infos = append(infos, FormatInfo{
+				Itag:          format.ItagNo,
+				Kind:          formatKind(format),
+				MimeType:      format.MimeType,
+				Quality:       format.QualityLabel,
+				FPS:           format.FPS,
+				AudioChannels: format.AudioChannels,
+				Bitrate:       formatBitrate(format),
+				Size:          formatSize(video, format),
+			})
+		}
+
+		exitOnError(writeOutput(os.Stdout, infos, func(w io.Writer) {
+			writeFormatsOutput(w, infos)
+		}))
+	},
+}
+
+func writeFormatsOutput(w io.Writer, infos []FormatInfo) {
+	table := tablewriter.NewWriter(w)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{
+		"itag",
+		"kind",
+		"MimeType",
+		"quality",
+		"fps",
+		"audio\nchannels",
+		"bitrate",
+		"size [MB]",
+	})
+
+	for _, info := range infos {
+		table.Append([]string{
+			strconv.Itoa(info.Itag),
+			info.Kind,
+			info.MimeType,
+			info.Quality,
+			strconv.Itoa(info.FPS),
+			strconv.Itoa(info.AudioChannels),
+			strconv.Itoa(info.Bitrate),
+			fmt.Sprintf("~%0.1f", float64(info.Size)/1024/1024),
+		})
+	}
+
+	table.Render()
+}
+
+// formatKind returns whether the format has video, audio or both
+func formatKind(format *youtube.Format) string {
+	hasVideo := format.Width > 0 || format.QualityLabel != ""
+
+	switch {
+	case hasVideo && format.AudioChannels > 0:
+		return formatKindCombined
+	case hasVideo:
+		return formatKindVideoOnly
+	default:
+		return formatKindAudioOnly
+	}
+}
+
+// formatBitrate returns the average bitrate of the format, or its peak bitrate if the average is unknown
+func formatBitrate(format *youtube.Format) int {
+	if format.AverageBitrate > 0 {
+		return format.AverageBitrate
+	}
+
+	// Some formats don't have the average bitrate
+	return format.Bitrate
+}
+
+// formatSize returns the size of the format, estimated by its bitrate and the duration of the video if unknown
+func formatSize(video *youtube.Video, format *youtube.Format) int64 {
+	if size := format.EstimatedSize(); size > 0 {
+		return size
+	}
+
+	return int64(float64(formatBitrate(format)) * video.Duration.Seconds() / 8)
+}
+
+func init() {
+	rootCmd.AddCommand(formatsCmd)
+	addFormatFlag(formatsCmd.Flags())
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -24,54 +25,61 @@ type VideoFormat struct {
 }
 
 type VideoInfo struct {
-	ID          string
-	Title       string
-	Author      string
-	Duration    string
-	Description string
-	Formats     []VideoFormat
+	ID           string
+	Title        string
+	Author       string
+	Duration     string
+	Views        int
+	PublishDate  string `json:",omitempty" xml:",omitempty"`
+	VideoFormats int    // number of formats with video
+	AudioFormats int    // number of audio-only formats
+	Description  string
+	Formats      []VideoFormat
 }
 
 // infoCmd represents the info command
 var infoCmd = &cobra.Command{
 	Use:   "info",
 	Short: "Print metadata of the desired video",
+	Long:  "Print the title, author, duration, views, publish date and the available formats of the video, without downloading it.",
 	Args:  cobra.ExactArgs(1),
 	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if infoJSON {
+			if cmd.Flags().Changed("format") && outputFormat != outputFormatJSON {
+				return errors.New("--json can't be combined with another --format")
+			}
+			outputFormat = outputFormatJSON
+		}
 		return checkOutputFormat()
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		video, err := getDownloader().GetVideo(args[0])
+		video, err := getVideo(cmd.Context(), args[0])
 		exitOnError(err)
 
 		videoInfo := VideoInfo{
-			Title:       video.Title,
-			Author:      video.Author,
-			Duration:    video.Duration.String(),
-			Description: video.Description,
+			ID:           video.ID,
+			Title:        video.Title,
+			Author:       video.Author,
+			Duration:     video.Duration.String(),
+			Views:        video.Views,
+			VideoFormats: len(video.Formats.Type("video/")),
+			AudioFormats: len(video.Formats.Type("audio/")),
+			Description:  video.Description,
+		}
+		if !video.PublishDate.IsZero() {
+			videoInfo.PublishDate = video.PublishDate.Format("2006-01-02")
 		}
 
-		for _, format := range video.Formats {
-			bitrate := format.AverageBitrate
-			if bitrate == 0 {
-				// Some formats don't have the average bitrate
-				bitrate = format.Bitrate
-			}
-
-			size := format.ContentLength
-			if size == 0 {
-				// Some formats don't have this information
-				size = int64(float64(bitrate) * video.Duration.Seconds() / 8)
-			}
-
+		for i := range video.Formats {
+			format := &video.Formats[i]
 			videoInfo.Formats = append(videoInfo.Formats, VideoFormat{
 				Itag:          format.ItagNo,
 				FPS:           format.FPS,
 				VideoQuality:  format.QualityLabel,
 				AudioQuality:  strings.ToLower(strings.TrimPrefix(format.AudioQuality, "AUDIO_QUALITY_")),
 				AudioChannels: format.AudioChannels,
-				Size:          size,
-				Bitrate:       bitrate,
+				Size:          formatSize(video, format),
+				Bitrate:       formatBitrate(format),
 				MimeType:      format.MimeType,
 			})
 		}
@@ -83,13 +91,18 @@ var infoCmd = &cobra.Command{
 }
 
 func writeInfoOutput(w io.Writer, info *VideoInfo) {
-	fmt.Println("Title:      ", info.Title)
-	fmt.Println("Author:     ", info.Author)
-	fmt.Println("Duration:   ", info.Duration)
+	fmt.Fprintln(w, "Title:      ", info.Title)
+	fmt.Fprintln(w, "Author:     ", info.Author)
+	fmt.Fprintln(w, "Duration:   ", info.Duration)
+	fmt.Fprintln(w, "Views:      ", info.Views)
+	if info.PublishDate != "" {
+		fmt.Fprintln(w, "Published:  ", info.PublishDate)
+	}
+	fmt.Fprintf(w, "Formats:     %d video, %d audio only\n", info.VideoFormats, info.AudioFormats)
 	if printDescription {
-		fmt.Println("Description:", info.Description)
+		fmt.Fprintln(w, "Description:", info.Description)
 	}
-	fmt.Println()
+	fmt.Fprintln(w)
 
 	table := tablewriter.NewWriter(w)
 	table.SetAutoWrapText(false)
@@ -120,10 +133,14 @@ func writeInfoOutput(w io.Writer, info *VideoInfo) {
 	table.Render()
 }
 
-var printDescription bool
+var (
+	printDescription bool
+	infoJSON         bool
+)
 
 func init() {
 	rootCmd.AddCommand(infoCmd)
 	addFormatFlag(infoCmd.Flags())
 	infoCmd.Flags().BoolVarP(&printDescription, "description", "d", false, "Print description")
+	infoCmd.Flags().BoolVar(&infoJSON, "json", false, "Print the info as JSON for scripts, the same as --format json")
 }
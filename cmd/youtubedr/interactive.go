@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// pickFormats lists the formats of the video numbered on out and reads the selection from in:
+// a number like "3", or a video only and an audio only format to merge like "3+12".
+// A video only format picked alone is merged with the best audio format, audio is nil for the others.
+// It asks again until the selection is valid.
+func pickFormats(video *youtube.Video, in io.Reader, out io.Writer) (format, audio *youtube.Format, err error) {
+	formats := append(youtube.FormatList(nil), video.Formats...)
+	formats.Sort()
+	if len(formats) == 0 {
+		return nil, nil, errors.New("no formats found")
+	}
+
+	writePickerTable(out, video, formats)
+
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprintf(out, "Pick a format (1-%d), or a video only and an audio only format to merge separated by \"+\": ", len(formats))
+		if !scanner.Scan() {
+			if err = scanner.Err(); err == nil {
+				err = errors.New("no format picked")
+			}
+			return nil, nil, err
+		}
+
+		format, audio, err = parsePick(formats, scanner.Text())
+		if err == nil {
+			return format, audio, nil
+		}
+		fmt.Fprintln(out, err)
+	}
+}
+
+// parsePick returns the formats of a selection like "3" or "3+12", in any order of video and audio
+func parsePick(formats youtube.FormatList, selection string) (format, audio *youtube.Format, err error) {
+	first, second, merge := strings.Cut(strings.TrimSpace(selection), "+")
+
+	if format, err = pickedFormat(formats, first); err != nil {
+		return nil, nil, err
+	}
+
+	kind := formatKind(format)
+
+	if merge {
+		if audio, err = pickedFormat(formats, second); err != nil {
+			return nil, nil, err
+		}
+		if kind == formatKindAudioOnly {
+			format, audio = audio, format
+		}
+		if formatKind(format) != formatKindVideoOnly || formatKind(audio) != formatKindAudioOnly {
+			return nil, nil, errors.New("merging needs a video only and an audio only format")
+		}
+		return format, audio, nil
+	}
+
+	if kind == formatKindVideoOnly {
+		// the formats are sorted, the first audio format is the best
+		for i := range formats {
+			if formatKind(&formats[i]) == formatKindAudioOnly {
+				return format, &formats[i], nil
+			}
+		}
+		return nil, nil, errors.New("the video has no audio format to merge the video only format with")
+	}
+
+	return format, nil, nil
+}
+
+func pickedFormat(formats youtube.FormatList, number string) (*youtube.Format, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(number))
+	if err != nil || n < 1 || n > len(formats) {
+		return nil, fmt.Errorf("no format %q, pick a number from 1 to %d", number, len(formats))
+	}
+
+	return &formats[n-1], nil
+}
+
+func writePickerTable(w io.Writer, video *youtube.Video, formats youtube.FormatList) {
+	table := tablewriter.NewWriter(w)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{
+		"#",
+		"itag",
+		"kind",
+		"MimeType",
+		"quality",
+		"fps",
+		"size [MB]",
+	})
+
+	for i := range formats {
+		format := &formats[i]
+
+		quality := format.QualityLabel
+		if quality == "" {
+			quality = strings.TrimPrefix(format.AudioQuality, "AUDIO_QUALITY_")
+		}
+
+		table.Append([]string{
+			strconv.Itoa(i + 1),
+			strconv.Itoa(format.ItagNo),
+			formatKind(format),
+			format.MimeType,
+			strings.ToLower(quality),
+			strconv.Itoa(format.FPS),
+			fmt.Sprintf("~%0.1f", float64(formatSize(video, format))/1024/1024),
+		})
+	}
+
+	table.Render()
+}
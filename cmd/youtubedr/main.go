@@ -1,12 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 )
 
 func main() {
-	exitOnError(rootCmd.Execute())
+	// an interrupt cancels all running downloads, which remove their incomplete files,
+	// and stops recording live streams
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	exitOnError(rootCmd.ExecuteContext(ctx))
 }
 
 func exitOnError(err error) {
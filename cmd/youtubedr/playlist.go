@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kkdai/youtube/v2"
+	ytdl "github.com/kkdai/youtube/v2/downloader"
+)
+
+var (
+	playlistStart int
+	playlistEnd   int
+	playlistDir   bool
+
+	playlistReverse bool // download from the last video to the first
+	playlistRandom  bool // download in random order
+)
+
+// playlistCmd represents the playlist command
+var playlistCmd = &cobra.Command{
+	Use:     "playlist",
+	Short:   "Downloads all videos of a playlist",
+	Example: `youtubedr playlist --start 10 https://www.youtube.com/playlist?list=PLqQ1RwlxOgeLTJ1f3fNMSwhjVgaWKo_9Z`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnError(parseFormatSelector())
+		if playlistReverse && playlistRandom {
+			exitOnError(errors.New("--playlist-reverse and --playlist-random can't be combined"))
+		}
+
+		playlist, err := getDownloader().GetPlaylist(args[0])
+		exitOnError(err)
+
+		from, to, err := playlistRange(len(playlist.Videos), playlistStart, playlistEnd)
+		exitOnError(err)
+
+		// the range is selected in the order of the playlist, then reordered
+		positions := playlistOrder(from, to, playlistReverse, playlistRandom)
+		ids := make([]string, len(positions))
+		for i, position := range positions {
+			ids[i] = playlist.Videos[position].ID
+		}
+
+		youtube.Logger.Info("downloading playlist", "title", playlist.Title, "videos", len(ids))
+
+		outputFormat = outputFormatPlain
+		if summaryJSON {
+			outputFormat = outputFormatJSON
+		}
+
+		result, err := downloadBatch(cmd.Context(), ids, func(ctx context.Context, i int, id string) (*ytdl.DownloadResult, error) {
+			// the positions in the whole playlist, also with --start and reordered
+			ctx = ytdl.WithPlaylistEntry(ctx, ytdl.PlaylistEntry{Title: playlist.Title, Index: positions[i] + 1, Count: len(playlist.Videos)})
+			return downloadPlaylistEntry(ctx, id)
+		})
+		exitOnError(writeSummary(result))
+		exitOnError(err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(playlistCmd)
+
+	playlistCmd.Flags().IntVar(&playlistStart, "start", 1, "The position of the first video to download, starting at 1")
+	playlistCmd.Flags().IntVar(&playlistEnd, "end", 0, "The position of the last video to download, the default is the end of the playlist")
+	playlistCmd.Flags().StringVarP(&outputDir, "directory", "d", ".", "The output directory.")
+	playlistCmd.Flags().StringVar(&outputTemplate, "output-template", "", outputTemplateUsage())
+	playlistCmd.Flags().BoolVar(&playlistReverse, "playlist-reverse", false, "Download the videos from the last to the first, after selecting them with --start and --end")
+	playlistCmd.Flags().BoolVar(&playlistRandom, "playlist-random", false, "Download the videos in random order, after selecting them with --start and --end")
+	playlistCmd.Flags().BoolVar(&playlistDir, "playlist-dir", false, "Put the videos into a directory named after the playlist in the output directory")
+	playlistCmd.Flags().BoolVar(&noOverwrite, "no-overwrite", false, "Skip videos whose output file already exists, e.g. when resuming a playlist")
+	playlistCmd.Flags().BoolVar(&uniqueNames, "unique-names", false, "Add \" (1)\", \" (2)\" and so on to the names of output files that already exist instead of overwriting them")
+	playlistCmd.Flags().BoolVar(&noSpaceCheck, "no-space-check", false, "Don't check for enough free disk space before downloading, for file systems reporting it unreliably")
+	playlistCmd.Flags().DurationVar(&downloadTimeout, "timeout", 0, "Abort the download of a video taking longer than this, e.g. 10m, removing its incomplete files")
+	playlistCmd.Flags().DurationVar(&sleepRequests, "sleep-requests", 0, "Wait at least this long between fetching videos, e.g. 2s, so large batches don't get throttled. Streams aren't delayed")
+	playlistCmd.Flags().StringVar(&audioLang, "audio-lang", "", "The language of the audio track for videos with multiple tracks, e.g. \"es\"")
+	playlistCmd.Flags().BoolVar(&strictAudioLang, "strict-audio-lang", false, "Fail the videos whose --audio-lang track is not available instead of using the default track")
+	playlistCmd.Flags().BoolVar(&summaryJSON, "json", false, "Print the summary of the downloads as JSON")
+	playlistCmd.Flags().BoolVar(&writeInfoJSON, "write-info-json", false, "Write the metadata and available formats of each video next to its file, e.g. \"Title.info.json\" for \"Title.mp4\"")
+	playlistCmd.Flags().BoolVar(&writeDescription, "write-description", false, "Write the description of each video next to its file, e.g. \"Title.description\" for \"Title.mp4\"")
+	playlistCmd.Flags().BoolVar(&writeThumbnail, "write-thumbnail", false, "Write the largest thumbnail of each video next to its file as it is, e.g. \"Title.webp\" for \"Title.mp4\"")
+	playlistCmd.Flags().BoolVar(&setMtime, "set-mtime", false, "Set the modification time of each file to the publish date of its video")
+	playlistCmd.Flags().StringVar(&execCommand, "exec", "", execUsage)
+	addProgressFlags(playlistCmd.Flags())
+	addQualityFlag(playlistCmd.Flags())
+	addMimeTypeFlag(playlistCmd.Flags())
+	addFormatSelectorFlag(playlistCmd.Flags())
+}
+
+// downloadPlaylistEntry downloads a video of a playlist, logging the unavailable ones that are skipped
+func downloadPlaylistEntry(ctx context.Context, id string) (*ytdl.DownloadResult, error) {
+	result, err := download(ctx, id)
+	if err != nil {
+		youtube.Logger.Warn("skipping video", "id", id, "error", err)
+	}
+
+	return result, err
+}
+
+// playlistOrder returns the indexes from to to, exclusive, in the order of the playlist, reversed or shuffled
+func playlistOrder(from, to int, reverse, random bool) []int {
+	positions := make([]int, 0, to-from)
+	for i := from; i < to; i++ {
+		positions = append(positions, i)
+	}
+
+	switch {
+	case reverse:
+		slices.Reverse(positions)
+	case random:
+		rand.Shuffle(len(positions), func(i, j int) {
+			positions[i], positions[j] = positions[j], positions[i]
+		})
+	}
+
+	return positions
+}
+
+// playlistRange returns the slice bounds of the videos from the 1-based positions start to end, inclusive.
+// An end of 0 is the last video.
+func playlistRange(n, start, end int) (int, int, error) {
+	if end == 0 {
+		end = n
+	}
+
+	switch {
+	case start < 1:
+		return 0, 0, fmt.Errorf("--start must be at least 1, not %d", start)
+	case end < start:
+		return 0, 0, fmt.Errorf("--end %d is before --start %d", end, start)
+	case start > n:
+		return 0, 0, fmt.Errorf("--start %d is beyond the %d videos of the playlist", start, n)
+	}
+
+	return start - 1, min(end, n), nil
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+
+	ytdl "github.com/kkdai/youtube/v2/downloader"
 )
 
 var (
@@ -20,8 +25,15 @@ var rootCmd = &cobra.Command{
 	Short: "Youtube downloader written in Golang",
 	Long: `This tool is meant to be used to download CC0 licenced content, we do not support nor recommend using it for illegal activities.
 
-Use the HTTP_PROXY environment variable to set a HTTP or SOCSK5 proxy. The proxy type is determined by the URL scheme.
-"http", "https", and "socks5" are supported. If the scheme is empty, "http" is assumed.`,
+Use --proxy or the HTTP_PROXY environment variable to set a HTTP or SOCKS5 proxy. The proxy type is determined by the URL scheme.
+"http", "https", and "socks5" are supported. If the scheme is empty, "http" is assumed.
+
+The defaults of all flags can be set in $HOME/.youtubedr.yaml or the file of --config, by their names like
+"directory: ~/Videos", and in environment variables like YOUTUBEDR_FFMPEG_PATH.
+Flags override environment variables, which override the config file.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		exitOnError(applyConfig(cmd.Flags()))
+	},
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	//	Run: func(cmd *cobra.Command, args []string) { },
@@ -37,6 +49,24 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.youtubedr.yaml)")
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Set log level (error/warn/info/debug)")
 	rootCmd.PersistentFlags().BoolVar(&insecureSkipVerify, "insecure", false, "Skip TLS server certificate verification")
+	rootCmd.PersistentFlags().StringVar(&dnsServer, "dns", "", "Resolve host names with the DNS server at this IP address instead of the system resolver")
+	rootCmd.PersistentFlags().StringVar(&proxyURL, "proxy", "", "Send all requests through this proxy, e.g. http://proxy:3128 or socks5://localhost:1080")
+	rootCmd.PersistentFlags().StringVar(&cookiesFile, "cookies", "", "Send the youtube.com cookies of this Netscape cookies.txt file, e.g. exported from a signed in browser for age-restricted videos")
+	rootCmd.PersistentFlags().StringArrayVar(&addHeaders, "add-header", nil, "Add a header to all requests, e.g. --add-header \"Accept-Language: de\", it replaces the header of the same name")
+	rootCmd.PersistentFlags().StringVar(&userAgent, "user-agent", "", "Send this User-Agent with all requests, including the stream downloads, or \"browser\" for the one of a recent desktop browser.\n"+
+		"The default is the User-Agent of the YouTube client in use. Changing it can help when downloads fail with 403 Forbidden")
+	rootCmd.PersistentFlags().StringVar(&cacheDir, "cache-dir", "", "Cache the fetched videos in this directory, so repeated commands on a video don't fetch it again")
+	rootCmd.PersistentFlags().DurationVar(&cacheTTL, "cache-ttl", ytdl.DefaultCacheTTL, "How long the videos of --cache-dir are used, they are fetched again earlier when their stream URLs expire or are forbidden")
+	rootCmd.PersistentFlags().BoolVar(&printTraffic, "print-traffic", false, "Print all HTTP requests and responses to stderr, with signatures and cookies redacted")
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Disable colored output")
+	rootCmd.PersistentFlags().IntVarP(&concurrency, "jobs", "j", 3, "Maximum number of videos downloaded at the same time")
+	rootCmd.PersistentFlags().IntVar(&concurrency, "concurrency", 3, "Maximum number of videos downloaded at the same time")
+	rootCmd.PersistentFlags().MarkDeprecated("concurrency", "use --jobs instead") //nolint:errcheck
+}
+
+// flagAliases maps deprecated flags to the flags replacing them, which take precedence
+var flagAliases = map[string]string{
+	"concurrency": "jobs",
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -57,10 +87,43 @@ func initConfig() {
 		viper.SetConfigName(".youtubedr")
 	}
 
-	viper.AutomaticEnv() // read in environment variables that match
+	// read in environment variables that match, e.g. YOUTUBEDR_FFMPEG_PATH for ffmpeg-path
+	viper.SetEnvPrefix("youtubedr")
+	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	viper.AutomaticEnv()
 
 	// If a config file is found, read it in.
-	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+	err := viper.ReadInConfig()
+	if errors.As(err, new(viper.ConfigFileNotFoundError)) {
+		return
 	}
+	exitOnError(err)
+}
+
+// applyConfig sets the flags not given on the command line to their values of the environment or config file
+func applyConfig(flags *pflag.FlagSet) error {
+	var err error
+
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if err != nil || flag.Changed || flag.Name == "config" || !viper.IsSet(flag.Name) {
+			return
+		}
+
+		// the old config key of a renamed flag is still read, unless the new flag is given
+		if name, ok := flagAliases[flag.Name]; ok && flags.Changed(name) {
+			return
+		}
+
+		if value, ok := flag.Value.(pflag.SliceValue); ok {
+			err = value.Replace(viper.GetStringSlice(flag.Name))
+		} else {
+			err = flag.Value.Set(viper.GetString(flag.Name))
+		}
+
+		if err != nil {
+			err = fmt.Errorf("invalid config value of %s: %w", flag.Name, err)
+		}
+	})
+
+	return err
 }
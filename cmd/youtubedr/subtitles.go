@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+type SubtitlesInfo struct {
+	ID        string
+	Title     string
+	Subtitles []SubtitleInfo
+}
+
+type SubtitleInfo struct {
+	LanguageCode  string
+	Name          string
+	AutoGenerated bool
+	Translatable  bool
+}
+
+var (
+	// subtitlesCmd represents the subtitles command
+	subtitlesCmd = &cobra.Command{
+		Use:   "subtitles",
+		Short: "Inspect the subtitles of a video",
+	}
+
+	// subtitlesListCmd represents the subtitles list command
+	subtitlesListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "Print the available subtitle languages of the desired video",
+		Args:  cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return checkOutputFormat()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			video, err := getVideo(cmd.Context(), args[0])
+			exitOnError(err)
+
+			subtitlesInfo := SubtitlesInfo{
+				ID:    video.ID,
+				Title: video.Title,
+			}
+			for _, track := range video.CaptionTracks {
+				subtitlesInfo.Subtitles = append(subtitlesInfo.Subtitles, SubtitleInfo{
+					LanguageCode:  track.LanguageCode,
+					Name:          track.Name.SimpleText,
+					AutoGenerated: track.Kind == "asr",
+					Translatable:  track.IsTranslatable,
+				})
+			}
+
+			exitOnError(writeOutput(os.Stdout, &subtitlesInfo, func(w io.Writer) {
+				writeSubtitlesOutput(w, &subtitlesInfo)
+			}))
+		},
+	}
+)
+
+func writeSubtitlesOutput(w io.Writer, info *SubtitlesInfo) {
+	fmt.Println("Title:      ", info.Title)
+	fmt.Println()
+
+	if len(info.Subtitles) == 0 {
+		fmt.Fprintln(w, "No subtitles available")
+		return
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Language", "Name", "Type", "Translatable"})
+
+	for _, subtitle := range info.Subtitles {
+		kind := "manual"
+		if subtitle.AutoGenerated {
+			kind = "auto-generated"
+		}
+
+		table.Append([]string{
+			subtitle.LanguageCode,
+			subtitle.Name,
+			kind,
+			strconv.FormatBool(subtitle.Translatable),
+		})
+	}
+
+	table.Render()
+}
+
+func init() {
+	rootCmd.AddCommand(subtitlesCmd)
+	subtitlesCmd.AddCommand(subtitlesListCmd)
+	addFormatFlag(subtitlesListCmd.Flags())
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	ytdl "github.com/kkdai/youtube/v2/downloader"
+)
+
+// timestamp is a flag value for positions in a video like "90", "1:30" or "1:02:03.5"
+type timestamp time.Duration
+
+func (t *timestamp) Set(value string) error {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) > 3 {
+		return fmt.Errorf("invalid timestamp %q", value)
+	}
+
+	var seconds float64
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(part, 64)
+		// only the seconds may have a fraction, and the minutes and seconds after hours or minutes are below 60
+		if err != nil || f < 0 || (i < len(parts)-1 && f != float64(int(f))) || (i > 0 && f >= 60) {
+			return fmt.Errorf("invalid timestamp %q", value)
+		}
+		seconds = seconds*60 + f
+	}
+
+	*t = timestamp(seconds * float64(time.Second))
+	return nil
+}
+
+func (t *timestamp) String() string {
+	return time.Duration(*t).String()
+}
+
+func (t *timestamp) Type() string {
+	return "timestamp"
+}
+
+// segments is a flag value for a list of time ranges like "30-45,1:20-1:35"
+type segments []ytdl.Segment
+
+func (s *segments) Set(value string) error {
+	var result segments
+	for _, r := range strings.Split(value, ",") {
+		from, to, ok := strings.Cut(r, "-")
+		if !ok {
+			return fmt.Errorf("invalid range %q, expected start-end", r)
+		}
+
+		var start, end timestamp
+		if err := start.Set(from); err != nil {
+			return err
+		}
+		if err := end.Set(to); err != nil {
+			return err
+		}
+
+		result = append(result, ytdl.Segment{Start: time.Duration(start), End: time.Duration(end)})
+	}
+
+	*s = result
+	return nil
+}
+
+func (s *segments) String() string {
+	ranges := make([]string, len(*s))
+	for i, segment := range *s {
+		ranges[i] = segment.String()
+	}
+
+	return strings.Join(ranges, ",")
+}
+
+func (s *segments) Type() string {
+	return "ranges"
+}
@@ -9,12 +9,17 @@ import (
 // urlCmd represents the url command
 var urlCmd = &cobra.Command{
 	Use:   "url",
-	Short: "Only output the stream-url to desired video",
+	Short: "Only output the stream-url to desired video, or the HLS manifest of live streams",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		video, format, err := getVideoWithFormat(args[0])
+		video, format, _, err := getVideoWithFormat(cmd.Context(), args[0])
 		exitOnError(err)
 
+		if video.IsLive {
+			fmt.Println(video.HLSManifestURL)
+			return
+		}
+
 		url, err := downloader.GetStreamURL(video, format)
 		exitOnError(err)
 
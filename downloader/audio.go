@@ -0,0 +1,275 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// FilterAudioLanguage reduces the formats to the audio track in AudioLanguage.
+// If the video has no such track, a warning gets logged and the default track is used instead,
+// unless StrictAudioLang is set.
+func (dl *Downloader) FilterAudioLanguage(formats youtube.FormatList) (youtube.FormatList, error) {
+	if dl.AudioLanguage == "" {
+		return formats, nil
+	}
+
+	if matching := formats.AudioLanguage(dl.AudioLanguage); len(matching) > 0 {
+		return matching, nil
+	}
+
+	available := audioLanguages(formats)
+	formats = formats.DefaultAudioTrack()
+
+	err := &ErrAudioLanguageUnavailable{
+		Requested: dl.AudioLanguage,
+		Chosen:    audioLanguage(formats),
+		Available: available,
+	}
+
+	if dl.StrictAudioLang {
+		return nil, err
+	}
+
+	youtube.Logger.Warn("requested audio language is not available, using the default audio track",
+		"requested", err.Requested,
+		"chosen", err.Chosen,
+		"available", strings.Join(err.Available, ", "),
+	)
+
+	return formats, nil
+}
+
+// audioLanguages returns the sorted languages of the audio tracks of the formats
+func audioLanguages(formats youtube.FormatList) []string {
+	var languages []string
+	for _, f := range formats {
+		if f.AudioTrack != nil && !slices.Contains(languages, f.AudioTrack.Language()) {
+			languages = append(languages, f.AudioTrack.Language())
+		}
+	}
+	slices.Sort(languages)
+
+	return languages
+}
+
+// audioLanguage returns the language of the first format having an audio track
+func audioLanguage(formats youtube.FormatList) string {
+	for _, f := range formats {
+		if f.AudioTrack != nil {
+			return f.AudioTrack.Language()
+		}
+	}
+
+	return ""
+}
+
+// audioTargets are the formats DownloadAudio converts to, by file extension
+var audioTargets = map[string]struct {
+	codec  string   // streams of this codec are remuxed without re-encoding, as in the mime type
+	encode []string // ffmpeg arguments for transcoding streams of other codecs
+}{
+	".mp3":  {"", []string{"-c:a", "libmp3lame", "-q:a", "2"}},
+	".opus": {"opus", []string{"-c:a", "libopus", "-b:a", "160k"}},
+	".aac":  {"mp4a", []string{"-c:a", "aac", "-b:a", "192k"}},
+	".m4a":  {"mp4a", []string{"-c:a", "aac", "-b:a", "192k"}},
+}
+
+// DownloadAudio downloads the best audio-only stream of the audio quality, e.g. "medium", or of any quality if it is empty.
+// With AudioFormat, or an outputFile with the extension of one of its formats, the stream is converted with ffmpeg.
+// Streams of the target codec are preferred, they are only remuxed instead of transcoded.
+func (dl *Downloader) DownloadAudio(ctx context.Context, outputFile string, v *youtube.Video, quality string) (*DownloadResult, error) {
+	ext, err := dl.audioExtension(outputFile)
+	if err != nil {
+		return nil, err
+	}
+	target, convert := audioTargets[ext]
+
+	format, err := dl.getAudioFormat(v, quality, target.codec)
+	if err != nil {
+		return nil, err
+	}
+
+	if !convert {
+		return dl.Download(ctx, v, format, outputFile)
+	}
+
+	if outputFile == Stdout {
+		return nil, fmt.Errorf("%w, converting the audio to %s needs an output file", ErrStdoutUnsupported, strings.TrimPrefix(ext, "."))
+	}
+
+	// the filter needs the audio re-encoded
+	filter, err := dl.loudnormFilter()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	remux := filter == nil && target.codec != "" && strings.Contains(format.MimeType, target.codec)
+
+	youtube.Logger.Info(
+		"Downloading audio",
+		"id", v.ID,
+		"mimeType", format.MimeType,
+		"format", strings.TrimPrefix(ext, "."),
+		"remux", remux,
+	)
+
+	if outputFile == "" {
+		outputFile, err = dl.getDefaultFile(ctx, v, format, ext)
+		if err != nil {
+			return nil, err
+		}
+	}
+	destFile, err := dl.joinOutputDir(outputFile)
+	if err != nil {
+		return nil, err
+	}
+	destFile = dl.uniqueName(destFile)
+	defer dl.releaseName(destFile)
+
+	if dl.skipExisting(destFile, 0) {
+		return dl.complete(&DownloadResult{Path: destFile, Itag: format.ItagNo, Skipped: true, Elapsed: time.Since(start)})
+	}
+
+	codecArgs := target.encode
+	if remux {
+		codecArgs = []string{"-c:a", "copy"}
+	}
+	codecArgs = append(slices.Clone(codecArgs), filter...)
+
+	written, err := dl.convertStream(ctx, v, format, destFile, func(input, output string) []string {
+		return convertAudioArgs(input, output, codecArgs)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = dl.verifyMedia(ctx, destFile, dl.expectedDuration(v), dl.expectedDuration(v)); err != nil {
+		return nil, err
+	}
+
+	destFile, err = dl.runPostProcessors(ctx, v, destFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return dl.complete(&DownloadResult{
+		Path:    destFile,
+		Itag:    format.ItagNo,
+		Bytes:   written,
+		Elapsed: time.Since(start),
+	})
+}
+
+// audioExtension returns the extension of the file DownloadAudio writes, by AudioFormat or the outputFile
+func (dl *Downloader) audioExtension(outputFile string) (string, error) {
+	if dl.AudioFormat == "" {
+		return strings.ToLower(filepath.Ext(outputFile)), nil
+	}
+
+	ext := "." + strings.ToLower(dl.AudioFormat)
+	if _, ok := audioTargets[ext]; !ok {
+		return "", fmt.Errorf("unsupported audio format: %s", dl.AudioFormat)
+	}
+
+	return ext, nil
+}
+
+// getAudioFormat returns the best audio-only format of the quality, preferably of the codec
+func (dl *Downloader) getAudioFormat(v *youtube.Video, quality string, codec string) (*youtube.Format, error) {
+	formats := v.Formats.Type("audio")
+	if quality != "" {
+		formats = formats.AudioQuality(quality)
+	}
+
+	formats, err := dl.FilterAudioLanguage(formats)
+	if err != nil {
+		return nil, downloadError(KindNoFormat, err)
+	}
+
+	formats, err = dl.FilterFilesize(formats)
+	if err != nil {
+		return nil, downloadError(KindNoFormat, err)
+	}
+
+	formats, err = dl.FilterCodecs(formats)
+	if err != nil {
+		return nil, downloadError(KindNoFormat, err)
+	}
+
+	if matching := formats.Type(codec); codec != "" && len(matching) > 0 {
+		formats = matching
+	}
+
+	if len(formats) == 0 {
+		return nil, downloadError(KindNoFormat, errors.New("no audio format found after filtering"))
+	}
+
+	dl.SortFormats(formats)
+
+	return &formats[0], nil
+}
+
+// convertAudioArgs returns the ffmpeg arguments for converting the audio file into destFile with the codec arguments
+func convertAudioArgs(audioFile, destFile string, codecArgs []string) []string {
+	args := []string{"-y", "-loglevel", "warning", "-i", audioFile, "-vn"}
+	args = append(args, codecArgs...)
+
+	return append(args, destFile)
+}
+
+// convertStream downloads the stream of the format into a temporary file and writes destFile from it
+// with the ffmpeg arguments returned by args for the input and output file. The output is converted into
+// a temporary file of TempDir or next to destFile, which is renamed to destFile once ffmpeg succeeds.
+func (dl *Downloader) convertStream(ctx context.Context, v *youtube.Video, format *youtube.Format, destFile string, args func(input, output string) []string) (int64, error) {
+	tempDir, err := dl.getTempDir(filepath.Dir(destFile))
+	if err != nil {
+		return 0, err
+	}
+
+	streamFile, err := os.CreateTemp(tempDir, "youtube_*"+pickIdealFileExtension(format.MimeType))
+	if err != nil {
+		return 0, err
+	}
+	defer dl.removeIntermediate(streamFile.Name())
+
+	written, err := dl.videoDLWorker(ctx, streamFile, v, format)
+	streamFile.Close()
+	if err != nil {
+		return written, err
+	}
+
+	input, convertFile := streamFile.Name(), destFile
+	if dl.PrintFFmpegCommands {
+		// the printed command refers to the stream by its name next to the output
+		input = streamFileName(destFile, format)
+		if err = safeRename(streamFile.Name(), input); err != nil {
+			return written, err
+		}
+		youtube.Logger.Info("keeping the input of the printed ffmpeg command", "path", input)
+	} else {
+		// moved to the destination once complete, so a failed conversion never has the final name
+		convertFile = filepath.Join(tempDir, "youtube_"+filepath.Base(streamFile.Name())+filepath.Ext(destFile))
+		defer os.Remove(convertFile)
+	}
+
+	if err = dl.runFFmpeg(ctx, args(input, convertFile)...); err != nil {
+		return written, err
+	}
+
+	if convertFile != destFile && !dl.PrintFFmpegCommands {
+		if err = safeRename(convertFile, destFile); err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
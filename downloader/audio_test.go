@@ -0,0 +1,189 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func loadMultiAudioVideo(t *testing.T) *youtube.Video {
+	data, err := os.ReadFile("testdata/multi_audio_formats.json")
+	require.NoError(t, err)
+
+	video := &youtube.Video{ID: "multiaudio1"}
+	require.NoError(t, json.Unmarshal(data, &video.Formats))
+
+	return video
+}
+
+func TestDownloader_getVideoAudioFormats_audioLanguage(t *testing.T) {
+	video := loadMultiAudioVideo(t)
+
+	tests := []struct {
+		name     string
+		language string
+		strict   bool
+		mimetype string
+		want     string
+		wantErr  *ErrAudioLanguageUnavailable
+	}{
+		{name: "default", want: "en"},
+		{name: "requested", language: "es", want: "es-419"},
+		{name: "requested webm", language: "es", mimetype: "webm", want: "es-419"},
+		{name: "fallback", language: "fr", want: "en"},
+		{name: "fallback for mime type", language: "de", mimetype: "webm", want: "en"},
+		{name: "strict", language: "fr", strict: true, wantErr: &ErrAudioLanguageUnavailable{Requested: "fr", Chosen: "en", Available: []string{"de", "en", "es-419"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dl := Downloader{AudioLanguage: tt.language, StrictAudioLang: tt.strict}
+
+			_, audioFormat, err := dl.getVideoAudioFormats(video, "", tt.mimetype)
+			if tt.wantErr != nil {
+				var langErr *ErrAudioLanguageUnavailable
+				require.ErrorAs(t, err, &langErr)
+				assert.Equal(t, tt.wantErr, langErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.NotNil(t, audioFormat.AudioTrack)
+			assert.Equal(t, tt.want, audioFormat.AudioTrack.Language())
+		})
+	}
+}
+
+func TestErrAudioLanguageUnavailable(t *testing.T) {
+	assert.Equal(t, `audio language "fr" is not available, the default is "en"`, ErrAudioLanguageUnavailable{Requested: "fr", Chosen: "en"}.Error())
+	assert.Equal(t, `audio language "fr" is not available`, ErrAudioLanguageUnavailable{Requested: "fr"}.Error())
+	assert.Equal(t, `audio language "fr" is not available, the default is "en", available are en, es-419`,
+		ErrAudioLanguageUnavailable{Requested: "fr", Chosen: "en", Available: []string{"en", "es-419"}}.Error())
+}
+
+var testAudioFormats = youtube.FormatList{
+	{ItagNo: 18, MimeType: `video/mp4; codecs="avc1.42001E, mp4a.40.2"`, AudioChannels: 2, Width: 640},
+	{ItagNo: 140, MimeType: `audio/mp4; codecs="mp4a.40.2"`, AudioQuality: "AUDIO_QUALITY_MEDIUM", AudioChannels: 2, Bitrate: 130000},
+	{ItagNo: 251, MimeType: `audio/webm; codecs="opus"`, AudioQuality: "AUDIO_QUALITY_MEDIUM", AudioChannels: 2, Bitrate: 140000},
+	{ItagNo: 249, MimeType: `audio/webm; codecs="opus"`, AudioQuality: "AUDIO_QUALITY_LOW", AudioChannels: 2, Bitrate: 50000},
+}
+
+func TestDownloader_getAudioFormat(t *testing.T) {
+	tests := []struct {
+		quality string
+		codec   string
+		want    int
+	}{
+		{want: 140},
+		{codec: "opus", want: 251},
+		{codec: "mp4a", want: 140},
+		{quality: "low", want: 249},
+		{quality: "low", codec: "mp4a", want: 249},
+	}
+
+	dl := Downloader{}
+	for _, tt := range tests {
+		format, err := dl.getAudioFormat(&youtube.Video{Formats: testAudioFormats}, tt.quality, tt.codec)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, format.ItagNo, "quality %q, codec %q", tt.quality, tt.codec)
+	}
+
+	_, err := dl.getAudioFormat(&youtube.Video{Formats: testAudioFormats}, "high", "")
+	assert.EqualError(t, err, "no audio format found after filtering")
+}
+
+func TestDownloader_audioExtension(t *testing.T) {
+	dl := Downloader{}
+
+	ext, err := dl.audioExtension("song.MP3")
+	require.NoError(t, err)
+	assert.Equal(t, ".mp3", ext)
+
+	dl.AudioFormat = "opus"
+	ext, err = dl.audioExtension("song.mp3")
+	require.NoError(t, err)
+	assert.Equal(t, ".opus", ext)
+
+	dl.AudioFormat = "flac"
+	_, err = dl.audioExtension("")
+	assert.EqualError(t, err, "unsupported audio format: flac")
+}
+
+func TestDownloader_DownloadAudio(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("audio")) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	// fake ffmpeg logging its arguments and writing the output, its last argument
+	dir := t.TempDir()
+	log := filepath.Join(dir, "ffmpeg.log")
+	script := "#!/bin/sh\necho \"$*\" > " + log + "\neval out=\\${$#}\necho converted > \"$out\"\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ffmpeg"), []byte(script), 0o755))
+	t.Setenv("PATH", dir)
+
+	formats := append(youtube.FormatList(nil), testAudioFormats...)
+	for i := range formats {
+		formats[i].URL = server.URL
+	}
+	video := &youtube.Video{ID: "BaW_jenozKc", Title: "Song", Formats: formats}
+
+	tests := []struct {
+		audioFormat string
+		wantPath    string
+		wantArgs    string
+	}{
+		{audioFormat: "m4a", wantPath: "Song.m4a", wantArgs: "-vn -c:a copy"},
+		{audioFormat: "opus", wantPath: "Song.opus", wantArgs: "-vn -c:a copy"},
+		{audioFormat: "mp3", wantPath: "Song.mp3", wantArgs: "-vn -c:a libmp3lame -q:a 2"},
+	}
+
+	for _, tt := range tests {
+		dl := Downloader{OutputDir: t.TempDir(), ProgressOutput: io.Discard, AudioFormat: tt.audioFormat}
+
+		result, err := dl.DownloadAudio(context.Background(), "", video, "")
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(dl.OutputDir, tt.wantPath), result.Path)
+		assert.EqualValues(t, 5, result.Bytes)
+
+		args, err := os.ReadFile(log)
+		require.NoError(t, err)
+		assert.Contains(t, string(args), tt.wantArgs+" "+filepath.Join(dl.OutputDir, "youtube_"), tt.audioFormat)
+		assert.FileExists(t, result.Path)
+
+		// the downloaded stream is removed
+		entries, err := os.ReadDir(dl.OutputDir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+	}
+
+	// a failed conversion doesn't leave a file with the final name
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ffmpeg"), []byte(script+"exit 1\n"), 0o755))
+	dl := Downloader{OutputDir: t.TempDir(), ProgressOutput: io.Discard, AudioFormat: "mp3"}
+	_, err := dl.DownloadAudio(context.Background(), "", video, "")
+	require.Error(t, err)
+	entries, err := os.ReadDir(dl.OutputDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// without conversion the stream is kept as it is
+	dl = Downloader{OutputDir: t.TempDir(), ProgressOutput: io.Discard}
+	result, err := dl.DownloadAudio(context.Background(), "", video, "")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dl.OutputDir, "Song.m4a"), result.Path)
+}
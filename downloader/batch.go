@@ -0,0 +1,118 @@
+package downloader
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/vbauerster/mpb/v5"
+	"github.com/vbauerster/mpb/v5/decor"
+)
+
+// BatchProgress is an overall progress bar of several downloads, e.g. of a playlist, see StartBatch
+type BatchProgress struct {
+	dl  *Downloader
+	bar *mpb.Bar // nil without the default progress bar
+
+	files   int
+	done    atomic.Int32
+	unknown atomic.Int32 // number of started streams of unknown size, not in total
+
+	// the decorators must not take mu, the bar is updated while holding it
+	mu    sync.Mutex
+	total int64 // sum of the known sizes of the started streams
+}
+
+// StartBatch renders an overall bar for the next files downloads above the bars of their streams,
+// like "file 3 of 40" with the bytes of all streams started so far.
+// Streams of unknown size are left out of its total and counted apart.
+// The bars of the streams are removed once complete, so only the running ones stay below it.
+// Call FileDone after each download and Finish after the last one.
+// With Progress, ProgressJSON or Silent there is no bar and the BatchProgress does nothing.
+func (dl *Downloader) StartBatch(files int) *BatchProgress {
+	batch := &BatchProgress{dl: dl, files: files}
+	if dl.Progress != nil || dl.ProgressJSON || dl.Silent {
+		return batch
+	}
+
+	batch.bar = dl.addBar(0, mpb.NewBarFiller(mpb.DefaultBarStyle, false),
+		mpb.BarPriority(-1), // above the bars of the streams
+		mpb.PrependDecorators(
+			decor.Any(batch.filesDecorator, decor.WCSyncSpaceR),
+			decor.CountersKibiByte("% .2f / % .2f"),
+		),
+		mpb.AppendDecorators(
+			decor.Any(batch.unknownDecorator),
+		),
+	)
+	// the total grows with the started streams
+	batch.bar.SetTotal(0, false)
+
+	dl.barsMu.Lock()
+	dl.batch = batch
+	dl.barsMu.Unlock()
+
+	return batch
+}
+
+// FileDone counts a finished download, successful or not
+func (b *BatchProgress) FileDone() {
+	b.done.Add(1)
+}
+
+// Finish completes the overall bar
+func (b *BatchProgress) Finish() {
+	if b.bar == nil {
+		return
+	}
+
+	b.dl.barsMu.Lock()
+	if b.dl.batch == b {
+		b.dl.batch = nil
+	}
+	b.dl.barsMu.Unlock()
+
+	b.bar.SetTotal(0, true)
+	b.dl.releaseBar()
+}
+
+// startStream adds the size of a stream to the total, or counts it as unknown
+func (b *BatchProgress) startStream(total int64) {
+	if total <= 0 {
+		b.unknown.Add(1)
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.total += total
+	b.bar.SetTotal(b.total, false)
+}
+
+// add advances the overall bar by the bytes written of a stream of known size
+func (b *BatchProgress) add(n int64) {
+	b.bar.IncrInt64(n)
+}
+
+// failStream removes the bytes a failed stream of known size didn't write from the total
+func (b *BatchProgress) failStream(missing int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.total -= missing
+	b.bar.SetTotal(b.total, false)
+}
+
+func (b *BatchProgress) filesDecorator(decor.Statistics) string {
+	return fmt.Sprintf("file %d of %d", min(int(b.done.Load())+1, b.files), b.files)
+}
+
+func (b *BatchProgress) unknownDecorator(decor.Statistics) string {
+	unknown := b.unknown.Load()
+	if unknown == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(" + %d streams of unknown size", unknown)
+}
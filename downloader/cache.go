@@ -0,0 +1,184 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// DefaultCacheTTL is how long videos are cached without CacheTTL
+const DefaultCacheTTL = time.Hour
+
+// cacheVersion is raised on incompatible changes of the cache files, older files are ignored
+const cacheVersion = 1
+
+// cachedVideo is the content of a cache file
+type cachedVideo struct {
+	Version int            `json:"version"`
+	Fetched time.Time      `json:"fetched"`
+	Video   *youtube.Video `json:"video"`
+}
+
+// GetVideoCached is GetVideoContext with the videos cached in CacheDir, spaced out by RequestInterval.
+// A video cached less than CacheTTL ago is returned without any request,
+// unless the signed stream URLs expired or a download of one of its streams was forbidden since.
+// Without CacheDir it only calls GetVideoContext.
+func (dl *Downloader) GetVideoCached(ctx context.Context, url string) (*youtube.Video, error) {
+	if dl.CacheDir == "" {
+		return dl.fetchVideo(ctx, url)
+	}
+
+	id, err := youtube.ExtractVideoID(url)
+	if err != nil {
+		return nil, err
+	}
+
+	if video := dl.loadCachedVideo(id); video != nil {
+		youtube.Logger.Debug("using the cached video", "id", id)
+		return video, nil
+	}
+
+	video, err := dl.fetchVideo(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = dl.storeCachedVideo(video); err != nil {
+		youtube.Logger.Warn("caching the video failed", "id", id, "error", err)
+	}
+
+	return video, nil
+}
+
+// fetchVideo is GetVideoContext after waiting for RequestInterval
+func (dl *Downloader) fetchVideo(ctx context.Context, url string) (*youtube.Video, error) {
+	if err := dl.waitRequest(ctx); err != nil {
+		return nil, err
+	}
+
+	return dl.GetVideoContext(ctx, url)
+}
+
+// loadCachedVideo returns the cached video of the ID, or nil if it isn't cached or its entry is no longer valid
+func (dl *Downloader) loadCachedVideo(id string) *youtube.Video {
+	data, err := os.ReadFile(dl.cacheFile(id))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			youtube.Logger.Warn("reading the cached video failed", "id", id, "error", err)
+		}
+		return nil
+	}
+
+	var entry cachedVideo
+	if err = json.Unmarshal(data, &entry); err != nil || entry.Version != cacheVersion || entry.Video == nil {
+		youtube.Logger.Debug("ignoring an invalid cache entry", "id", id, "error", err)
+		return nil
+	}
+
+	ttl := dl.CacheTTL
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+
+	if time.Since(entry.Fetched) > ttl {
+		return nil
+	}
+
+	if expires := streamsExpire(entry.Video); !expires.IsZero() && time.Until(expires) < streamExpiryMargin {
+		youtube.Logger.Debug("the stream URLs of the cached video expire", "id", id, "expires", expires)
+		return nil
+	}
+
+	return entry.Video
+}
+
+func (dl *Downloader) storeCachedVideo(video *youtube.Video) error {
+	data, err := json.Marshal(cachedVideo{Version: cacheVersion, Fetched: time.Now(), Video: video})
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(dl.CacheDir, 0o755); err != nil {
+		return err
+	}
+
+	// written to a temporary file first, concurrent readers never see half of it
+	tmpFile, err := os.CreateTemp(dl.CacheDir, "youtube_*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err = tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err = tmpFile.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), dl.cacheFile(video.ID))
+}
+
+// invalidateCachedVideo removes the cached video of the ID, e.g. after its stream URLs were forbidden
+func (dl *Downloader) invalidateCachedVideo(id string) {
+	if dl.CacheDir == "" {
+		return
+	}
+
+	err := os.Remove(dl.cacheFile(id))
+	if err == nil {
+		youtube.Logger.Info("removed the cached video, its streams are forbidden", "id", id)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		youtube.Logger.Warn("removing the cached video failed", "id", id, "error", err)
+	}
+}
+
+func (dl *Downloader) cacheFile(id string) string {
+	return filepath.Join(dl.CacheDir, id+".json")
+}
+
+// streamExpiryMargin is how long the stream URLs of a cached video must stay valid, so a download doesn't fail midway
+const streamExpiryMargin = 30 * time.Minute
+
+// streamsExpire returns when the first of the signed stream URLs of the video expires, by their expire parameter,
+// or the zero time if none has one
+func streamsExpire(video *youtube.Video) time.Time {
+	var first time.Time
+
+	for _, format := range video.Formats {
+		streamURL := format.URL
+		if streamURL == "" {
+			// the URL of a cipher is its url parameter
+			cipher, err := url.ParseQuery(format.Cipher)
+			if err != nil {
+				continue
+			}
+			streamURL = cipher.Get("url")
+		}
+
+		u, err := url.Parse(streamURL)
+		if err != nil {
+			continue
+		}
+
+		seconds, err := strconv.ParseInt(u.Query().Get("expire"), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		if expires := time.Unix(seconds, 0); first.IsZero() || expires.Before(first) {
+			first = expires
+		}
+	}
+
+	return first
+}
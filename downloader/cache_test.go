@@ -0,0 +1,107 @@
+package downloader
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func TestDownloader_GetVideoCached(t *testing.T) {
+	expire := strconv.FormatInt(time.Now().Add(6*time.Hour).Unix(), 10)
+	transport := &playerTransport{response: `{
+		"playabilityStatus": {"status": "OK"},
+		"videoDetails": {"videoId": "BaW_jenozKc", "title": "Title"},
+		"streamingData": {"adaptiveFormats": [
+			{"itag": 137, "url": "https://example.com/videoplayback?expire=` + expire + `", "mimeType": "video/mp4", "contentLength": "10"}
+		]}
+	}`}
+
+	dl := Downloader{CacheDir: filepath.Join(t.TempDir(), "cache")}
+	dl.HTTPClient = &http.Client{Transport: transport}
+
+	video, err := dl.GetVideoCached(context.Background(), "https://youtu.be/BaW_jenozKc")
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, transport.players.Load())
+	assert.FileExists(t, filepath.Join(dl.CacheDir, "BaW_jenozKc.json"))
+
+	cached, err := dl.GetVideoCached(context.Background(), "BaW_jenozKc")
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, transport.players.Load(), "cached")
+	assert.Equal(t, video, cached)
+
+	dl.CacheTTL = time.Nanosecond
+	_, err = dl.GetVideoCached(context.Background(), "BaW_jenozKc")
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, transport.players.Load(), "outlived the TTL")
+
+	dl.CacheTTL = 0
+	dl.invalidateCachedVideo("BaW_jenozKc")
+	assert.NoFileExists(t, filepath.Join(dl.CacheDir, "BaW_jenozKc.json"))
+
+	dl.CacheDir = ""
+	_, err = dl.GetVideoCached(context.Background(), "BaW_jenozKc")
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, transport.players.Load(), "no cache")
+}
+
+func TestDownloader_loadCachedVideo_expiredStreams(t *testing.T) {
+	dl := Downloader{CacheDir: t.TempDir()}
+
+	expire := strconv.FormatInt(time.Now().Add(10*time.Minute).Unix(), 10)
+	video := &youtube.Video{ID: "BaW_jenozKc", Formats: youtube.FormatList{
+		{ItagNo: 137, URL: "https://example.com/videoplayback?expire=" + expire},
+	}}
+	require.NoError(t, dl.storeCachedVideo(video))
+	assert.Nil(t, dl.loadCachedVideo(video.ID), "the streams expire too soon")
+
+	video.Formats[0].URL = "https://example.com/videoplayback"
+	require.NoError(t, dl.storeCachedVideo(video))
+	assert.Equal(t, video, dl.loadCachedVideo(video.ID), "no expiry")
+
+	require.NoError(t, os.WriteFile(dl.cacheFile(video.ID), []byte(`{"version": 0}`), 0o644))
+	assert.Nil(t, dl.loadCachedVideo(video.ID), "old version")
+}
+
+func Test_streamsExpire(t *testing.T) {
+	first := time.Unix(1700000000, 0)
+
+	video := &youtube.Video{Formats: youtube.FormatList{
+		{URL: "https://example.com/videoplayback?expire=1700003600"},
+		{Cipher: "s=abc&sp=sig&url=" + url.QueryEscape("https://example.com/videoplayback?expire=1700000000")},
+		{URL: "https://example.com/videoplayback"},
+	}}
+	assert.Equal(t, first, streamsExpire(video))
+	assert.True(t, streamsExpire(&youtube.Video{}).IsZero())
+}
+
+func TestDownloader_videoDLWorker_invalidatesCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	video := &youtube.Video{ID: "BaW_jenozKc", Formats: youtube.FormatList{{ItagNo: 18, URL: server.URL}}}
+
+	dl := Downloader{ProgressOutput: io.Discard, CacheDir: t.TempDir()}
+	require.NoError(t, dl.storeCachedVideo(video))
+
+	out, err := os.Create(filepath.Join(t.TempDir(), "video.mp4"))
+	require.NoError(t, err)
+	defer out.Close()
+
+	_, err = dl.videoDLWorker(context.Background(), out, video, &video.Formats[0])
+	assert.ErrorIs(t, err, youtube.ErrUnexpectedStatusCode(http.StatusForbidden))
+	assert.NoFileExists(t, dl.cacheFile(video.ID))
+}
@@ -0,0 +1,170 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// Captions formats, see Downloader.CaptionsFormat
+const (
+	CaptionsVTT = "vtt"
+	CaptionsSRT = "srt"
+)
+
+// DownloadCaptions downloads the captions of the given language in CaptionsFormat.
+// Without an outputFile the file is named after the video title and the language.
+// If the video has no captions in the language, ErrCaptionsUnavailable lists the available ones.
+func (dl *Downloader) DownloadCaptions(ctx context.Context, v *youtube.Video, lang string, outputFile string) error {
+	track := findCaptionTrack(v.CaptionTracks, lang)
+	if track == nil {
+		return &ErrCaptionsUnavailable{Requested: lang, Available: captionLanguages(v.CaptionTracks)}
+	}
+
+	return dl.downloadCaptionTrack(ctx, v, track, "", outputFile)
+}
+
+// DownloadTranslatedCaptions downloads captions auto-translated by YouTube to targetLang.
+// The captions of baseLang are translated, or the first translatable track if baseLang is empty.
+func (dl *Downloader) DownloadTranslatedCaptions(ctx context.Context, v *youtube.Video, baseLang, targetLang string, outputFile string) error {
+	var track *youtube.CaptionTrack
+
+	if baseLang != "" {
+		track = findCaptionTrack(v.CaptionTracks, baseLang)
+		if track == nil {
+			return &ErrCaptionsUnavailable{Requested: baseLang, Available: captionLanguages(v.CaptionTracks)}
+		}
+		if !track.IsTranslatable {
+			return fmt.Errorf("captions for language %s can't be translated", baseLang)
+		}
+	} else {
+		track = findTranslatableTrack(v.CaptionTracks)
+		if track == nil {
+			return fmt.Errorf("no translatable captions found")
+		}
+	}
+
+	return dl.downloadCaptionTrack(ctx, v, track, targetLang, outputFile)
+}
+
+func (dl *Downloader) downloadCaptionTrack(ctx context.Context, v *youtube.Video, track *youtube.CaptionTrack, translateTo string, outputFile string) error {
+	lang := track.LanguageCode
+	if translateTo != "" {
+		lang = translateTo
+	}
+
+	format := dl.captionsFormat()
+
+	youtube.Logger.Info(
+		"Downloading captions",
+		"id", v.ID,
+		"lang", lang,
+		"kind", track.Kind,
+		"format", format,
+	)
+
+	if outputFile == "" {
+		outputFile = SanitizeFilename(v.Title) + "." + lang + "." + format
+	}
+
+	destFile, err := dl.joinOutputDir(outputFile)
+	if err != nil {
+		return err
+	}
+
+	captionURL, err := getCaptionURL(track.BaseURL, translateTo)
+	if err != nil {
+		return err
+	}
+
+	data, err := dl.httpGetBodyBytes(ctx, captionURL)
+	if err != nil {
+		return err
+	}
+
+	if format == CaptionsSRT {
+		data = vttToSRT(data)
+	}
+
+	return os.WriteFile(destFile, data, 0o644)
+}
+
+func (dl *Downloader) captionsFormat() string {
+	if dl.CaptionsFormat == CaptionsSRT {
+		return CaptionsSRT
+	}
+
+	return CaptionsVTT
+}
+
+// captionLanguages returns the distinct language codes of the tracks
+func captionLanguages(tracks []youtube.CaptionTrack) []string {
+	var langs []string
+
+	for _, track := range tracks {
+		if !slices.Contains(langs, track.LanguageCode) {
+			langs = append(langs, track.LanguageCode)
+		}
+	}
+
+	return langs
+}
+
+// findCaptionTrack returns the track of the given language, manual captions are preferred over generated ones
+func findCaptionTrack(tracks []youtube.CaptionTrack, lang string) *youtube.CaptionTrack {
+	var result *youtube.CaptionTrack
+
+	for i := range tracks {
+		if tracks[i].LanguageCode != lang {
+			continue
+		}
+		if tracks[i].Kind != "asr" {
+			return &tracks[i]
+		}
+		if result == nil {
+			result = &tracks[i]
+		}
+	}
+
+	return result
+}
+
+// findTranslatableTrack returns the first translatable track, manual captions are preferred over generated ones
+func findTranslatableTrack(tracks []youtube.CaptionTrack) *youtube.CaptionTrack {
+	var result *youtube.CaptionTrack
+
+	for i := range tracks {
+		if !tracks[i].IsTranslatable {
+			continue
+		}
+		if tracks[i].Kind != "asr" {
+			return &tracks[i]
+		}
+		if result == nil {
+			result = &tracks[i]
+		}
+	}
+
+	return result
+}
+
+// getCaptionURL returns the timedtext URL of a track in WebVTT format, optionally translated
+func getCaptionURL(baseURL string, translateTo string) (string, error) {
+	uri, err := url.Parse(baseURL)
+	if err != nil {
+		return "", err
+	}
+
+	query := uri.Query()
+	query.Set("fmt", "vtt")
+	if translateTo != "" {
+		query.Set("tlang", translateTo)
+	}
+	uri.RawQuery = query.Encode()
+
+	return uri.String(), nil
+}
@@ -0,0 +1,68 @@
+package downloader
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+var testCaptionTracks = []youtube.CaptionTrack{
+	{LanguageCode: "en", Kind: "asr", IsTranslatable: true, VssID: "a.en"},
+	{LanguageCode: "de", VssID: ".de"},
+	{LanguageCode: "en", IsTranslatable: true, VssID: ".en"},
+}
+
+func TestFindCaptionTrack(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(".en", findCaptionTrack(testCaptionTracks, "en").VssID)
+	assert.Equal(".de", findCaptionTrack(testCaptionTracks, "de").VssID)
+	assert.Equal("a.en", findCaptionTrack(testCaptionTracks[:1], "en").VssID)
+	assert.Nil(findCaptionTrack(testCaptionTracks, "fr"))
+}
+
+func TestFindTranslatableTrack(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(".en", findTranslatableTrack(testCaptionTracks).VssID)
+	assert.Nil(findTranslatableTrack(testCaptionTracks[1:2]))
+}
+
+func TestGetCaptionURL(t *testing.T) {
+	require := require.New(t)
+
+	uri, err := getCaptionURL("https://www.youtube.com/api/timedtext?v=BaW_jenozKc&lang=en", "")
+	require.NoError(err)
+	require.Equal("https://www.youtube.com/api/timedtext?fmt=vtt&lang=en&v=BaW_jenozKc", uri)
+
+	uri, err = getCaptionURL("https://www.youtube.com/api/timedtext?v=BaW_jenozKc&lang=en", "de")
+	require.NoError(err)
+	require.Equal("https://www.youtube.com/api/timedtext?fmt=vtt&lang=en&tlang=de&v=BaW_jenozKc", uri)
+}
+
+func TestCaptionLanguages(t *testing.T) {
+	assert.Equal(t, []string{"en", "de"}, captionLanguages(testCaptionTracks))
+	assert.Nil(t, captionLanguages(nil))
+}
+
+func TestErrCaptionsUnavailable(t *testing.T) {
+	err := ErrCaptionsUnavailable{Requested: "fr", Available: []string{"en", "de"}}
+	assert.Equal(t, "no captions found for language fr, available: en, de", err.Error())
+
+	err = ErrCaptionsUnavailable{Requested: "fr"}
+	assert.Equal(t, "no captions found for language fr, the video has no captions", err.Error())
+}
+
+func TestVttToSRT(t *testing.T) {
+	vtt := "WEBVTT\r\nKind: captions\r\nLanguage: en\r\n\r\n" +
+		"NOTE generated\r\n\r\n" +
+		"intro\r\n01:02.500 --> 01:04.000 align:start position:0%\r\nhello<00:01:03.000><c> world</c>\r\n \r\n\r\n" +
+		"00:01:04.000 --> 00:01:04.010 align:start position:0%\r\n \r\n\r\n" +
+		"01:00:04.010 --> 01:00:06.000\r\nfish &amp; chips\r\nsecond line\r\n"
+
+	assert.Equal(t, "1\n00:01:02,500 --> 00:01:04,000\nhello world\n\n"+
+		"2\n01:00:04,010 --> 01:00:06,000\nfish & chips\nsecond line\n\n", string(vttToSRT([]byte(vtt))))
+}
@@ -0,0 +1,83 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// writeChaptersFile writes the chapters of the video as ffmpeg metadata file into dir and returns its path,
+// or "" if EmbedChapters is disabled or the video has no chapters
+func (dl *Downloader) writeChaptersFile(v *youtube.Video, dir string) (string, error) {
+	if !dl.EmbedChapters {
+		return "", nil
+	}
+
+	if len(v.Chapters) == 0 {
+		youtube.Logger.Info("the video has no chapters, not embedding them", "id", v.ID)
+		return "", nil
+	}
+
+	file, err := os.CreateTemp(dir, "youtube_*.chapters.txt")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err = file.WriteString(chaptersMetadata(v.Chapters)); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+
+	return file.Name(), file.Close()
+}
+
+// embedChapters writes the chapters of the video into the file with an extra ffmpeg pass, see EmbedChapters
+func (dl *Downloader) embedChapters(ctx context.Context, v *youtube.Video, path string) error {
+	dir, err := dl.getTempDir(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+
+	chaptersFile, err := dl.writeChaptersFile(v, dir)
+	if err != nil || chaptersFile == "" {
+		return err
+	}
+	defer dl.removeIntermediate(chaptersFile)
+
+	youtube.Logger.Debug("embedding chapters", "path", path, "chapters", len(v.Chapters))
+
+	return dl.rewriteWithFFmpeg(ctx, path, "-i", chaptersFile, "-map", "0", "-map_chapters", "1", "-c", "copy")
+}
+
+// chaptersMetadata formats the chapters in the ffmpeg metadata format, with millisecond timestamps
+func chaptersMetadata(chapters []youtube.Chapter) string {
+	var b strings.Builder
+
+	b.WriteString(";FFMETADATA1\n")
+
+	for _, chapter := range chapters {
+		b.WriteString("\n[CHAPTER]\nTIMEBASE=1/1000\n")
+		b.WriteString("START=" + strconv.FormatInt(chapter.Start.Milliseconds(), 10) + "\n")
+		b.WriteString("END=" + strconv.FormatInt(chapter.End.Milliseconds(), 10) + "\n")
+		b.WriteString("title=" + escapeFFMetadata(chapter.Title) + "\n")
+	}
+
+	return b.String()
+}
+
+// escapeFFMetadata makes the value a single line and escapes the characters special to the ffmpeg metadata format
+func escapeFFMetadata(value string) string {
+	value = strings.Join(strings.Fields(cleanMetadataValue(value)), " ")
+
+	return strings.NewReplacer(
+		`\`, `\\`,
+		"=", `\=`,
+		";", `\;`,
+		"#", `\#`,
+	).Replace(value)
+}
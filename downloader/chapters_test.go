@@ -0,0 +1,56 @@
+package downloader
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func TestChaptersMetadata(t *testing.T) {
+	chapters := []youtube.Chapter{
+		{Title: "Intro", Start: 0, End: 65 * time.Second},
+		{Title: "Q&A; part=1 #2 \\o/\nmore", Start: 65 * time.Second, End: 90*time.Second + 500*time.Millisecond},
+	}
+
+	assert.Equal(t, `;FFMETADATA1
+
+[CHAPTER]
+TIMEBASE=1/1000
+START=0
+END=65000
+title=Intro
+
+[CHAPTER]
+TIMEBASE=1/1000
+START=65000
+END=90500
+title=Q&A\; part\=1 \#2 \\o/ more
+`, chaptersMetadata(chapters))
+}
+
+func TestDownloader_writeChaptersFile(t *testing.T) {
+	dir := t.TempDir()
+	video := &youtube.Video{ID: "BaW_jenozKc", Chapters: []youtube.Chapter{{Title: "Intro", End: time.Minute}}}
+
+	dl := Downloader{}
+	path, err := dl.writeChaptersFile(video, dir)
+	require.NoError(t, err)
+	assert.Empty(t, path, "disabled")
+
+	dl.EmbedChapters = true
+	path, err = dl.writeChaptersFile(&youtube.Video{ID: "BaW_jenozKc"}, dir)
+	require.NoError(t, err)
+	assert.Empty(t, path, "no chapters")
+
+	path, err = dl.writeChaptersFile(video, dir)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, chaptersMetadata(video.Chapters), string(data))
+}
@@ -0,0 +1,131 @@
+package downloader
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// chunkStateSuffix is appended to the name of the output of a concurrent download for its ChunkState file
+const chunkStateSuffix = ".state"
+
+// chunkStateSize is the size of the parts of a concurrent download with ChunkState,
+// which an interruption loses at most per running part
+const chunkStateSize = youtube.Size10Mb
+
+// chunkState is the content of a ChunkState file
+type chunkState struct {
+	ContentLength int64  `json:"content_length"`
+	Parts         int    `json:"parts"`
+	Done          []byte `json:"done"` // bitmap of the finished parts by index
+}
+
+// chunkStateFile records the finished parts of a concurrent download in the file next to its output.
+// Its methods do nothing on a nil chunkStateFile, the one of downloads without ChunkState.
+type chunkStateFile struct {
+	mu    sync.Mutex
+	path  string
+	state chunkState
+}
+
+// chunkStatePath returns the path of the ChunkState file of the output
+func chunkStatePath(output string) string {
+	return output + chunkStateSuffix
+}
+
+// resumable reports whether the finished parts of a concurrent download into out are recorded,
+// which are the ".part" outputs of Download with ChunkState
+func (dl *Downloader) resumable(out *os.File) bool {
+	return dl.ChunkState && out != os.Stdout && strings.HasSuffix(out.Name(), partialSuffix)
+}
+
+// openChunkState returns the state of the concurrent download of the parts into out, or nil if it isn't resumable.
+// The state of an interrupted download of the same parts is resumed if out still has their size,
+// otherwise out is truncated to the size and the download starts over.
+func (dl *Downloader) openChunkState(out *os.File, contentLength int64, parts int) (*chunkStateFile, error) {
+	if !dl.resumable(out) {
+		return nil, nil
+	}
+
+	file := &chunkStateFile{path: chunkStatePath(out.Name())}
+
+	if data, err := os.ReadFile(file.path); err == nil {
+		info, err := out.Stat()
+		if json.Unmarshal(data, &file.state) == nil && err == nil && info.Size() == contentLength &&
+			file.state.ContentLength == contentLength && file.state.Parts == parts && len(file.state.Done) == (parts+7)/8 {
+			youtube.Logger.Info("resuming the download", "path", out.Name(), "finished", file.finished(), "parts", parts)
+			return file, nil
+		}
+	}
+
+	// the parts are written at their offsets
+	if err := out.Truncate(contentLength); err != nil {
+		return nil, err
+	}
+
+	file.state = chunkState{ContentLength: contentLength, Parts: parts, Done: make([]byte, (parts+7)/8)}
+
+	return file, file.save()
+}
+
+// done reports whether the part of the index is finished
+func (f *chunkStateFile) done(i int) bool {
+	if f == nil {
+		return false
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.state.Done[i/8]&(1<<(i%8)) != 0
+}
+
+// finished returns the number of finished parts
+func (f *chunkStateFile) finished() int {
+	var n int
+	for i := 0; i < f.state.Parts; i++ {
+		if f.state.Done[i/8]&(1<<(i%8)) != 0 {
+			n++
+		}
+	}
+
+	return n
+}
+
+// finish records the part of the index as finished
+func (f *chunkStateFile) finish(i int) error {
+	if f == nil {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.state.Done[i/8] |= 1 << (i % 8)
+
+	return f.save()
+}
+
+// save writes the state to a temporary file replacing the state file, so an interruption doesn't leave half of it
+func (f *chunkStateFile) save() error {
+	data, err := json.Marshal(f.state)
+	if err != nil {
+		return err
+	}
+
+	if err = os.WriteFile(f.path+partialSuffix, data, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(f.path+partialSuffix, f.path)
+}
+
+// remove removes the state file of a complete download, or one that can't be resumed
+func (f *chunkStateFile) remove() {
+	if f != nil {
+		os.Remove(f.path)
+	}
+}
@@ -0,0 +1,123 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func TestDownloader_Download_ChunkState(t *testing.T) {
+	const content = "0123456789abcdefghij"
+
+	var (
+		mu        sync.Mutex
+		requested []string
+		failing   = "10-14"
+		served    sync.WaitGroup
+	)
+	served.Add(3)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rng := r.URL.Query().Get("range")
+		if rng == failing {
+			// fail once the other parts are served
+			served.Wait()
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		mu.Lock()
+		requested = append(requested, rng)
+		mu.Unlock()
+		if failing != "" {
+			defer served.Done()
+		}
+
+		var start, end int
+		_, err := fmt.Sscanf(rng, "%d-%d", &start, &end)
+		require.NoError(t, err)
+		w.Write([]byte(content[start : end+1])) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	video := &youtube.Video{ID: "BaW_jenozKc", Title: "Title"}
+	format := &youtube.Format{URL: server.URL, MimeType: "video/mp4", ContentLength: int64(len(content))}
+
+	dl := Downloader{OutputDir: t.TempDir(), ProgressOutput: io.Discard, Concurrency: 4, ChunkState: true}
+	path := filepath.Join(dl.OutputDir, "Title.mp4")
+
+	_, err := dl.Download(context.Background(), video, format, "")
+	require.Error(t, err)
+	assert.NoFileExists(t, path)
+	assert.FileExists(t, path+".part")
+	assert.FileExists(t, path+".part.state")
+
+	// the failure cancels the parts still running, the resumed download requests them and the failed one
+	out, err := os.Open(path + ".part")
+	require.NoError(t, err)
+	state, err := dl.openChunkState(out, format.ContentLength, 4)
+	out.Close()
+	require.NoError(t, err)
+
+	var missing []string
+	for i, p := range getParts(format.ContentLength, 4) {
+		if !state.done(i) {
+			missing = append(missing, fmt.Sprintf("%d-%d", p.start, p.end))
+		}
+	}
+	assert.Contains(t, missing, "10-14")
+
+	requested, failing = nil, ""
+
+	result, err := dl.Download(context.Background(), video, format, "")
+	require.NoError(t, err)
+	assert.Equal(t, path, result.Path)
+	assert.ElementsMatch(t, missing, requested)
+	assert.NoFileExists(t, path+".part")
+	assert.NoFileExists(t, path+".part.state")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data))
+}
+
+func TestDownloader_openChunkState_mismatch(t *testing.T) {
+	out, err := os.Create(filepath.Join(t.TempDir(), "Title.mp4.part"))
+	require.NoError(t, err)
+	defer out.Close()
+
+	dl := Downloader{ChunkState: true}
+	state, err := dl.openChunkState(out, 20, 4)
+	require.NoError(t, err)
+	require.NoError(t, state.finish(2))
+
+	state, err = dl.openChunkState(out, 20, 4)
+	require.NoError(t, err)
+	assert.True(t, state.done(2))
+	assert.False(t, state.done(1))
+
+	// the state of other parts starts over
+	state, err = dl.openChunkState(out, 20, 5)
+	require.NoError(t, err)
+	assert.False(t, state.done(2))
+
+	// outputs other than the ".part" files of Download aren't resumed
+	other, err := os.Create(filepath.Join(t.TempDir(), "youtube_1.mp4"))
+	require.NoError(t, err)
+	defer other.Close()
+
+	state, err = dl.openChunkState(other, 20, 4)
+	require.NoError(t, err)
+	assert.Nil(t, state)
+}
@@ -0,0 +1,104 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// DownloadClip downloads the stream of the format and cuts the range from start to end out of it with ffmpeg.
+// The streams are copied, so the clip begins at the keyframe before start.
+func (dl *Downloader) DownloadClip(ctx context.Context, outputFile string, v *youtube.Video, format *youtube.Format, start, end time.Duration) (*DownloadResult, error) {
+	if err := checkClipRange(v, start, end); err != nil {
+		return nil, err
+	}
+
+	if outputFile == Stdout {
+		return nil, fmt.Errorf("%w, clips are cut out of a seekable temporary file", ErrStdoutUnsupported)
+	}
+
+	started := time.Now()
+
+	youtube.Logger.Info(
+		"Downloading clip",
+		"id", v.ID,
+		"quality", format.Quality,
+		"mimeType", format.MimeType,
+		"from", start,
+		"to", end,
+	)
+
+	destFile, err := dl.getOutputFile(ctx, v, format, outputFile)
+	if err != nil {
+		return nil, err
+	}
+	defer dl.releaseName(destFile)
+
+	if dl.skipExisting(destFile, 0) {
+		return dl.complete(&DownloadResult{Path: destFile, Itag: format.ItagNo, Skipped: true, Elapsed: time.Since(started)})
+	}
+
+	written, err := dl.convertStream(ctx, v, format, destFile, func(input, output string) []string {
+		return clipArgs(input, output, start, end)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the clip may begin before start, at a keyframe
+	var minDuration time.Duration
+	if !dl.TestMode {
+		minDuration = end - start
+	}
+	if err = dl.verifyMedia(ctx, destFile, minDuration, 0); err != nil {
+		return nil, err
+	}
+
+	destFile, err = dl.runPostProcessors(ctx, v, destFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return dl.complete(&DownloadResult{
+		Path:    destFile,
+		Itag:    format.ItagNo,
+		Bytes:   written,
+		Elapsed: time.Since(started),
+	})
+}
+
+// checkClipRange checks the range from start to end is a part of the video
+func checkClipRange(v *youtube.Video, start, end time.Duration) error {
+	switch {
+	case start < 0:
+		return fmt.Errorf("clip start %s is negative", start)
+	case end <= start:
+		return fmt.Errorf("clip end %s is not after its start %s", end, start)
+	case v.Duration > 0 && end > v.Duration:
+		return fmt.Errorf("clip end %s is beyond the duration %s of the video", end, v.Duration)
+	}
+
+	return nil
+}
+
+// clipArgs returns the ffmpeg arguments for copying the range from start to end of the input
+func clipArgs(input, output string, start, end time.Duration) []string {
+	return []string{
+		"-y",
+		"-loglevel", "warning",
+		"-ss", ffmpegDuration(start),
+		"-i", input,
+		"-t", ffmpegDuration(end - start),
+		"-map", "0",
+		"-c", "copy",
+		output,
+	}
+}
+
+// ffmpegDuration formats a duration as seconds for ffmpeg
+func ffmpegDuration(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
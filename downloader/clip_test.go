@@ -0,0 +1,102 @@
+package downloader
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func Test_checkClipRange(t *testing.T) {
+	video := &youtube.Video{Duration: time.Minute}
+
+	tests := []struct {
+		name       string
+		start, end time.Duration
+		wantErr    string
+	}{
+		{name: "valid", start: 10 * time.Second, end: 40 * time.Second},
+		{name: "until the end", start: 0, end: time.Minute},
+		{name: "negative", start: -time.Second, end: time.Second, wantErr: "clip start -1s is negative"},
+		{name: "empty", start: 10 * time.Second, end: 10 * time.Second, wantErr: "clip end 10s is not after its start 10s"},
+		{name: "reversed", start: 20 * time.Second, end: 10 * time.Second, wantErr: "is not after its start"},
+		{name: "too long", start: 0, end: 2 * time.Minute, wantErr: "clip end 2m0s is beyond the duration 1m0s of the video"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkClipRange(video, tt.start, tt.end)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+
+	// videos of unknown duration are not limited
+	assert.NoError(t, checkClipRange(&youtube.Video{}, 0, time.Hour))
+}
+
+func Test_clipArgs(t *testing.T) {
+	args := clipArgs("in.mp4", "out.mp4", 90*time.Second, 120500*time.Millisecond)
+	assert.Equal(t, []string{"-y", "-loglevel", "warning", "-ss", "90.000", "-i", "in.mp4", "-t", "30.500", "-map", "0", "-c", "copy", "out.mp4"}, args)
+}
+
+func TestDownloader_DownloadClip(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("video")) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	// fake ffmpeg logging its arguments and writing the output, its last argument
+	dir := t.TempDir()
+	log := filepath.Join(dir, "ffmpeg.log")
+	script := "#!/bin/sh\necho \"$*\" > " + log + "\neval out=\\${$#}\necho converted > \"$out\"\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ffmpeg"), []byte(script), 0o755))
+	t.Setenv("PATH", dir)
+
+	video := &youtube.Video{ID: "BaW_jenozKc", Title: "Talk", Duration: time.Hour}
+	format := &youtube.Format{URL: server.URL, MimeType: "video/mp4"}
+
+	dl := Downloader{OutputDir: t.TempDir(), ProgressOutput: io.Discard}
+	result, err := dl.DownloadClip(context.Background(), "", video, format, time.Minute, 2*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dl.OutputDir, "Talk.mp4"), result.Path)
+	assert.EqualValues(t, 5, result.Bytes)
+
+	args, err := os.ReadFile(log)
+	require.NoError(t, err)
+	assert.Contains(t, string(args), "-ss 60.000 -i ")
+	assert.Contains(t, string(args), "-t 60.000 -map 0 -c copy "+filepath.Join(dl.OutputDir, "youtube_"))
+
+	data, err := os.ReadFile(result.Path)
+	require.NoError(t, err)
+	assert.Equal(t, "converted\n", string(data))
+
+	_, err = dl.DownloadClip(context.Background(), "", video, format, 2*time.Minute, time.Minute)
+	assert.ErrorContains(t, err, "is not after its start")
+
+	// a failed clip doesn't leave a file with the final name
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ffmpeg"), []byte(script+"exit 1\n"), 0o755))
+	dl.OutputDir = t.TempDir()
+	_, err = dl.DownloadClip(context.Background(), "", video, format, time.Minute, 2*time.Minute)
+	require.Error(t, err)
+	entries, err := os.ReadDir(dl.OutputDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,105 @@
+package downloader
+
+import (
+	"mime"
+	"slices"
+	"strings"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// codecNames maps the codec identifiers of mime types to the names of VideoCodec and AudioCodec
+var codecNames = map[string]string{
+	"avc1": "h264",
+	"av01": "av1",
+	"vp09": "vp9",
+	"mp4a": "aac",
+}
+
+// FilterCodecs reduces the formats to the ones with the codecs VideoCodec and AudioCodec.
+// Formats without a video or audio stream are not filtered by its codec, e.g. audio-only formats by VideoCodec.
+func (dl *Downloader) FilterCodecs(formats youtube.FormatList) (youtube.FormatList, error) {
+	formats, err := filterCodec(formats, "video", dl.VideoCodec)
+	if err != nil {
+		return nil, err
+	}
+
+	return filterCodec(formats, "audio", dl.AudioCodec)
+}
+
+// filterCodec keeps the formats whose stream of the kind, video or audio, has the codec
+func filterCodec(formats youtube.FormatList, kind, codec string) (youtube.FormatList, error) {
+	if codec == "" {
+		return formats, nil
+	}
+
+	codec = codecName(strings.ToLower(codec))
+
+	var (
+		result    youtube.FormatList
+		available []string
+		matched   bool
+	)
+
+	for _, format := range formats {
+		videoCodec, audioCodec := formatCodecs(&format)
+		actual := videoCodec
+		if kind == "audio" {
+			actual = audioCodec
+		}
+
+		switch {
+		case actual == "":
+			result = append(result, format)
+		case actual == codec:
+			result = append(result, format)
+			matched = true
+		case !slices.Contains(available, actual):
+			available = append(available, actual)
+		}
+	}
+
+	if !matched && len(available) > 0 {
+		return nil, &ErrCodecUnavailable{
+			Kind:      kind,
+			Requested: codec,
+			Available: available,
+		}
+	}
+
+	return result, nil
+}
+
+// formatCodecs returns the names of the video and audio codec of the format, e.g. "h264" and "aac",
+// or "" for a stream the format doesn't have
+func formatCodecs(format *youtube.Format) (video, audio string) {
+	_, video, audio = formatCodecIDs(format)
+
+	return codecName(video), codecName(audio)
+}
+
+// formatCodecIDs returns the media type of the format and the codec identifiers of its video and audio,
+// e.g. "video/mp4", "avc1.640028" and "mp4a.40.2", or "" for a stream the format doesn't have
+func formatCodecIDs(format *youtube.Format) (mediaType, video, audio string) {
+	mediaType, params, err := mime.ParseMediaType(format.MimeType)
+	if err != nil {
+		return "", "", ""
+	}
+
+	first, second, _ := strings.Cut(params["codecs"], ",")
+	if strings.HasPrefix(mediaType, "audio/") {
+		return mediaType, "", strings.TrimSpace(first)
+	}
+
+	return mediaType, strings.TrimSpace(first), strings.TrimSpace(second)
+}
+
+// codecName returns the name of a codec identifier without its profile, e.g. "h264" for "avc1.640028"
+func codecName(codec string) string {
+	id, _, _ := strings.Cut(strings.TrimSpace(codec), ".")
+	if name, ok := codecNames[id]; ok {
+		return name
+	}
+
+	return id
+}
@@ -0,0 +1,80 @@
+package downloader
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func Test_formatCodecs(t *testing.T) {
+	tests := []struct {
+		mimeType     string
+		video, audio string
+	}{
+		{`video/mp4; codecs="avc1.42001E, mp4a.40.2"`, "h264", "aac"},
+		{`video/mp4; codecs="av01.0.08M.08"`, "av1", ""},
+		{`video/mp4; codecs="vp09.00.40.08"`, "vp9", ""},
+		{`video/webm; codecs="vp9"`, "vp9", ""},
+		{`audio/webm; codecs="opus"`, "", "opus"},
+		{`audio/mp4; codecs="mp4a.40.2"`, "", "aac"},
+		{`invalid`, "", ""},
+	}
+
+	for _, tt := range tests {
+		video, audio := formatCodecs(&youtube.Format{MimeType: tt.mimeType})
+		assert.Equal(t, tt.video, video, tt.mimeType)
+		assert.Equal(t, tt.audio, audio, tt.mimeType)
+	}
+}
+
+func TestDownloader_FilterCodecs(t *testing.T) {
+	formats := youtube.FormatList{
+		{ItagNo: 18, MimeType: `video/mp4; codecs="avc1.42001E, mp4a.40.2"`},
+		{ItagNo: 137, MimeType: `video/mp4; codecs="avc1.640028"`},
+		{ItagNo: 248, MimeType: `video/webm; codecs="vp9"`},
+		{ItagNo: 399, MimeType: `video/mp4; codecs="av01.0.08M.08"`},
+		{ItagNo: 140, MimeType: `audio/mp4; codecs="mp4a.40.2"`},
+		{ItagNo: 251, MimeType: `audio/webm; codecs="opus"`},
+	}
+
+	itags := func(formats youtube.FormatList) (result []int) {
+		for _, f := range formats {
+			result = append(result, f.ItagNo)
+		}
+		return result
+	}
+
+	dl := Downloader{}
+	got, err := dl.FilterCodecs(formats)
+	require.NoError(t, err)
+	assert.Len(t, got, 6)
+
+	dl.VideoCodec = "H264"
+	got, err = dl.FilterCodecs(formats)
+	require.NoError(t, err)
+	assert.Equal(t, []int{18, 137, 140, 251}, itags(got))
+
+	dl.VideoCodec = ""
+	dl.AudioCodec = "opus"
+	got, err = dl.FilterCodecs(formats)
+	require.NoError(t, err)
+	assert.Equal(t, []int{137, 248, 399, 251}, itags(got))
+
+	// the identifiers of mime types work too
+	dl.VideoCodec = "av01"
+	dl.AudioCodec = ""
+	got, err = dl.FilterCodecs(formats)
+	require.NoError(t, err)
+	assert.Equal(t, []int{399, 140, 251}, itags(got))
+
+	dl.VideoCodec = "vp8"
+	_, err = dl.FilterCodecs(formats)
+	assert.EqualError(t, err, "no video format with the codec vp8, available codecs: h264, vp9, av1")
+
+	var codecErr *ErrCodecUnavailable
+	require.ErrorAs(t, err, &codecErr)
+	assert.Equal(t, "video", codecErr.Kind)
+}
@@ -0,0 +1,196 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// part is a byte range of a stream, end is inclusive
+type part struct {
+	start int64
+	end   int64
+}
+
+// stream downloads the stream of the format into out, in parts at once if Concurrency is set
+func (dl *Downloader) stream(ctx context.Context, out *os.File, video *youtube.Video, format *youtube.Format) (int64, error) {
+	// the parts are written at their offsets, stdout can only be written in order
+	if dl.Concurrency > 1 && format.ContentLength > 0 && !dl.TestMode && out != os.Stdout {
+		written, err := dl.streamConcurrently(ctx, out, video, format)
+		if !errors.Is(err, youtube.ErrRangeNotSupported) {
+			return written, err
+		}
+
+		youtube.Logger.Warn("ranged requests not supported, downloading sequentially", "id", video.ID, "itag", format.ItagNo)
+		if err = rewind(out); err != nil {
+			return 0, err
+		}
+	} else if dl.resumable(out) && fileExists(chunkStatePath(out.Name())) {
+		// a kept output is only resumed by the concurrent download
+		os.Remove(chunkStatePath(out.Name()))
+		if err := rewind(out); err != nil {
+			return 0, err
+		}
+	}
+
+	return dl.streamWithRetries(ctx, out, video, format)
+}
+
+// streamConcurrently downloads the stream of the format in Concurrency parts at once, writing each at its offset of out,
+// see openPartWriter. With ChunkState the finished parts are recorded for resuming the download, see openChunkState.
+func (dl *Downloader) streamConcurrently(ctx context.Context, out *os.File, video *youtube.Video, format *youtube.Format) (int64, error) {
+	// decipher the URL once, before the parts share the client
+	if _, err := dl.GetStreamURLContext(ctx, video, format); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	reporter := dl.progressReporter(video, format)
+	reporter.Start(format.ContentLength)
+	defer reporter.Finish()
+
+	// with ChunkState the stream is split into more parts than are downloaded at once, so resuming it loses less
+	n := dl.Concurrency
+	if dl.resumable(out) {
+		n = max(n, int((format.ContentLength+chunkStateSize-1)/chunkStateSize))
+	}
+	parts := getParts(format.ContentLength, n)
+
+	state, err := dl.openChunkState(out, format.ContentLength, len(parts))
+	if err != nil {
+		return 0, err
+	}
+
+	target, err := dl.openPartWriter(out, format.ContentLength)
+	if err != nil {
+		return 0, err
+	}
+
+	counter := progressWriter{reporter}
+	limiter := dl.getRateLimiter()
+	refresher := dl.newURLRefresher(video, format)
+
+	var (
+		wg       sync.WaitGroup
+		written  atomic.Int64
+		errOnce  sync.Once
+		firstErr error
+	)
+
+	queue := make(chan int, len(parts))
+	for i, p := range parts {
+		if state.done(i) {
+			written.Add(p.end - p.start + 1)
+			reporter.Add(p.end - p.start + 1)
+			continue
+		}
+		queue <- i
+	}
+	close(queue)
+
+	for workers := min(dl.Concurrency, len(queue)); workers > 0; workers-- {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range queue {
+				n, err := dl.downloadPart(ctx, target, counter, limiter, video, refresher, parts[i])
+				written.Add(n)
+
+				if err == nil {
+					err = state.finish(i)
+				}
+
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if err = target.Close(); firstErr == nil {
+		firstErr = err
+	}
+
+	// the state of a failed download is kept for resuming it, unless it falls back to the sequential download
+	if firstErr == nil || errors.Is(firstErr, youtube.ErrRangeNotSupported) {
+		state.remove()
+	}
+
+	return written.Load(), firstErr
+}
+
+// downloadPart writes the part of the stream at its offset of out, resuming it up to MaxRetries times on transient errors
+// and after refreshing a forbidden URL
+func (dl *Downloader) downloadPart(ctx context.Context, out io.WriterAt, counter io.Writer, limiter *rateLimiter, video *youtube.Video, refresher *urlRefresher, p part) (int64, error) {
+	var written int64
+
+	format := refresher.current()
+
+	for attempt := 1; ; attempt++ {
+		n, err := dl.copyRange(ctx, out, counter, limiter, video, format, p.start+written, p.end)
+		written += n
+
+		switch {
+		case err == nil || ctx.Err() != nil:
+			return written, err
+		case isForbidden(err):
+			if format, err = refresher.refresh(ctx, format, err); err != nil {
+				return written, err
+			}
+			attempt--
+		case attempt > dl.MaxRetries || !isTransient(err):
+			return written, err
+		default:
+			youtube.Logger.Warn("download of part failed, retrying", "id", video.ID, "itag", format.ItagNo, "offset", p.start+written, "attempt", attempt, "error", err)
+
+			if err = sleepContext(ctx, dl.getRetryBackoff()(attempt)); err != nil {
+				return written, err
+			}
+		}
+	}
+}
+
+// copyRange copies the bytes from start to end of the stream to the same offset of out, and to counter.
+// A non-nil limiter throttles the copy.
+func (dl *Downloader) copyRange(ctx context.Context, out io.WriterAt, counter io.Writer, limiter *rateLimiter, video *youtube.Video, format *youtube.Format, start, end int64) (int64, error) {
+	stream, err := dl.GetStreamRangeContext(ctx, video, format, start, end)
+	if err != nil {
+		return 0, err
+	}
+	defer stream.Close()
+
+	w := io.MultiWriter(io.NewOffsetWriter(out, start), counter)
+	written, err := io.CopyBuffer(w, limitRate(ctx, stream, limiter), make([]byte, dl.getCopyBufferSize()))
+	if err == nil && written != end-start+1 {
+		err = fmt.Errorf("%w: part at offset %d has invalid size: expected=%d actual=%d", ErrIncompleteDownload, start, end-start+1, written)
+	}
+
+	return written, err
+}
+
+// getParts splits a stream of contentLength bytes into n parts of about the same size
+func getParts(contentLength int64, n int) []part {
+	size := (contentLength + int64(n) - 1) / int64(n)
+
+	var parts []part
+	for start := int64(0); start < contentLength; start += size {
+		parts = append(parts, part{start, min(start+size, contentLength) - 1})
+	}
+
+	return parts
+}
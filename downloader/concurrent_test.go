@@ -0,0 +1,68 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func Test_getParts(t *testing.T) {
+	assert.Equal(t, []part{{0, 3}, {4, 7}, {8, 9}}, getParts(10, 3))
+	assert.Equal(t, []part{{0, 4}, {5, 9}}, getParts(10, 2))
+	assert.Equal(t, []part{{0, 0}, {1, 1}}, getParts(2, 4))
+}
+
+func TestDownloader_videoDLWorker_concurrency(t *testing.T) {
+	const content = "0123456789abcdefghij"
+
+	var requests atomic.Int32
+	ignoreRange := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if ignoreRange {
+			w.Write([]byte(content)) //nolint:errcheck
+			return
+		}
+
+		var start, end int
+		_, err := fmt.Sscanf(r.URL.Query().Get("range"), "%d-%d", &start, &end)
+		require.NoError(t, err)
+		w.Write([]byte(content[start : end+1])) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	video := &youtube.Video{ID: "BaW_jenozKc"}
+	format := &youtube.Format{URL: server.URL, ContentLength: int64(len(content))}
+
+	for _, ignoreRange = range []bool{false, true} {
+		requests.Store(0)
+		path := filepath.Join(t.TempDir(), "video.mp4")
+		out, err := os.Create(path)
+		require.NoError(t, err)
+		defer out.Close()
+
+		dl := Downloader{ProgressOutput: io.Discard, Concurrency: 4}
+		written, err := dl.videoDLWorker(context.Background(), out, video, format)
+		require.NoError(t, err)
+		assert.EqualValues(t, len(content), written)
+
+		if !ignoreRange {
+			assert.EqualValues(t, 4, requests.Load())
+		}
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(data), "ignore range %v", ignoreRange)
+	}
+}
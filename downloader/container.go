@@ -0,0 +1,66 @@
+package downloader
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// The containers of Downloader.Container
+const (
+	ContainerMP4  = "mp4"
+	ContainerWebM = "webm"
+	ContainerMKV  = "mkv"
+)
+
+// containerCodecs are the video and audio codecs, by the names of formatCodecs, the containers of composite downloads can hold
+// when copying the streams, a container without codecs holds all
+var containerCodecs = map[string]struct {
+	video []string
+	audio []string
+}{
+	ContainerMP4:  {video: []string{"h264", "av1"}, audio: []string{"aac"}},
+	ContainerWebM: {video: []string{"vp8", "vp9", "av1"}, audio: []string{"opus", "vorbis"}},
+	ContainerMKV:  {},
+}
+
+// compositeExtension returns the extension of the output file merged from the formats:
+// the one of Container if set, else the container of the video format if it can hold the audio, else mkv
+func (dl *Downloader) compositeExtension(videoFormat, audioFormat *youtube.Format) (string, error) {
+	videoCodec, _ := formatCodecs(videoFormat)
+	_, audioCodec := formatCodecs(audioFormat)
+
+	if dl.Container != "" {
+		if _, ok := containerCodecs[dl.Container]; !ok {
+			return "", fmt.Errorf("unsupported container %s, use %s, %s or %s", dl.Container, ContainerMP4, ContainerWebM, ContainerMKV)
+		}
+
+		if !canHold(dl.Container, videoCodec, audioCodec) {
+			return "", fmt.Errorf("container %s can't hold the %s video and %s audio of the formats %d and %d, use %s",
+				dl.Container, videoCodec, audioCodec, videoFormat.ItagNo, audioFormat.ItagNo, ContainerMKV)
+		}
+
+		return "." + dl.Container, nil
+	}
+
+	ext := pickIdealFileExtension(videoFormat.MimeType)
+	if !canHold(strings.TrimPrefix(ext, "."), videoCodec, audioCodec) {
+		youtube.Logger.Info("using mkv for incompatible codecs", "container", strings.TrimPrefix(ext, "."), "video", videoCodec, "audio", audioCodec)
+		return "." + ContainerMKV, nil
+	}
+
+	return ext, nil
+}
+
+// canHold reports whether the container can hold streams of the codecs, unknown codecs are assumed to fit
+func canHold(container, videoCodec, audioCodec string) bool {
+	codecs, ok := containerCodecs[container]
+	if !ok || codecs.video == nil {
+		return ok
+	}
+
+	return (videoCodec == "" || slices.Contains(codecs.video, videoCodec)) &&
+		(audioCodec == "" || slices.Contains(codecs.audio, audioCodec))
+}
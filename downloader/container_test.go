@@ -0,0 +1,50 @@
+package downloader
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func TestDownloader_compositeExtension(t *testing.T) {
+	avc := &youtube.Format{ItagNo: 137, MimeType: `video/mp4; codecs="avc1.640028"`}
+	av1 := &youtube.Format{ItagNo: 399, MimeType: `video/mp4; codecs="av01.0.08M.08"`}
+	vp9 := &youtube.Format{ItagNo: 248, MimeType: `video/webm; codecs="vp9"`}
+	aac := &youtube.Format{ItagNo: 140, MimeType: `audio/mp4; codecs="mp4a.40.2"`}
+	opus := &youtube.Format{ItagNo: 251, MimeType: `audio/webm; codecs="opus"`}
+
+	tests := []struct {
+		name      string
+		container string
+		video     *youtube.Format
+		audio     *youtube.Format
+		want      string
+		wantErr   string
+	}{
+		{name: "mp4", video: avc, audio: aac, want: ".mp4"},
+		{name: "av1 in mp4", video: av1, audio: aac, want: ".mp4"},
+		{name: "webm", video: vp9, audio: opus, want: ".webm"},
+		{name: "vp9 with aac", video: vp9, audio: aac, want: ".mkv"},
+		{name: "avc with opus", video: avc, audio: opus, want: ".mkv"},
+		{name: "forced mkv", container: ContainerMKV, video: avc, audio: aac, want: ".mkv"},
+		{name: "forced webm", container: ContainerWebM, video: av1, audio: opus, want: ".webm"},
+		{name: "incompatible", container: ContainerMP4, video: vp9, audio: opus, wantErr: "container mp4 can't hold the vp9 video and opus audio of the formats 248 and 251, use mkv"},
+		{name: "unsupported", container: "avi", video: avc, audio: aac, wantErr: "unsupported container avi"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dl := Downloader{Container: tt.container}
+			ext, err := dl.compositeExtension(tt.video, tt.audio)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ext)
+		})
+	}
+}
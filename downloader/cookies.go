@@ -0,0 +1,115 @@
+package downloader
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// cookieDomain is the domain of the cookies loaded from CookiesFile, others are ignored
+const cookieDomain = "youtube.com"
+
+// httpOnlyPrefix marks HttpOnly cookies in cookies.txt files
+const httpOnlyPrefix = "#HttpOnly_"
+
+// loadCookies returns a cookie jar with the youtube.com cookies of a Netscape cookies.txt file
+func loadCookies(path string) (http.CookieJar, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	cookies, err := parseCookies(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cookies file %s: %w", path, err)
+	}
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var valid int
+	for _, c := range cookies {
+		if c.host != cookieDomain && !strings.HasSuffix(c.host, "."+cookieDomain) {
+			continue
+		}
+
+		if !c.Expires.IsZero() && c.Expires.Before(time.Now()) {
+			continue
+		}
+
+		jar.SetCookies(&url.URL{Scheme: "https", Host: c.host, Path: c.Path}, []*http.Cookie{c.Cookie})
+		valid++
+	}
+
+	if valid == 0 {
+		youtube.Logger.Warn("cookies file has no valid cookies of "+cookieDomain, "path", path)
+	}
+
+	return jar, nil
+}
+
+// fileCookie is a cookie of a cookies.txt file with the host it was set by
+type fileCookie struct {
+	*http.Cookie
+	host string
+}
+
+// parseCookies parses the lines of a Netscape cookies.txt file:
+// domain, include subdomains, path, secure, expiry, name and value, separated by tabs
+func parseCookies(r io.Reader) ([]fileCookie, error) {
+	var cookies []fileCookie
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+
+		httpOnly := strings.HasPrefix(text, httpOnlyPrefix)
+		text = strings.TrimPrefix(text, httpOnlyPrefix)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Split(text, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("line %d: expected 7 fields separated by tabs, got %d", line, len(fields))
+		}
+
+		expiry, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid expiry %q", line, fields[4])
+		}
+
+		cookie := &http.Cookie{
+			Domain:   fields[0],
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		}
+		// an expiry of 0 is a session cookie
+		if expiry > 0 {
+			cookie.Expires = time.Unix(expiry, 0)
+		}
+		// cookies of the host only have no domain attribute
+		if !strings.EqualFold(fields[1], "TRUE") {
+			cookie.Domain = ""
+		}
+
+		cookies = append(cookies, fileCookie{Cookie: cookie, host: strings.TrimPrefix(fields[0], ".")})
+	}
+
+	return cookies, scanner.Err()
+}
@@ -0,0 +1,68 @@
+package downloader
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCookies = `# Netscape HTTP Cookie File
+# This is a generated file! Do not edit.
+
+.youtube.com	TRUE	/	TRUE	4102444800	SID	secret
+#HttpOnly_.youtube.com	TRUE	/	TRUE	0	SESSION	session
+www.youtube.com	FALSE	/	FALSE	4102444800	PREF	hl=en
+.youtube.com	TRUE	/	TRUE	946684800	EXPIRED	old
+.google.com	TRUE	/	TRUE	4102444800	NID	other
+`
+
+func Test_parseCookies(t *testing.T) {
+	cookies, err := parseCookies(strings.NewReader(testCookies))
+	require.NoError(t, err)
+	require.Len(t, cookies, 5)
+
+	assert.Equal(t, "youtube.com", cookies[0].host)
+	assert.Equal(t, ".youtube.com", cookies[0].Domain)
+	assert.True(t, cookies[0].Secure)
+	assert.EqualValues(t, 4102444800, cookies[0].Expires.Unix())
+
+	assert.True(t, cookies[1].HttpOnly)
+	assert.True(t, cookies[1].Expires.IsZero())
+
+	// a host only cookie
+	assert.Equal(t, "www.youtube.com", cookies[2].host)
+	assert.Empty(t, cookies[2].Domain)
+
+	_, err = parseCookies(strings.NewReader("youtube.com\tTRUE\t/\n"))
+	assert.ErrorContains(t, err, "line 1: expected 7 fields")
+}
+
+func TestDownloader_SetupHTTPClient_cookies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cookies.txt")
+	require.NoError(t, os.WriteFile(path, []byte(testCookies), 0o600))
+
+	dl := Downloader{CookiesFile: path}
+	require.NoError(t, dl.SetupHTTPClient())
+	require.NotNil(t, dl.HTTPClient.Jar)
+
+	names := func(rawURL string) (names []string) {
+		u, err := url.Parse(rawURL)
+		require.NoError(t, err)
+		for _, cookie := range dl.HTTPClient.Jar.Cookies(u) {
+			names = append(names, cookie.Name)
+		}
+		return names
+	}
+
+	assert.ElementsMatch(t, []string{"SID", "SESSION", "PREF"}, names("https://www.youtube.com/watch"))
+	assert.ElementsMatch(t, []string{"SID", "SESSION"}, names("https://m.youtube.com/"))
+	assert.Empty(t, names("https://www.google.com/"))
+
+	dl = Downloader{CookiesFile: filepath.Join(t.TempDir(), "missing.txt")}
+	assert.Error(t, dl.SetupHTTPClient())
+}
@@ -2,67 +2,580 @@ package downloader
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/kkdai/youtube/v2"
 	"github.com/vbauerster/mpb/v5"
-	"github.com/vbauerster/mpb/v5/decor"
+
+	"github.com/kkdai/youtube/v2"
 )
 
 // Downloader offers high level functions to download videos into files
 type Downloader struct {
 	youtube.Client
 	OutputDir string // optional directory to store the files
+
+	// TempDir is where incomplete downloads and intermediate files are written, instead of next to the output.
+	// Complete files are moved to the output, also across filesystems.
+	TempDir string
+
+	// ProgressOutput is where the progress bar gets rendered.
+	// If not set, os.Stderr will be used, so the bar doesn't mix with data written to stdout.
+	ProgressOutput io.Writer
+
+	// Progress receives the progress of the downloads instead of the progress bar, e.g. for a GUI.
+	// It is shared by all downloads of the Downloader.
+	Progress ProgressReporter
+
+	// Silent disables the progress bar, e.g. for logging to a file. Progress is still reported to Progress.
+	Silent bool
+
+	// ProgressJSON writes the progress of each stream to ProgressOutput as lines of JSON instead of the bar,
+	// for scripts. See ProgressEvent for the schema.
+	ProgressJSON bool
+
+	// FFmpegPath is the ffmpeg executable, either a path or a name looked up in PATH. The default is "ffmpeg".
+	FFmpegPath string
+
+	// FFprobePath is the ffprobe executable, either a path or a name looked up in PATH.
+	// The default is the ffprobe in the directory of FFmpegPath, or "ffprobe" if FFmpegPath is a name.
+	FFprobePath string
+
+	// FFmpegArgs are passed to ffmpeg when merging video and audio, e.g. []string{"-movflags", "+faststart"}.
+	// They follow the inputs and the "-c copy -shortest" of the merge and precede the output file,
+	// so they add to these and override them, like "-c:v libx264". Inputs (-i) are rejected.
+	FFmpegArgs []string
+
+	// PrintFFmpegCommands prints the ffmpeg commands to os.Stderr instead of running them.
+	// The input files of merges and conversions are kept next to the output, named like the streams of KeepStreams,
+	// and the commands write to the output itself, so they can be run manually. The arguments are quoted for a POSIX shell.
+	PrintFFmpegCommands bool
+
+	// ShowFFmpegOutput streams the output of ffmpeg to os.Stderr.
+	// The last lines of it are always included in ErrFFmpegFailed.
+	ShowFFmpegOutput bool
+
+	// SkipSizeVerification disables the comparison of the bytes written with the reported size of a stream.
+	// By default a stream with less or more bytes fails with ErrIncompleteDownload,
+	// and Download keeps what was written with the suffix ".part", unless TempDir is set.
+	// Chunks and parts of the wrong size fail regardless, as the following ones would be misplaced.
+	// The option is negative, so the zero value of a Downloader literal verifies the size.
+	SkipSizeVerification bool
+
+	// SkipSpaceCheck disables the check for enough free space before a download of known size,
+	// for file systems reporting it unreliably. Composite downloads need twice the size of their streams.
+	SkipSpaceCheck bool
+
+	// VerifyWithProbe runs ffprobe on downloads of unknown size,
+	// to make sure they have a duration and the expected streams.
+	VerifyWithProbe bool
+
+	// VerifyMedia runs ffprobe on the finished file of each download, before the PostProcessors,
+	// and fails with ErrInvalidMedia unless it has a video or audio stream and a duration about the length of the video,
+	// catching e.g. merges into an empty file that have the expected size. The file is removed then, unless KeepStreams is set.
+	// The duration isn't checked in TestMode and for live streams.
+	VerifyMedia bool
+
+	// UniqueNames adds " (1)", " (2)" and so on to the name of an output file that already exists,
+	// or is being downloaded to by its ".part" file, instead of overwriting it. It has no effect with SkipExisting.
+	UniqueNames bool
+
+	// SkipExisting skips downloads of files that already exist.
+	// Files of a single stream with a different size than the stream are downloaded again.
+	SkipExisting bool
+
+	// TestMode only downloads about the first five seconds of each stream.
+	// This is meant for testing the selected formats, the resulting files might not be playable.
+	TestMode bool
+
+	// ThumbnailFormat is the image format of DownloadThumbnail, ThumbnailJPG (default) or ThumbnailWebP
+	ThumbnailFormat string
+
+	// CaptionsFormat is the file format of DownloadCaptions, CaptionsVTT (default) or CaptionsSRT
+	CaptionsFormat string
+
+	// CopyBufferSize is the size of the buffer for writing the stream into the file, the default is 256 KiB
+	CopyBufferSize int
+
+	// PostProcessors are run in order on each downloaded file
+	PostProcessors []PostProcessor
+
+	// CacheDir is a directory GetVideoCached caches the fetched videos in, as JSON files named by their ID.
+	// CacheTTL is how long they are used, DefaultCacheTTL if 0.
+	CacheDir string
+	CacheTTL time.Duration
+
+	// OnComplete is called with the result of each successful Download, DownloadAudio, DownloadComposite and DownloadClip,
+	// after the post-processors. Files skipped by SkipExisting are reported with Skipped set,
+	// downloads to stdout with the Path Stdout.
+	OnComplete func(result DownloadResult)
+
+	// WarmConnections opens the connections for a chunked download before requesting the chunks.
+	// The HTTP transport needs to keep at least MaxRoutines idle connections per host for this to help.
+	WarmConnections bool
+
+	// OutputTemplate names the files without an explicit name, e.g. "{author}/{title}-{id}.{ext}".
+	// See OutputTemplateTokens for the placeholders, slashes create directories. ResolutionSuffix is ignored with it.
+	OutputTemplate string
+
+	// PlaylistDir nests the generated files of playlist entries, see WithPlaylistEntry,
+	// in a directory named after the playlist
+	PlaylistDir bool
+
+	// ResolutionSuffix appends the resolution of the format to generated file names, e.g. "Title [1080p].mp4".
+	// This keeps several renditions of the same video apart.
+	ResolutionSuffix bool
+
+	// AudioLanguage selects the audio track of videos with multiple tracks, e.g. "es".
+	// If the video has no track in this language, the default track is used and a warning logged.
+	AudioLanguage string
+
+	// MergeRetry retries a failed merge of video and audio once with re-encoding the streams,
+	// for codec and container combinations ffmpeg can't copy.
+	MergeRetry bool
+
+	// Container is the container DownloadComposite merges into without an output file name: mp4, webm or mkv.
+	// By default it is the container of the video format, or mkv if that can't hold the audio codec.
+	Container string
+
+	// AudioFormat is the format DownloadAudio converts the audio stream to: mp3, opus, aac or m4a.
+	// If it is empty, the extension of the output file selects one of them, otherwise the stream is kept as it is.
+	AudioFormat string
+
+	// StrictAudioLang fails with ErrAudioLanguageUnavailable instead of falling back to the default track
+	StrictAudioLang bool
+
+	// PreferFreeFormats ranks webm before mp4 formats, see SortFormats
+	PreferFreeFormats bool
+
+	// VideoCodec and AudioCodec select the formats with these codecs, e.g. h264, vp9 or av1 and aac or opus,
+	// see FilterCodecs. There is no fallback to other codecs.
+	VideoCodec string
+	AudioCodec string
+
+	// NormalizeAudio normalizes the loudness of the audio of Download, DownloadComposite and DownloadAudio
+	// to TargetLUFS with the loudnorm filter (EBU R128) of ffmpeg. This re-encodes the audio, which is slower,
+	// the video is still copied.
+	NormalizeAudio bool
+
+	// TargetLUFS is the integrated loudness of NormalizeAudio, from -70 to -5. The default is DefaultTargetLUFS.
+	TargetLUFS float64
+
+	// KeepStreams keeps the downloaded video and audio streams of DownloadComposite next to the output file,
+	// named like "Title.f137.mp4" and "Title.f140.m4a". They are kept regardless if the merge fails, see ErrMergeFailed.
+	// It also keeps the files failing VerifyMedia.
+	KeepStreams bool
+
+	// EmbedChapters writes the chapters of the video into the files of Download and DownloadComposite with ffmpeg.
+	// Composite downloads get them with the merge, others with an extra pass copying the streams.
+	// Videos without chapters are left unchanged.
+	EmbedChapters bool
+
+	// PreferFPS ranks video formats with this frame rate first among the formats of the same resolution, e.g. 60.
+	// Other frame rates are still selected if no format has it.
+	PreferFPS int
+
+	// MinFPS selects the video formats of composite downloads with a frame rate of at least this, e.g. 60,
+	// see FilterFPS. If no format has it, lower frame rates are selected, unless StrictFPS is set.
+	MinFPS    int
+	StrictFPS bool
+
+	// MinFilesize and MaxFilesize limit the estimated size of the selected formats in bytes, 0 means no limit.
+	// For composite downloads the limits apply to the video stream.
+	MinFilesize int64
+	MaxFilesize int64
+
+	// RefreshPlayerOn403 retries a download forbidden by YouTube once after clearing the cached player,
+	// as a rotated player breaks the deciphered stream URLs.
+	// This costs fetching the player JavaScript again and restarting the download from the beginning.
+	RefreshPlayerOn403 bool
+
+	// RefreshURLsOn403 fetches the video again when its stream URL is forbidden during a download, as signed URLs expire,
+	// and resumes the download where it stopped with the fresh URL of the same format.
+	// This doesn't count as a retry of MaxRetries, a download refreshes its URL at most 3 times.
+	RefreshURLsOn403 bool
+
+	// TryAlternateHosts retries failed downloads from the other CDN hosts the stream URL lists.
+	// This is best-effort, it only works for formats with a plain URL and relies on undocumented parameters.
+	TryAlternateHosts bool
+
+	// DNSServer is the address of a DNS server used instead of the system resolver, e.g. "1.1.1.1" or "9.9.9.9:53".
+	// Lookups failing on it fall back to the system resolver. It is applied by SetupHTTPClient.
+	DNSServer string
+
+	// ProxyURL is a proxy for all requests like "http://proxy:3128" or "socks5://localhost:1080",
+	// replacing the proxy of the transport. It is applied by SetupHTTPClient.
+	ProxyURL string
+
+	// CookiesFile is a Netscape cookies.txt file with the cookies of a signed in browser session,
+	// for age-restricted and members-only videos. Only youtube.com cookies are sent.
+	// It is applied by SetupHTTPClient.
+	CookiesFile string
+
+	// Headers are added to all requests, e.g. a User-Agent or Accept-Language.
+	// They replace the headers of the same name set by the client, except cookies, which are added.
+	// It is applied by SetupHTTPClient.
+	Headers http.Header
+
+	// TrafficLog receives the method, URL, status and headers of all HTTP requests, for debugging.
+	// Signatures, keys and cookies are redacted. It is applied by SetupHTTPClient.
+	TrafficLog io.Writer
+
+	// MaxRetries is how often a stream failing with a network error or a 5xx response is reopened.
+	// Streams of known size resume where the failed attempt stopped, others are downloaded again.
+	MaxRetries int
+
+	// RetryBackoff returns how long to wait before the retry with the given number, starting at 1.
+	// The default doubles the delay from one second up to 30 seconds.
+	RetryBackoff func(attempt int) time.Duration
+
+	// Concurrency is the number of parts a stream of known size is split into and downloaded at once,
+	// each with its own ranged request. The default 1 downloads a stream sequentially.
+	// Streams of unknown size and servers not supporting ranges fall back to the sequential download.
+	Concurrency int
+
+	// UseMmap writes the parts of concurrent downloads into a memory mapping of the output file,
+	// which is truncated to the size of the stream first, instead of a write syscall per buffer.
+	// Whether that is faster depends on the platform and file system, see BenchmarkPartWriter.
+	// Platforms without memory mapping and files too large to map are written at offsets as without it.
+	UseMmap bool
+
+	// ChunkState records the finished parts of concurrent downloads in a file with the suffix ".state" next to the
+	// ".part" output, so Download keeps both on errors and a later Download of the format resumes the missing parts.
+	// The stream is then split into parts of 10 MiB, of which Concurrency are downloaded at once.
+	// It has no effect with TempDir, and UniqueNames gives the next Download another name.
+	ChunkState bool
+
+	// MaxBytesPerSecond limits the average rate of all downloads of the Downloader together:
+	// concurrent downloads, the parts of a concurrent download and the video and audio of a
+	// composite share the limit. The default 0 does not limit the rate.
+	MaxBytesPerSecond int64
+
+	// RequestInterval is the least time between the video fetches of GetVideoCached and RefreshURLsOn403, with a small
+	// random jitter added, so a batch doesn't get the IP throttled. The streams aren't delayed. The default 0 doesn't wait.
+	RequestInterval time.Duration
+
+	// the progress bars of the running downloads, see barReporter
+	barsMu     sync.Mutex
+	bars       *mpb.Progress
+	activeBars int
+	batch      *BatchProgress // the overall bar, see StartBatch
+
+	// the cached FFmpegInfo of the executable at ffmpegInfoPath
+	ffmpegInfoMu   sync.Mutex
+	ffmpegInfo     *FFmpegInfo
+	ffmpegInfoPath string
+
+	// when the next video may be fetched, see RequestInterval
+	requestMu   sync.Mutex
+	nextRequest time.Time
+
+	// the limiter shared by all streams, see MaxBytesPerSecond
+	limiterMu sync.Mutex
+	limiter   *rateLimiter
 }
 
-func (dl *Downloader) getOutputFile(v *youtube.Video, format *youtube.Format, outputFile string) (string, error) {
+func (dl *Downloader) getProgressOutput() io.Writer {
+	if dl.ProgressOutput != nil {
+		return dl.ProgressOutput
+	}
+
+	return os.Stderr
+}
+
+func (dl *Downloader) getOutputFile(ctx context.Context, v *youtube.Video, format *youtube.Format, outputFile string) (string, error) {
 	if outputFile == "" {
-		outputFile = SanitizeFilename(v.Title)
-		outputFile += pickIdealFileExtension(format.MimeType)
+		var err error
+		outputFile, err = dl.getDefaultFile(ctx, v, format, pickIdealFileExtension(format.MimeType))
+		if err != nil {
+			return "", err
+		}
 	}
 
+	destFile, err := dl.joinOutputDir(outputFile)
+	if err != nil {
+		return "", err
+	}
+
+	return dl.uniqueName(destFile), nil
+}
+
+// uniqueName returns destFile, or with UniqueNames the first name with " (1)", " (2)" and so on
+// before the extension that isn't taken yet. Existing files are kept with SkipExisting.
+// A name is taken by a file or by its ".part" file, which reserves the name for the download
+// until releaseName removes it, so concurrent downloads of the same name don't get the same one.
+func (dl *Downloader) uniqueName(destFile string) string {
+	if !dl.UniqueNames || dl.SkipExisting {
+		return destFile
+	}
+
+	ext := filepath.Ext(destFile)
+	base := strings.TrimSuffix(destFile, ext)
+
+	name := destFile
+	for i := 1; !reserveName(name); i++ {
+		name = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+
+	if name != destFile {
+		youtube.Logger.Info("output file exists, using a new name", "path", name)
+	}
+
+	return name
+}
+
+// reserveName creates the ".part" file of the name, unless it or the file exists.
+// Other errors than an existing file leave the name to the download, which fails on them.
+func reserveName(name string) bool {
+	if fileExists(name) {
+		return false
+	}
+
+	part, err := os.OpenFile(name+partialSuffix, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return false
+	}
+	if err == nil {
+		part.Close()
+	}
+
+	return true
+}
+
+// releaseName removes the ".part" file reserving destFile for uniqueName, if it is still empty.
+// The ".part" files of downloads are kept, e.g. the incomplete ones for inspection.
+func (dl *Downloader) releaseName(destFile string) {
+	if !dl.UniqueNames || dl.SkipExisting {
+		return
+	}
+
+	if info, err := os.Stat(destFile + partialSuffix); err == nil && info.Mode().IsRegular() && info.Size() == 0 {
+		os.Remove(destFile + partialSuffix)
+	}
+}
+
+// fileExists reports whether there is a file or directory at the path
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// joinOutputDir places the file in OutputDir, if set, and creates the missing directories
+func (dl *Downloader) joinOutputDir(outputFile string) (string, error) {
 	if dl.OutputDir != "" {
-		if err := os.MkdirAll(dl.OutputDir, 0o755); err != nil {
+		if err := ensureDir(dl.OutputDir); err != nil {
 			return "", err
 		}
 		outputFile = filepath.Join(dl.OutputDir, outputFile)
 	}
 
+	// the output file may point into a nested directory
+	if err := ensureDir(filepath.Dir(outputFile)); err != nil {
+		return "", err
+	}
+
 	return outputFile, nil
 }
 
+// ensureDir creates the directory and its parents if they don't exist.
+// It returns ErrOutputDirNotDirectory if the directory or a parent is a file.
+func ensureDir(dir string) error {
+	// the nearest existing path has to be a directory
+	for path := dir; ; path = filepath.Dir(path) {
+		if info, err := os.Stat(path); err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%w: %s", ErrOutputDirNotDirectory, path)
+			}
+			break
+		}
+
+		if filepath.Dir(path) == path {
+			break
+		}
+	}
+
+	return os.MkdirAll(dir, 0o755)
+}
+
 // Download : Starting download video by arguments.
-func (dl *Downloader) Download(ctx context.Context, v *youtube.Video, format *youtube.Format, outputFile string) error {
+// The stream is written to outputFile with the suffix ".part", or to a temporary file of TempDir,
+// and renamed once its size is verified, so an interrupted download never has the final name.
+// The partial file is removed on errors, except for an ErrIncompleteDownload without TempDir
+// and a concurrent download with ChunkState, which the next Download resumes.
+func (dl *Downloader) Download(ctx context.Context, v *youtube.Video, format *youtube.Format, outputFile string) (*DownloadResult, error) {
+	start := time.Now()
+
 	youtube.Logger.Info(
 		"Downloading video",
 		"id", v.ID,
 		"quality", format.Quality,
 		"mimeType", format.MimeType,
 	)
-	destFile, err := dl.getOutputFile(v, format, outputFile)
+	if outputFile == Stdout {
+		written, err := dl.videoDLWorker(ctx, os.Stdout, v, format)
+		if err != nil {
+			return nil, err
+		}
+
+		return dl.complete(&DownloadResult{Path: Stdout, Itag: format.ItagNo, Bytes: written, Elapsed: time.Since(start)})
+	}
+
+	audioArgs, err := dl.loudnormArgs(format)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Create output file
-	out, err := os.Create(destFile)
+	destFile, err := dl.getOutputFile(ctx, v, format, outputFile)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer out.Close()
+	defer dl.releaseName(destFile)
 
-	return dl.videoDLWorker(ctx, out, v, format)
+	if dl.skipExisting(destFile, format.ContentLength) {
+		return dl.complete(&DownloadResult{Path: destFile, Itag: format.ItagNo, Skipped: true, Elapsed: time.Since(start)})
+	}
+
+	// Create output file, it only gets the name of destFile once complete
+	out, err := dl.createOutput(destFile)
+	if err != nil {
+		return nil, err
+	}
+
+	written, err := dl.videoDLWorker(ctx, out, v, format)
+	out.Close()
+	if err != nil {
+		if errors.Is(err, ErrIncompleteDownload) && dl.TempDir == "" {
+			// keep the truncated file next to the destination for inspection
+			return nil, err
+		}
+		if dl.resumable(out) && fileExists(chunkStatePath(out.Name())) {
+			youtube.Logger.Info("keeping the partial download for resuming it", "path", out.Name())
+			return nil, err
+		}
+		os.Remove(out.Name())
+		return nil, err
+	}
+
+	if err = safeRename(out.Name(), destFile); err != nil {
+		os.Remove(out.Name())
+		return nil, err
+	}
+
+	if err = dl.embedChapters(ctx, v, destFile); err != nil {
+		return nil, err
+	}
+
+	if err = dl.normalizeAudio(ctx, destFile, format, audioArgs); err != nil {
+		return nil, err
+	}
+
+	if err = dl.verifyMedia(ctx, destFile, dl.expectedDuration(v), dl.expectedDuration(v)); err != nil {
+		return nil, err
+	}
+
+	destFile, err = dl.runPostProcessors(ctx, v, destFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return dl.complete(&DownloadResult{
+		Path:    destFile,
+		Itag:    format.ItagNo,
+		Bytes:   written,
+		Elapsed: time.Since(start),
+	})
 }
 
 // DownloadComposite : Downloads audio and video streams separately and merges them via ffmpeg.
-func (dl *Downloader) DownloadComposite(ctx context.Context, outputFile string, v *youtube.Video, quality string, mimetype string) error {
-	videoFormat, audioFormat, err1 := getVideoAudioFormats(v, quality, mimetype)
-	if err1 != nil {
-		return err1
+// The quality can be a list of preferences like "hd1080,hd720", see FilterQuality.
+// QualityBest selects the video format of the highest resolution and the best audio format, QualityWorst the opposite.
+func (dl *Downloader) DownloadComposite(ctx context.Context, outputFile string, v *youtube.Video, quality string, mimetype string) (*DownloadResult, error) {
+	start := time.Now()
+
+	if outputFile == Stdout {
+		return nil, fmt.Errorf("%w, composite downloads merge seekable temporary files", ErrStdoutUnsupported)
+	}
+
+	videoFormat, audioFormat, err := dl.getVideoAudioFormats(v, quality, mimetype)
+	if err != nil {
+		return nil, err
+	}
+
+	return dl.downloadComposite(ctx, start, outputFile, v, videoFormat, audioFormat)
+}
+
+// DownloadCompositeFormats downloads the video and audio formats separately and merges them via ffmpeg,
+// like DownloadComposite without selecting the formats
+func (dl *Downloader) DownloadCompositeFormats(ctx context.Context, outputFile string, v *youtube.Video, videoFormat, audioFormat *youtube.Format) (*DownloadResult, error) {
+	start := time.Now()
+
+	if outputFile == Stdout {
+		return nil, fmt.Errorf("%w, composite downloads merge seekable temporary files", ErrStdoutUnsupported)
+	}
+
+	if videoFormat == nil || audioFormat == nil {
+		return nil, downloadError(KindNoFormat, youtube.ErrNoFormat)
+	}
+
+	return dl.downloadComposite(ctx, start, outputFile, v, videoFormat, audioFormat)
+}
+
+// DownloadCompositeByItags downloads the video only format of videoItag and the audio only format of audioItag
+// and merges them via ffmpeg, like DownloadCompositeFormats.
+// A missing itag or a format of the wrong kind is a DownloadError of KindNoFormat.
+func (dl *Downloader) DownloadCompositeByItags(ctx context.Context, outputFile string, v *youtube.Video, videoItag, audioItag int) (*DownloadResult, error) {
+	videoFormat, audioFormat, err := compositeFormatsByItags(v, videoItag, audioItag)
+	if err != nil {
+		return nil, err
+	}
+
+	return dl.DownloadCompositeFormats(ctx, outputFile, v, videoFormat, audioFormat)
+}
+
+// compositeFormatsByItags returns the video only format of videoItag and the audio only format of audioItag
+func compositeFormatsByItags(v *youtube.Video, videoItag, audioItag int) (*youtube.Format, *youtube.Format, error) {
+	videoFormat := v.Formats.FindByItag(videoItag)
+	if videoFormat == nil {
+		return nil, nil, downloadError(KindNoFormat, fmt.Errorf("the video has no format with itag %d", videoItag))
+	}
+
+	audioFormat := v.Formats.FindByItag(audioItag)
+	if audioFormat == nil {
+		return nil, nil, downloadError(KindNoFormat, fmt.Errorf("the video has no format with itag %d", audioItag))
+	}
+
+	isVideo := strings.HasPrefix(videoFormat.MimeType, "video/")
+	isAudio := strings.HasPrefix(audioFormat.MimeType, "audio/")
+
+	switch {
+	case !isVideo && strings.HasPrefix(audioFormat.MimeType, "video/"):
+		return nil, nil, downloadError(KindNoFormat, fmt.Errorf("itag %d is the audio and itag %d the video, swap them", videoItag, audioItag))
+	case !isVideo:
+		return nil, nil, downloadError(KindNoFormat, fmt.Errorf("format %d is not a video format, it is %s", videoItag, videoFormat.MimeType))
+	case videoFormat.AudioChannels > 0:
+		return nil, nil, downloadError(KindNoFormat, fmt.Errorf("format %d already has audio, download it by its itag alone", videoItag))
+	case !isAudio:
+		return nil, nil, downloadError(KindNoFormat, fmt.Errorf("format %d is not an audio only format, it is %s", audioItag, audioFormat.MimeType))
+	}
+
+	return videoFormat, audioFormat, nil
+}
+
+// downloadComposite downloads and merges the formats of a composite download started at start
+func (dl *Downloader) downloadComposite(ctx context.Context, start time.Time, outputFile string, v *youtube.Video, videoFormat, audioFormat *youtube.Format) (*DownloadResult, error) {
+	audioArgs, err := dl.loudnormArgs(audioFormat)
+	if err != nil {
+		return nil, err
 	}
 
 	log := youtube.Logger.With("id", v.ID)
@@ -74,55 +587,295 @@ func (dl *Downloader) DownloadComposite(ctx context.Context, outputFile string,
 		"audioMimeType", audioFormat.MimeType,
 	)
 
-	destFile, err := dl.getOutputFile(v, videoFormat, outputFile)
+	if outputFile == "" {
+		var ext string
+		ext, err = dl.compositeExtension(videoFormat, audioFormat)
+		if err != nil {
+			return nil, err
+		}
+		outputFile, err = dl.getDefaultFile(ctx, v, videoFormat, ext)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	destFile, err := dl.getOutputFile(ctx, v, videoFormat, outputFile)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	defer dl.releaseName(destFile)
+
+	if dl.skipExisting(destFile, 0) {
+		return dl.complete(&DownloadResult{
+			Path:      destFile,
+			Itag:      videoFormat.ItagNo,
+			AudioItag: audioFormat.ItagNo,
+			Skipped:   true,
+			Elapsed:   time.Since(start),
+		})
+	}
+
+	// don't download streams that can't be merged
+	if err = dl.CheckFFmpeg(); err != nil {
+		return nil, err
+	}
+
+	tempDir, err := dl.getTempDir(filepath.Dir(destFile))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = dl.checkCompositeSpace(tempDir, filepath.Dir(destFile), videoFormat, audioFormat); err != nil {
+		return nil, err
 	}
-	outputDir := filepath.Dir(destFile)
 
 	// Create temporary video file
-	videoFile, err := os.CreateTemp(outputDir, "youtube_*.m4v")
+	videoFile, err := os.CreateTemp(tempDir, "youtube_*"+pickIdealFileExtension(videoFormat.MimeType))
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer os.Remove(videoFile.Name())
+	defer dl.removeIntermediate(videoFile.Name())
+	defer videoFile.Close()
 
 	// Create temporary audio file
-	audioFile, err := os.CreateTemp(outputDir, "youtube_*.m4a")
+	audioFile, err := os.CreateTemp(tempDir, "youtube_*"+pickIdealFileExtension(audioFormat.MimeType))
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer os.Remove(audioFile.Name())
+	defer dl.removeIntermediate(audioFile.Name())
+	defer audioFile.Close()
 
-	log.Debug("Downloading video file...")
-	err = dl.videoDLWorker(ctx, videoFile, v, videoFormat)
+	log.Debug("Downloading video and audio files...")
+	videoBytes, audioBytes, err := dl.downloadStreams(ctx, v, videoFile, videoFormat, audioFile, audioFormat)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	log.Info("merging video and audio", "output", destFile)
+
+	videoPath, audioPath, mergeFile := videoFile.Name(), audioFile.Name(), destFile
+	if dl.PrintFFmpegCommands {
+		// the printed command refers to the streams by their names next to the output
+		if videoPath, audioPath, err = keepStreams(destFile, videoFile, videoFormat, audioFile, audioFormat); err != nil {
+			return nil, err
+		}
+	} else if dl.TempDir != "" {
+		// moved to the destination once complete
+		mergeFile = filepath.Join(tempDir, "youtube_"+filepath.Base(videoFile.Name())+filepath.Ext(destFile))
+		defer os.Remove(mergeFile)
 	}
 
-	log.Debug("Downloading audio file...")
-	err = dl.videoDLWorker(ctx, audioFile, v, audioFormat)
+	chaptersFile, err := dl.writeChaptersFile(v, tempDir)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if chaptersFile != "" {
+		defer dl.removeIntermediate(chaptersFile)
+	}
+
+	mergeStart := time.Now()
+	err = dl.merge(ctx, videoPath, audioPath, chaptersFile, mergeFile, audioArgs)
+	if err != nil {
+		// a failed or cancelled ffmpeg leaves an incomplete output behind
+		os.Remove(mergeFile)
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, dl.mergeFailed(err, destFile, videoFile, videoFormat, audioFile, audioFormat)
+	}
+	mergeElapsed := time.Since(mergeStart)
+
+	if dl.KeepStreams && !dl.PrintFFmpegCommands {
+		if _, _, err = keepStreams(destFile, videoFile, videoFormat, audioFile, audioFormat); err != nil {
+			return nil, err
+		}
+	}
+
+	if mergeFile != destFile && !dl.PrintFFmpegCommands {
+		if err = safeRename(mergeFile, destFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = dl.verifyMedia(ctx, destFile, dl.expectedDuration(v), dl.expectedDuration(v)); err != nil {
+		return nil, err
 	}
 
-	//nolint:gosec
-	ffmpegVersionCmd := exec.Command("ffmpeg", "-y",
-		"-i", videoFile.Name(),
-		"-i", audioFile.Name(),
-		"-c", "copy", // Just copy without re-encoding
-		"-shortest", // Finish encoding when the shortest input stream ends
-		destFile,
-		"-loglevel", "warning",
+	destFile, err = dl.runPostProcessors(ctx, v, destFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return dl.complete(&DownloadResult{
+		Path:         destFile,
+		Itag:         videoFormat.ItagNo,
+		AudioItag:    audioFormat.ItagNo,
+		Bytes:        videoBytes + audioBytes,
+		Elapsed:      time.Since(start),
+		MergeElapsed: mergeElapsed,
+	})
+}
+
+// mergeFailed keeps the streams of a failed merge into destFile and returns an ErrMergeFailed telling where they are
+func (dl *Downloader) mergeFailed(err error, destFile string, videoFile *os.File, videoFormat *youtube.Format, audioFile *os.File, audioFormat *youtube.Format) error {
+	videoPath, audioPath, keepErr := keepStreams(destFile, videoFile, videoFormat, audioFile, audioFormat)
+	if keepErr != nil {
+		return fmt.Errorf("%w, keeping the downloaded streams failed: %w", err, keepErr)
+	}
+
+	mergeErr := &ErrMergeFailed{Err: err, VideoFile: videoPath, AudioFile: audioPath}
+
+	var failed *ErrFFmpegFailed
+	if errors.As(err, &failed) {
+		mergeErr.Command = failed.Command
+	}
+
+	return mergeErr
+}
+
+// keepStreams moves the downloaded streams of a composite download next to destFile
+// and returns their paths, see KeepStreams
+func keepStreams(destFile string, videoFile *os.File, videoFormat *youtube.Format, audioFile *os.File, audioFormat *youtube.Format) (string, string, error) {
+	videoPath, audioPath := streamFileName(destFile, videoFormat), streamFileName(destFile, audioFormat)
+
+	// open files can't be renamed on Windows
+	videoFile.Close()
+	audioFile.Close()
+
+	if err := safeRename(videoFile.Name(), videoPath); err != nil {
+		return "", "", err
+	}
+	if err := safeRename(audioFile.Name(), audioPath); err != nil {
+		return "", "", err
+	}
+
+	youtube.Logger.Info("kept the downloaded streams", "video", videoPath, "audio", audioPath)
+
+	return videoPath, audioPath, nil
+}
+
+// streamFileName returns the name of the stream of the format downloaded for destFile, e.g. "Title.f137.mp4"
+func streamFileName(destFile string, format *youtube.Format) string {
+	return strings.TrimSuffix(destFile, filepath.Ext(destFile)) + ".f" + strconv.Itoa(format.ItagNo) + pickIdealFileExtension(format.MimeType)
+}
+
+// downloadStreams downloads the video and audio streams of a composite download at the same time,
+// the failure of one cancels the other
+func (dl *Downloader) downloadStreams(ctx context.Context, v *youtube.Video, videoFile *os.File, videoFormat *youtube.Format, audioFile *os.File, audioFormat *youtube.Format) (int64, int64, error) {
+	// decipher the URLs once, before the streams share the client
+	for _, format := range []*youtube.Format{videoFormat, audioFormat} {
+		if _, err := dl.GetStreamURLContext(ctx, v, format); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg                     sync.WaitGroup
+		videoBytes, audioBytes int64
+		errOnce                sync.Once
+		firstErr               error
 	)
-	ffmpegVersionCmd.Stderr = os.Stderr
-	ffmpegVersionCmd.Stdout = os.Stdout
-	log.Info("merging video and audio", "output", destFile)
 
-	return ffmpegVersionCmd.Run()
+	download := func(out *os.File, format *youtube.Format, written *int64) {
+		defer wg.Done()
+
+		n, err := dl.videoDLWorker(ctx, out, v, format)
+		*written = n
+
+		if err != nil {
+			errOnce.Do(func() {
+				firstErr = err
+				cancel()
+			})
+		}
+	}
+
+	wg.Add(2)
+	go download(videoFile, videoFormat, &videoBytes)
+	go download(audioFile, audioFormat, &audioBytes)
+	wg.Wait()
+
+	return videoBytes, audioBytes, firstErr
+}
+
+// skipExisting reports whether destFile already exists and is kept, see SkipExisting.
+// The size is compared if it is known and there are no PostProcessors changing it.
+func (dl *Downloader) skipExisting(destFile string, size int64) bool {
+	if !dl.SkipExisting {
+		return false
+	}
+
+	info, err := os.Stat(destFile)
+	if err != nil || info.IsDir() {
+		return false
+	}
+
+	if size > 0 && len(dl.PostProcessors) == 0 && !dl.TestMode && info.Size() != size {
+		youtube.Logger.Warn("existing file has a different size, downloading it again", "path", destFile, "size", info.Size(), "expected", size)
+		return false
+	}
+
+	youtube.Logger.Info("skipping existing file", "path", destFile)
+
+	return true
+}
+
+// getTempDir returns TempDir, or dir if it is not set
+func (dl *Downloader) getTempDir(dir string) (string, error) {
+	if dl.TempDir == "" {
+		return dir, nil
+	}
+
+	return dl.TempDir, ensureDir(dl.TempDir)
 }
 
-func getVideoAudioFormats(v *youtube.Video, quality string, mimetype string) (*youtube.Format, *youtube.Format, error) {
+// createOutput creates the file to download destFile into, which has to be moved to destFile when complete.
+// It is destFile with the suffix ".part", or a temporary file in TempDir.
+func (dl *Downloader) createOutput(destFile string) (*os.File, error) {
+	if dl.TempDir == "" {
+		// the parts of a resumed download are kept, see openChunkState
+		if dl.ChunkState && fileExists(chunkStatePath(destFile+partialSuffix)) {
+			return os.OpenFile(destFile+partialSuffix, os.O_RDWR|os.O_CREATE, 0o644)
+		}
+		return os.Create(destFile + partialSuffix)
+	}
+
+	if err := ensureDir(dl.TempDir); err != nil {
+		return nil, err
+	}
+
+	out, err := os.CreateTemp(dl.TempDir, "youtube_*"+filepath.Ext(destFile))
+	if err != nil {
+		return nil, err
+	}
+
+	// temporary files are private, outputs are not
+	if err = out.Chmod(0o644); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// removeIntermediate removes a temporary file, unless the printed ffmpeg commands need it
+func (dl *Downloader) removeIntermediate(path string) {
+	if dl.PrintFFmpegCommands {
+		// inputs moved next to the output are logged by keepStreams
+		if _, err := os.Stat(path); err == nil {
+			youtube.Logger.Info("keeping the input of the printed ffmpeg command", "path", path)
+		}
+		return
+	}
+
+	os.Remove(path)
+}
+
+func (dl *Downloader) getVideoAudioFormats(v *youtube.Video, quality string, mimetype string) (*youtube.Format, *youtube.Format, error) {
 	var videoFormat, audioFormat *youtube.Format
 	var videoFormats, audioFormats youtube.FormatList
 
@@ -132,66 +885,272 @@ func getVideoAudioFormats(v *youtube.Video, quality string, mimetype string) (*y
 	}
 
 	videoFormats = formats.Type("video").AudioChannels(0)
-	audioFormats = formats.Type("audio")
+	audioFormats, err := dl.FilterAudioLanguage(formats.Type("audio"))
+	if err != nil {
+		return nil, nil, downloadError(KindNoFormat, err)
+	}
 
 	if quality != "" {
-		videoFormats = videoFormats.Quality(quality)
+		// the quality of the list that matched decides between the best and worst formats
+		videoFormats, quality = FilterQuality(videoFormats, quality)
+	}
+
+	videoFormats, err = dl.FilterFilesize(videoFormats)
+	if err != nil {
+		return nil, nil, downloadError(KindNoFormat, err)
+	}
+
+	if videoFormats, err = dl.FilterCodecs(videoFormats); err != nil {
+		return nil, nil, downloadError(KindNoFormat, err)
+	}
+
+	if videoFormats, err = dl.FilterFPS(videoFormats); err != nil {
+		return nil, nil, downloadError(KindNoFormat, err)
+	}
+
+	if audioFormats, err = dl.FilterCodecs(audioFormats); err != nil {
+		return nil, nil, downloadError(KindNoFormat, err)
+	}
+
+	// the formats sort from the best to the worst
+	pick := func(formats youtube.FormatList) *youtube.Format {
+		dl.SortFormats(formats)
+		if quality == QualityWorst {
+			return &formats[len(formats)-1]
+		}
+		return &formats[0]
 	}
 
 	if len(videoFormats) > 0 {
-		videoFormats.Sort()
-		videoFormat = &videoFormats[0]
+		videoFormat = pick(videoFormats)
 	}
 
 	if len(audioFormats) > 0 {
-		audioFormats.Sort()
-		audioFormat = &audioFormats[0]
+		audioFormat = pick(audioFormats)
 	}
 
 	if videoFormat == nil {
-		return nil, nil, fmt.Errorf("no video format found after filtering")
+		return nil, nil, downloadError(KindNoFormat, errors.New("no video format found after filtering"))
 	}
 
 	if audioFormat == nil {
-		return nil, nil, fmt.Errorf("no audio format found after filtering")
+		return nil, nil, downloadError(KindNoFormat, errors.New("no audio format found after filtering"))
 	}
 
 	return videoFormat, audioFormat, nil
 }
 
-func (dl *Downloader) videoDLWorker(ctx context.Context, out *os.File, video *youtube.Video, format *youtube.Format) error {
-	stream, size, err := dl.GetStreamContext(ctx, video, format)
-	if err != nil {
+// SortFormats sorts the formats like FormatList.Sort, but orders equally ranked formats by itag.
+// This way the same format gets selected regardless of the order YouTube returned them in.
+// Among formats of the same resolution and frame rate mp4 comes before webm, unless PreferFreeFormats is set.
+// Formats with PreferFPS come first among the formats of the same resolution.
+func (dl *Downloader) SortFormats(formats youtube.FormatList) {
+	sort.SliceStable(formats, func(i, j int) bool {
+		return formats[i].ItagNo < formats[j].ItagNo
+	})
+
+	// FormatList.Sort is stable and keeps the itag order for equal formats
+	formats.Sort()
+
+	// formats are sorted by width and frame rate already
+	sort.SliceStable(formats, func(i, j int) bool {
+		a, b := &formats[i], &formats[j]
+
+		if a.Width != b.Width {
+			return a.Width > b.Width
+		}
+
+		if dl.PreferFPS > 0 && (a.FPS == dl.PreferFPS) != (b.FPS == dl.PreferFPS) {
+			return a.FPS == dl.PreferFPS
+		}
+
+		if a.FPS != b.FPS {
+			return a.FPS > b.FPS
+		}
+
+		return dl.containerRank(a) < dl.containerRank(b)
+	})
+}
+
+// containerRank ranks mp4 before webm for compatibility, or the other way round with PreferFreeFormats
+func (dl *Downloader) containerRank(format *youtube.Format) int {
+	free := strings.Contains(format.MimeType, "webm")
+	if free == dl.PreferFreeFormats {
+		return 0
+	}
+
+	return 1
+}
+
+// videoDLWorker downloads the stream of the format into out and returns the number of bytes written.
+// With RefreshPlayerOn403 and TryAlternateHosts a failed download is restarted.
+// Failures are DownloadErrors of KindStream, cancellations are returned as they are.
+func (dl *Downloader) videoDLWorker(ctx context.Context, out *os.File, video *youtube.Video, format *youtube.Format) (_ int64, err error) {
+	defer func() {
+		if !errors.Is(err, context.Canceled) {
+			err = downloadError(KindStream, err)
+		}
+	}()
+
+	if out != os.Stdout {
+		if err := dl.checkSpace(filepath.Dir(out.Name()), format.ContentLength); err != nil {
+			return 0, err
+		}
+	}
+
+	written, err := dl.stream(ctx, out, video, format)
+
+	if isForbidden(err) {
+		// the cached video may have stale URLs
+		dl.invalidateCachedVideo(video.ID)
+	}
+
+	if err != nil && dl.RefreshPlayerOn403 && isForbidden(err) && ctx.Err() == nil {
+		youtube.Logger.Warn("download forbidden, retrying with a fresh player", "id", video.ID, "itag", format.ItagNo)
+
+		dl.ClearPlayerCache()
+		if err = rewind(out); err != nil {
+			return 0, err
+		}
+		written, err = dl.stream(ctx, out, video, format)
+	}
+
+	if err == nil || !dl.TryAlternateHosts || ctx.Err() != nil {
+		return written, err
+	}
+
+	for _, streamURL := range alternateHosts(format.URL) {
+		youtube.Logger.Warn("download failed, retrying from an alternate host", "id", video.ID, "itag", format.ItagNo, "error", err)
+
+		if err = rewind(out); err != nil {
+			return 0, err
+		}
+
+		alternate := *format
+		alternate.URL = streamURL
+
+		written, err = dl.stream(ctx, out, video, &alternate)
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+	}
+
+	return written, err
+}
+
+// isForbidden reports whether the error is caused by a HTTP 403 response
+func isForbidden(err error) bool {
+	var statusErr youtube.ErrUnexpectedStatusCode
+	return errors.As(err, &statusErr) && statusErr == http.StatusForbidden
+}
+
+// rewind empties the file for downloading it again
+func rewind(out *os.File) error {
+	if err := out.Truncate(0); err != nil {
 		return err
 	}
 
+	_, err := out.Seek(0, io.SeekStart)
+	return err
+}
+
+// streamToFile copies the stream of the format from offset on into out and returns the number of bytes written
+func (dl *Downloader) streamToFile(ctx context.Context, out *os.File, video *youtube.Video, format *youtube.Format, offset int64) (int64, error) {
+	// only streams of known size get downloaded in chunks
+	if dl.WarmConnections && format.ContentLength > 0 {
+		dl.warmConnections(ctx, video, format)
+	}
+
+	stream, size, err := dl.GetStreamFromContext(ctx, video, format, offset)
+	if err != nil {
+		return 0, err
+	}
+	defer stream.Close()
+
+	source := limitRate(ctx, stream, dl.getRateLimiter())
+	total := size
+
+	if dl.TestMode {
+		limit := testModeBytes(format) - offset
+		source = io.LimitReader(source, limit)
+		if total == 0 || total > limit {
+			total = limit
+		}
+	}
+
 	prog := &progress{
-		contentLength: float64(size),
-	}
-
-	// create progress bar
-	progress := mpb.New(mpb.WithWidth(64))
-	bar := progress.AddBar(
-		int64(prog.contentLength),
-
-		mpb.PrependDecorators(
-			decor.CountersKibiByte("% .2f / % .2f"),
-			decor.Percentage(decor.WCSyncSpace),
-		),
-		mpb.AppendDecorators(
-			decor.EwmaETA(decor.ET_STYLE_GO, 90),
-			decor.Name(" ] "),
-			decor.EwmaSpeed(decor.UnitKiB, "% .2f", 60),
-		),
-	)
+		contentLength: float64(total),
+	}
 
-	reader := bar.ProxyReader(stream)
-	mw := io.MultiWriter(out, prog)
-	_, err = io.Copy(mw, reader)
+	reporter := dl.progressReporter(video, format)
+	reporter.Start(total)
+
+	mw := io.MultiWriter(out, prog, progressWriter{reporter})
+	written, err := io.CopyBuffer(mw, source, make([]byte, dl.getCopyBufferSize()))
+	reporter.Finish()
+	if errors.Is(err, youtube.ErrInvalidChunkSize) {
+		return written, fmt.Errorf("%w: %w", ErrIncompleteDownload, err)
+	}
 	if err != nil {
-		return err
+		return written, err
+	}
+
+	if size > 0 && written != size && !dl.TestMode && !dl.SkipSizeVerification {
+		return written, fmt.Errorf("%w: wrote %d of %d bytes from offset %d", ErrIncompleteDownload, written, size, offset)
+	}
+
+	// without a size the download can only be verified by inspecting the file
+	if size == 0 && dl.VerifyWithProbe && out != os.Stdout {
+		return written, dl.verifyWithProbe(ctx, out.Name(), format)
+	}
+
+	return written, nil
+}
+
+// Stdout is the output file of Download writing the stream to os.Stdout, e.g. for piping it into a player.
+// Post-processors are not run on it.
+const Stdout = "-"
+
+// partialSuffix is appended to the names of incomplete downloads
+const partialSuffix = ".part"
+
+// defaultCopyBufferSize is the default of CopyBufferSize, see BenchmarkCopyBuffer
+const defaultCopyBufferSize = 256 * youtube.Size1Kb
+
+func (dl *Downloader) getCopyBufferSize() int {
+	if dl.CopyBufferSize > 0 {
+		return dl.CopyBufferSize
+	}
+
+	return defaultCopyBufferSize
+}
+
+// resolution returns the resolution of a video format like "1080p", or "" for audio formats
+func resolution(format *youtube.Format) string {
+	if format.QualityLabel != "" {
+		return format.QualityLabel
+	}
+
+	if format.Height > 0 {
+		return fmt.Sprintf("%dp", format.Height)
+	}
+
+	return ""
+}
+
+// testModeDuration is the length of the samples downloaded in TestMode
+const testModeDuration = 5 * time.Second
+
+// testModeBytes estimates the number of bytes needed for testModeDuration of the format
+func testModeBytes(format *youtube.Format) int64 {
+	bitrate := format.AverageBitrate
+	if bitrate == 0 {
+		bitrate = format.Bitrate
+	}
+
+	if bitrate == 0 {
+		return youtube.Size1Mb
 	}
 
-	progress.Wait()
-	return nil
+	return int64(float64(bitrate) * testModeDuration.Seconds() / 8)
 }
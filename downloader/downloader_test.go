@@ -2,7 +2,16 @@ package downloader
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -42,7 +51,8 @@ func TestDownload_FirstStream(t *testing.T) {
 	assert.GreaterOrEqual(len(video.Formats), 18)
 
 	if assert.Greater(len(video.Formats), 0) {
-		assert.NoError(testDownloader.Download(ctx, video, &video.Formats[0], ""))
+		_, err := testDownloader.Download(ctx, video, &video.Formats[0], "")
+		assert.NoError(err)
 	}
 }
 
@@ -69,7 +79,7 @@ func TestYoutube_DownloadWithHighQualityFails(t *testing.T) {
 				Formats: tt.formats,
 			}
 
-			err := testDownloader.DownloadComposite(context.Background(), "", video, "hd1080", "")
+			_, err := testDownloader.DownloadComposite(context.Background(), "", video, "hd1080", "")
 			assert.EqualError(t, err, tt.message)
 		})
 	}
@@ -101,7 +111,7 @@ func Test_getVideoAudioFormats(t *testing.T) {
 		{ItagNo: 249, MimeType: "audio/webm; codecs=\"opus\"", Quality: "tiny", Bitrate: 72862, FPS: 0, Width: 0, Height: 0, LastModified: "1540474783513282", ContentLength: 24839529, QualityLabel: "", ProjectionType: "RECTANGULAR", AverageBitrate: 55914, AudioQuality: "AUDIO_QUALITY_LOW", ApproxDurationMs: "3553941", AudioSampleRate: "48000", AudioChannels: 2},
 	}}
 	{
-		videoFormat, audioFormat, err := getVideoAudioFormats(v, "hd720", "mp4")
+		videoFormat, audioFormat, err := testDownloader.getVideoAudioFormats(v, "hd720", "mp4")
 		require.NoError(err)
 		require.NotNil(videoFormat)
 		require.Equal(398, videoFormat.ItagNo)
@@ -110,7 +120,7 @@ func Test_getVideoAudioFormats(t *testing.T) {
 	}
 
 	{
-		videoFormat, audioFormat, err := getVideoAudioFormats(v, "large", "webm")
+		videoFormat, audioFormat, err := testDownloader.getVideoAudioFormats(v, "large", "webm")
 		require.NoError(err)
 		require.NotNil(videoFormat)
 		require.Equal(244, videoFormat.ItagNo)
@@ -118,3 +128,722 @@ func Test_getVideoAudioFormats(t *testing.T) {
 		require.Equal(251, audioFormat.ItagNo)
 	}
 }
+
+func TestDownloader_SortFormats(t *testing.T) {
+	require := require.New(t)
+
+	formats := youtube.FormatList{
+		{ItagNo: 251, MimeType: "audio/webm; codecs=\"opus\"", Bitrate: 1000, AudioChannels: 2},
+		{ItagNo: 250, MimeType: "audio/webm; codecs=\"opus\"", Bitrate: 1000, AudioChannels: 2},
+		{ItagNo: 140, MimeType: "audio/mp4; codecs=\"mp4a.40.2\"", Bitrate: 500, AudioChannels: 2},
+	}
+	reversed := youtube.FormatList{formats[2], formats[1], formats[0]}
+
+	testDownloader.SortFormats(formats)
+	testDownloader.SortFormats(reversed)
+
+	require.Equal(formats, reversed)
+	require.Equal(140, formats[0].ItagNo)
+	require.Equal(250, formats[1].ItagNo)
+	require.Equal(251, formats[2].ItagNo)
+}
+
+func TestDownloader_SortFormats_preferFPS(t *testing.T) {
+	formats := youtube.FormatList{
+		{ItagNo: 299, MimeType: "video/mp4; codecs=\"avc1.64002a\"", Width: 1920, FPS: 60},
+		{ItagNo: 137, MimeType: "video/mp4; codecs=\"avc1.640028\"", Width: 1920, FPS: 30},
+		{ItagNo: 298, MimeType: "video/mp4; codecs=\"avc1.4d4020\"", Width: 1280, FPS: 60},
+		{ItagNo: 136, MimeType: "video/mp4; codecs=\"avc1.4d401f\"", Width: 1280, FPS: 30},
+		{ItagNo: 135, MimeType: "video/mp4; codecs=\"avc1.4d401f\"", Width: 854, FPS: 30},
+	}
+
+	dl := Downloader{}
+	dl.SortFormats(formats)
+	assert.Equal(t, []int{299, 137, 298, 136, 135}, itags(formats))
+
+	dl.PreferFPS = 30
+	dl.SortFormats(formats)
+	assert.Equal(t, []int{137, 299, 136, 298, 135}, itags(formats))
+
+	// falls back to other frame rates
+	dl.PreferFPS = 50
+	dl.SortFormats(formats)
+	assert.Equal(t, []int{299, 137, 298, 136, 135}, itags(formats))
+}
+
+func TestDownloader_SortFormats_preferFreeFormats(t *testing.T) {
+	formats := youtube.FormatList{
+		{ItagNo: 248, MimeType: "video/webm; codecs=\"vp9\"", Width: 1920, FPS: 30},
+		{ItagNo: 137, MimeType: "video/mp4; codecs=\"avc1.640028\"", Width: 1920, FPS: 30},
+		{ItagNo: 303, MimeType: "video/webm; codecs=\"vp9\"", Width: 1920, FPS: 60},
+		{ItagNo: 251, MimeType: "audio/webm; codecs=\"opus\"", Bitrate: 1000, AudioChannels: 2},
+		{ItagNo: 140, MimeType: "audio/mp4; codecs=\"mp4a.40.2\"", Bitrate: 500, AudioChannels: 2},
+	}
+
+	dl := Downloader{}
+	dl.SortFormats(formats)
+	assert.Equal(t, []int{303, 137, 248, 140, 251}, itags(formats))
+
+	dl.PreferFreeFormats = true
+	dl.SortFormats(formats)
+	assert.Equal(t, []int{303, 248, 137, 251, 140}, itags(formats))
+}
+
+func itags(formats youtube.FormatList) (result []int) {
+	for _, f := range formats {
+		result = append(result, f.ItagNo)
+	}
+	return result
+}
+
+func Test_testModeBytes(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.EqualValues(625000, testModeBytes(&youtube.Format{Bitrate: 1000000}))
+	assert.EqualValues(312500, testModeBytes(&youtube.Format{Bitrate: 1000000, AverageBitrate: 500000}))
+	assert.EqualValues(youtube.Size1Mb, testModeBytes(&youtube.Format{}))
+}
+
+func TestDownloader_getOutputFile(t *testing.T) {
+	video := &youtube.Video{Title: "My Video: part 1"}
+	format := &youtube.Format{MimeType: `video/mp4; codecs="avc1.640028"`, QualityLabel: "1080p60", Height: 1080}
+
+	tests := []struct {
+		name             string
+		resolutionSuffix bool
+		format           *youtube.Format
+		want             string
+	}{
+		{"default", false, format, "My Video part 1.mp4"},
+		{"resolution", true, format, "My Video part 1 [1080p60].mp4"},
+		{"height", true, &youtube.Format{MimeType: "video/webm", Height: 720}, "My Video part 1 [720p].webm"},
+		{"audio", true, &youtube.Format{MimeType: "audio/mp4"}, "My Video part 1.m4a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dl := Downloader{ResolutionSuffix: tt.resolutionSuffix}
+			got, err := dl.getOutputFile(context.Background(), video, tt.format, "")
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDownloader_getOutputFile_directories(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(file, nil, 0o644))
+
+	video := &youtube.Video{Title: "Title"}
+	format := &youtube.Format{MimeType: "video/mp4"}
+
+	t.Run("output dir is a file", func(t *testing.T) {
+		dl := Downloader{OutputDir: file}
+		_, err := dl.getOutputFile(context.Background(), video, format, "")
+		assert.ErrorIs(t, err, ErrOutputDirNotDirectory)
+	})
+
+	t.Run("parent of output dir is a file", func(t *testing.T) {
+		dl := Downloader{OutputDir: filepath.Join(file, "nested")}
+		_, err := dl.getOutputFile(context.Background(), video, format, "")
+		assert.ErrorIs(t, err, ErrOutputDirNotDirectory)
+	})
+
+	t.Run("missing output dir", func(t *testing.T) {
+		dl := Downloader{OutputDir: filepath.Join(dir, "a", "b")}
+		got, err := dl.getOutputFile(context.Background(), video, format, "")
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(dir, "a", "b", "Title.mp4"), got)
+		assert.DirExists(t, filepath.Join(dir, "a", "b"))
+	})
+
+	t.Run("output file in a missing directory", func(t *testing.T) {
+		dl := Downloader{OutputDir: dir}
+		got, err := dl.getOutputFile(context.Background(), video, format, filepath.Join("c", "d", "video.mp4"))
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(dir, "c", "d", "video.mp4"), got)
+		assert.DirExists(t, filepath.Join(dir, "c", "d"))
+	})
+
+	t.Run("output file in a file", func(t *testing.T) {
+		dl := Downloader{}
+		_, err := dl.getOutputFile(context.Background(), video, format, filepath.Join(file, "video.mp4"))
+		assert.ErrorIs(t, err, ErrOutputDirNotDirectory)
+	})
+}
+
+func TestDownloader_videoDLWorker_refreshPlayerOn403(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("video")) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	video := &youtube.Video{ID: "BaW_jenozKc"}
+	format := &youtube.Format{URL: server.URL}
+
+	for _, refresh := range []bool{false, true} {
+		requests.Store(0)
+		out, err := os.Create(filepath.Join(t.TempDir(), "video.mp4"))
+		require.NoError(t, err)
+		defer out.Close()
+
+		dl := Downloader{ProgressOutput: io.Discard, RefreshPlayerOn403: refresh}
+		written, err := dl.videoDLWorker(context.Background(), out, video, format)
+
+		if !refresh {
+			assert.ErrorIs(t, err, youtube.ErrUnexpectedStatusCode(http.StatusForbidden))
+			continue
+		}
+
+		require.NoError(t, err)
+		assert.EqualValues(t, 5, written)
+		assert.EqualValues(t, 2, requests.Load())
+	}
+}
+
+func TestDownloader_videoDLWorker_canceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("video")) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	out, err := os.Create(filepath.Join(t.TempDir(), "video.mp4"))
+	require.NoError(t, err)
+	defer out.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	dl := Downloader{ProgressOutput: io.Discard}
+	_, err = dl.videoDLWorker(ctx, out, &youtube.Video{ID: "BaW_jenozKc"}, &youtube.Format{URL: server.URL})
+	assert.ErrorIs(t, err, context.Canceled)
+	var dlErr *DownloadError
+	assert.False(t, errors.As(err, &dlErr), "cancellation is not a stream failure")
+}
+
+// BenchmarkCopyBuffer copies a stream like the chunked download into a file.
+// Larger buffers need fewer write syscalls than the 32 KiB of io.Copy, beyond 256 KiB there is no gain:
+//
+//	BenchmarkCopyBuffer/32KiB     10    14349791 ns/op    4676.64 MB/s
+//	BenchmarkCopyBuffer/256KiB    10    11388799 ns/op    5892.53 MB/s
+//	BenchmarkCopyBuffer/1MiB      10    12969948 ns/op    5174.18 MB/s
+func BenchmarkCopyBuffer(b *testing.B) {
+	const size = 64 * youtube.Size1Mb
+	chunk := make([]byte, youtube.Size10Mb)
+
+	for _, bufferSize := range []int{32 * youtube.Size1Kb, 256 * youtube.Size1Kb, youtube.Size1Mb} {
+		name := fmt.Sprintf("%dKiB", bufferSize/youtube.Size1Kb)
+		if bufferSize >= youtube.Size1Mb {
+			name = fmt.Sprintf("%dMiB", bufferSize/youtube.Size1Mb)
+		}
+
+		b.Run(name, func(b *testing.B) {
+			out, err := os.CreateTemp(b.TempDir(), "youtube_*.mp4")
+			require.NoError(b, err)
+			defer out.Close()
+
+			b.SetBytes(size)
+			for i := 0; i < b.N; i++ {
+				// the client hands out the chunks through a pipe
+				r, w := io.Pipe()
+				go func() {
+					for written := int64(0); written < size; written += int64(len(chunk)) {
+						w.Write(chunk[:min(int64(len(chunk)), size-written)]) //nolint:errcheck
+					}
+					w.Close()
+				}()
+
+				_, err = out.Seek(0, io.SeekStart)
+				require.NoError(b, err)
+
+				mw := io.MultiWriter(out, &progress{contentLength: size})
+				_, err = io.CopyBuffer(mw, r, make([]byte, bufferSize))
+				require.NoError(b, err)
+			}
+		})
+	}
+}
+
+func TestDownloader_Download_skipExisting(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte("video")) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	video := &youtube.Video{ID: "BaW_jenozKc", Title: "Title"}
+	format := &youtube.Format{URL: server.URL, MimeType: "video/mp4", ContentLength: 5}
+
+	dl := Downloader{OutputDir: t.TempDir(), ProgressOutput: io.Discard, SkipExisting: true}
+	path := filepath.Join(dl.OutputDir, "Title.mp4")
+
+	// truncated files are downloaded again
+	require.NoError(t, os.WriteFile(path, []byte("vid"), 0o644))
+	result, err := dl.Download(context.Background(), video, format, "")
+	require.NoError(t, err)
+	assert.False(t, result.Skipped)
+	assert.EqualValues(t, 1, requests.Load())
+
+	result, err = dl.Download(context.Background(), video, format, "")
+	require.NoError(t, err)
+	assert.True(t, result.Skipped)
+	assert.Equal(t, path, result.Path)
+	assert.EqualValues(t, 1, requests.Load())
+}
+
+func TestDownloader_Download_verifySize(t *testing.T) {
+	const content = "vid"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var start, end int
+		_, err := fmt.Sscanf(r.URL.Query().Get("range"), "%d-%d", &start, &end)
+		require.NoError(t, err)
+
+		// the stream ends before the reported length
+		w.Write([]byte(content[start:min(end+1, len(content))])) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	video := &youtube.Video{ID: "BaW_jenozKc", Title: "Title"}
+	format := &youtube.Format{URL: server.URL, MimeType: "video/mp4", ContentLength: 5}
+
+	dl := Downloader{OutputDir: t.TempDir(), ProgressOutput: io.Discard}
+	dl.ChunkSize = 2
+	dl.MaxRoutines = 1
+	path := filepath.Join(dl.OutputDir, "Title.mp4")
+
+	_, err := dl.Download(context.Background(), video, format, "")
+	require.ErrorIs(t, err, ErrIncompleteDownload)
+	assert.NoFileExists(t, path)
+
+	// the complete chunks are kept
+	data, err := os.ReadFile(path + ".part")
+	require.NoError(t, err)
+	assert.Equal(t, "vi", string(data))
+}
+
+func TestDownloader_uniqueName(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Title.v2.m4v")
+
+	dl := Downloader{UniqueNames: true}
+	assert.Equal(t, path, dl.uniqueName(path))
+
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	assert.Equal(t, filepath.Join(dir, "Title.v2 (1).m4v"), dl.uniqueName(path))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Title.v2 (1).m4v"), nil, 0o644))
+	assert.Equal(t, filepath.Join(dir, "Title.v2 (2).m4v"), dl.uniqueName(path))
+
+	// existing files are kept instead
+	dl.SkipExisting = true
+	assert.Equal(t, path, dl.uniqueName(path))
+
+	// overwriting is the default
+	assert.Equal(t, path, (&Downloader{}).uniqueName(path))
+}
+
+func TestDownloader_uniqueName_part(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Title.mp4")
+
+	// an incomplete download of the name
+	require.NoError(t, os.WriteFile(path+".part", []byte("vi"), 0o644))
+
+	dl := Downloader{UniqueNames: true}
+	name := dl.uniqueName(path)
+	assert.Equal(t, filepath.Join(dir, "Title (1).mp4"), name)
+	assert.FileExists(t, name+".part", "reserved")
+
+	dl.releaseName(name)
+	assert.NoFileExists(t, name+".part")
+
+	dl.releaseName(path)
+	assert.FileExists(t, path+".part", "not empty")
+}
+
+func TestDownloader_uniqueName_concurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Title.mp4")
+	dl := Downloader{UniqueNames: true}
+
+	const downloads = 10
+	names := make(chan string, downloads)
+
+	var wg sync.WaitGroup
+	for i := 0; i < downloads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			names <- dl.uniqueName(path)
+		}()
+	}
+	wg.Wait()
+	close(names)
+
+	unique := map[string]bool{}
+	for name := range names {
+		unique[name] = true
+	}
+	assert.Len(t, unique, downloads, "each download gets its own name")
+}
+
+func TestDownloader_Download_UniqueNames(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("video")) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	video := &youtube.Video{ID: "BaW_jenozKc", Title: "Title"}
+	format := &youtube.Format{URL: server.URL, MimeType: "video/mp4"}
+
+	dl := Downloader{OutputDir: t.TempDir(), ProgressOutput: io.Discard, UniqueNames: true}
+	require.NoError(t, os.WriteFile(filepath.Join(dl.OutputDir, "Title.mp4"), []byte("other"), 0o644))
+
+	result, err := dl.Download(context.Background(), video, format, "")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dl.OutputDir, "Title (1).mp4"), result.Path)
+
+	// the reservation of the name is released with TempDir as well
+	dl.TempDir = t.TempDir()
+	result, err = dl.Download(context.Background(), video, format, "")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dl.OutputDir, "Title (2).mp4"), result.Path)
+	assert.NoFileExists(t, result.Path+".part")
+
+	data, err := os.ReadFile(filepath.Join(dl.OutputDir, "Title.mp4"))
+	require.NoError(t, err)
+	assert.Equal(t, "other", string(data))
+}
+
+func TestDownloader_Download_cancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("vi")) //nolint:errcheck
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	video := &youtube.Video{ID: "BaW_jenozKc", Title: "Title"}
+	format := &youtube.Format{URL: server.URL, MimeType: "video/mp4"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	dl := Downloader{OutputDir: t.TempDir(), ProgressOutput: io.Discard}
+	_, err := dl.Download(ctx, video, format, "")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// neither the partial file nor one with the final name is left
+	entries, err := os.ReadDir(dl.OutputDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestDownloader_DownloadComposite_streamFails(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+	fakeFFmpeg(t, "exit 0\n")
+
+	videoServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("vi")) //nolint:errcheck
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer videoServer.Close()
+
+	audioServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer audioServer.Close()
+
+	video := &youtube.Video{ID: "BaW_jenozKc", Title: "Title", Formats: youtube.FormatList{
+		{ItagNo: 137, URL: videoServer.URL, MimeType: `video/mp4; codecs="avc1.640028"`, Quality: "hd1080", QualityLabel: "1080p"},
+		{ItagNo: 140, URL: audioServer.URL, MimeType: `audio/mp4; codecs="mp4a.40.2"`, AudioChannels: 2},
+	}}
+
+	dl := Downloader{OutputDir: t.TempDir(), ProgressOutput: io.Discard}
+
+	done := make(chan error)
+	go func() {
+		_, err := dl.DownloadComposite(context.Background(), "", video, "hd1080", "")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		// the failed audio stream cancels the video stream
+		assert.ErrorIs(t, err, youtube.ErrUnexpectedStatusCode(http.StatusNotFound))
+	case <-time.After(5 * time.Second):
+		t.Fatal("the video stream was not cancelled")
+	}
+
+	// both temporary files are removed
+	entries, err := os.ReadDir(dl.OutputDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestDownloader_Download_stdout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "5")
+		w.Write([]byte("video")) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	video := &youtube.Video{ID: "BaW_jenozKc", Title: "Title"}
+	format := &youtube.Format{URL: server.URL, MimeType: "video/mp4", ContentLength: 5}
+
+	// the parts of concurrent downloads can't be written to a pipe
+	dl := Downloader{OutputDir: t.TempDir(), ProgressOutput: io.Discard, Concurrency: 4}
+	result, err := dl.Download(context.Background(), video, format, Stdout)
+	w.Close()
+	require.NoError(t, err)
+	assert.Equal(t, Stdout, result.Path)
+	assert.EqualValues(t, 5, result.Bytes)
+
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "video", string(data))
+
+	// nothing is written to the output directory
+	entries, err := os.ReadDir(dl.OutputDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	_, err = dl.DownloadComposite(context.Background(), Stdout, video, "hd1080", "")
+	assert.ErrorIs(t, err, ErrStdoutUnsupported)
+}
+
+func TestDownloader_Download_partial(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "5")
+		w.Write([]byte("vi")) //nolint:errcheck
+		w.(http.Flusher).Flush()
+		<-release
+		w.Write([]byte("deo")) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	video := &youtube.Video{ID: "BaW_jenozKc", Title: "Title"}
+	format := &youtube.Format{URL: server.URL, MimeType: "video/mp4", ContentLength: 5}
+
+	dl := Downloader{OutputDir: t.TempDir(), ProgressOutput: io.Discard}
+	path := filepath.Join(dl.OutputDir, "Title.mp4")
+
+	done := make(chan error)
+	go func() {
+		_, err := dl.Download(context.Background(), video, format, "")
+		done <- err
+	}()
+
+	// while downloading only the partial file exists
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(path + ".part")
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.NoFileExists(t, path)
+
+	close(release)
+	require.NoError(t, <-done)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "video", string(data))
+	assert.NoFileExists(t, path+".part")
+}
+
+func TestDownloader_DownloadComposite_mergeFails(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+
+	// fake ffmpeg failing on every merge
+	fakeFFmpeg(t, "echo 'unknown codec' >&2\nexit 1\n")
+
+	video := compositeTestVideo(t)
+	dl := Downloader{OutputDir: t.TempDir(), ProgressOutput: io.Discard}
+
+	_, err := dl.DownloadComposite(context.Background(), "", video, "hd1080", "")
+
+	var mergeErr *ErrMergeFailed
+	require.ErrorAs(t, err, &mergeErr)
+	assert.Equal(t, filepath.Join(dl.OutputDir, "Title.f137.mp4"), mergeErr.VideoFile)
+	assert.Equal(t, filepath.Join(dl.OutputDir, "Title.f140.m4a"), mergeErr.AudioFile)
+	assert.Contains(t, mergeErr.Command, "ffmpeg -y -loglevel warning -i")
+	assert.Contains(t, err.Error(), "unknown codec")
+
+	var dlErr *DownloadError
+	require.ErrorAs(t, err, &dlErr)
+	assert.Equal(t, KindMerge, dlErr.Kind)
+
+	data, err := os.ReadFile(mergeErr.VideoFile)
+	require.NoError(t, err)
+	assert.Equal(t, "video", string(data))
+
+	data, err = os.ReadFile(mergeErr.AudioFile)
+	require.NoError(t, err)
+	assert.Equal(t, "audio", string(data))
+
+	entries, err := os.ReadDir(dl.OutputDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2, "only the kept streams are left")
+}
+
+func TestDownloader_DownloadComposite_KeepStreams(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+
+	// fake ffmpeg writing the output file, the last argument
+	fakeFFmpeg(t, "eval out=\\${$#}\necho merged > \"$out\"\n")
+
+	video := compositeTestVideo(t)
+	dl := Downloader{OutputDir: t.TempDir(), TempDir: t.TempDir(), ProgressOutput: io.Discard, KeepStreams: true}
+
+	_, err := dl.DownloadComposite(context.Background(), "Video.mp4", video, "hd1080", "")
+	require.NoError(t, err)
+
+	assert.FileExists(t, filepath.Join(dl.OutputDir, "Video.mp4"))
+	assert.FileExists(t, filepath.Join(dl.OutputDir, "Video.f137.mp4"))
+	assert.FileExists(t, filepath.Join(dl.OutputDir, "Video.f140.m4a"))
+}
+
+func TestDownloader_DownloadComposite_PrintFFmpegCommands(t *testing.T) {
+	// ffmpeg must not be run
+	t.Setenv("PATH", t.TempDir())
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+
+	stderr := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = stderr }()
+
+	video := compositeTestVideo(t)
+	video.Title = "It's a video"
+	dl := Downloader{OutputDir: t.TempDir(), TempDir: t.TempDir(), ProgressOutput: io.Discard, PrintFFmpegCommands: true}
+
+	_, err = dl.DownloadComposite(context.Background(), "", video, "hd1080", "")
+	w.Close()
+	require.NoError(t, err)
+
+	printed, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	// the command merges the kept streams into the output, not temporary files
+	output := filepath.Join(dl.OutputDir, "It's a video.mp4")
+	assert.Equal(t, commandLine("ffmpeg", mergeArgs(streamFileName(output, &video.Formats[0]), streamFileName(output, &video.Formats[1]), "", output, false, nil))+"\n", string(printed))
+	assert.FileExists(t, streamFileName(output, &video.Formats[0]))
+	assert.FileExists(t, streamFileName(output, &video.Formats[1]))
+
+	entries, err := os.ReadDir(dl.TempDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestDownloader_DownloadCompositeFormats(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+	fakeFFmpeg(t, "eval out=\\${$#}\necho merged > \"$out\"\n")
+
+	video := compositeTestVideo(t)
+	// a format quality "hd1080" wouldn't select
+	video.Formats[0].Quality, video.Formats[0].QualityLabel = "hd720", "720p"
+
+	dl := Downloader{OutputDir: t.TempDir(), ProgressOutput: io.Discard}
+	result, err := dl.DownloadCompositeFormats(context.Background(), "Video.mp4", video, &video.Formats[0], &video.Formats[1])
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dl.OutputDir, "Video.mp4"), result.Path)
+	assert.Equal(t, 137, result.Itag)
+	assert.Equal(t, 140, result.AudioItag)
+	assert.EqualValues(t, 10, result.Bytes)
+
+	_, err = dl.DownloadCompositeFormats(context.Background(), "", video, &video.Formats[0], nil)
+	assert.ErrorIs(t, err, youtube.ErrNoFormat)
+}
+
+func TestDownloader_DownloadCompositeByItags(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+	fakeFFmpeg(t, "eval out=\\${$#}\necho merged > \"$out\"\n")
+
+	video := compositeTestVideo(t)
+	dl := Downloader{OutputDir: t.TempDir(), ProgressOutput: io.Discard}
+
+	result, err := dl.DownloadCompositeByItags(context.Background(), "Video.mp4", video, 137, 140)
+	require.NoError(t, err)
+	assert.Equal(t, 137, result.Itag)
+	assert.Equal(t, 140, result.AudioItag)
+}
+
+func Test_compositeFormatsByItags(t *testing.T) {
+	video := &youtube.Video{Formats: youtube.FormatList{
+		{ItagNo: 18, MimeType: `video/mp4; codecs="avc1.42001E, mp4a.40.2"`, AudioChannels: 2},
+		{ItagNo: 137, MimeType: `video/mp4; codecs="avc1.640028"`},
+		{ItagNo: 140, MimeType: `audio/mp4; codecs="mp4a.40.2"`, AudioChannels: 2},
+		{ItagNo: 251, MimeType: `audio/webm; codecs="opus"`, AudioChannels: 2},
+	}}
+
+	videoFormat, audioFormat, err := compositeFormatsByItags(video, 137, 251)
+	require.NoError(t, err)
+	assert.Equal(t, 137, videoFormat.ItagNo)
+	assert.Equal(t, 251, audioFormat.ItagNo)
+
+	tests := []struct {
+		videoItag, audioItag int
+		want                 string
+	}{
+		{22, 140, "the video has no format with itag 22"},
+		{137, 141, "the video has no format with itag 141"},
+		{140, 137, "itag 140 is the audio and itag 137 the video, swap them"},
+		{140, 251, `format 140 is not a video format, it is audio/mp4; codecs="mp4a.40.2"`},
+		{18, 140, "format 18 already has audio, download it by its itag alone"},
+		{137, 18, `format 18 is not an audio only format, it is video/mp4; codecs="avc1.42001E, mp4a.40.2"`},
+	}
+	for _, tt := range tests {
+		_, _, err := compositeFormatsByItags(video, tt.videoItag, tt.audioItag)
+		assert.EqualError(t, err, tt.want)
+
+		var dlErr *DownloadError
+		require.ErrorAs(t, err, &dlErr)
+		assert.Equal(t, KindNoFormat, dlErr.Kind)
+	}
+}
+
+// compositeTestVideo returns a video with a video and an audio format served by test servers
+func compositeTestVideo(t *testing.T) *youtube.Video {
+	videoServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("video")) //nolint:errcheck
+	}))
+	t.Cleanup(videoServer.Close)
+
+	audioServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("audio")) //nolint:errcheck
+	}))
+	t.Cleanup(audioServer.Close)
+
+	return &youtube.Video{ID: "BaW_jenozKc", Title: "Title", Formats: youtube.FormatList{
+		{ItagNo: 137, URL: videoServer.URL, MimeType: `video/mp4; codecs="avc1.640028"`, Quality: "hd1080", QualityLabel: "1080p"},
+		{ItagNo: 140, URL: audioServer.URL, MimeType: `audio/mp4; codecs="mp4a.40.2"`, AudioChannels: 2},
+	}}
+}
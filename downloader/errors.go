@@ -0,0 +1,247 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+var (
+	// ErrFFmpegNotFound is returned when the ffmpeg binary can't be located or executed
+	ErrFFmpegNotFound = errors.New("ffmpeg not found, please check it is installed correctly")
+
+	// ErrIncompleteDownload is returned when a downloaded file fails verification
+	ErrIncompleteDownload = errors.New("incomplete download")
+
+	// ErrOutputDirNotDirectory is returned when the output directory, or one of its parents, is a file
+	ErrOutputDirNotDirectory = errors.New("output directory is not a directory")
+
+	// ErrStdoutUnsupported is returned when a download writing its output with ffmpeg gets Stdout as output file
+	ErrStdoutUnsupported = errors.New("can't write to stdout")
+
+	// ErrNoThumbnail is returned when none of the thumbnails of a video can be downloaded
+	ErrNoThumbnail = errors.New("no thumbnail found")
+
+	// ErrNotLive is returned when DownloadLive gets a video that isn't an ongoing live stream
+	ErrNotLive = errors.New("the video is not a live stream")
+
+	// ErrInvalidMedia is returned when a finished file fails the ffprobe check of VerifyMedia
+	ErrInvalidMedia = errors.New("invalid media file")
+)
+
+// ErrorKind classifies the errors of downloads, see DownloadError
+type ErrorKind int
+
+const (
+	KindNoFormat      ErrorKind = iota + 1 // no format matches the quality, codecs, size or frame rate
+	KindFFmpegMissing                      // ffmpeg can't be found or executed
+	KindStream                             // downloading a stream failed, e.g. because of the network
+	KindMerge                              // merging or converting with ffmpeg failed
+	KindDiskFull                           // the file system has no space left for the download
+)
+
+func (kind ErrorKind) String() string {
+	switch kind {
+	case KindNoFormat:
+		return "no format"
+	case KindFFmpegMissing:
+		return "ffmpeg missing"
+	case KindStream:
+		return "stream"
+	case KindMerge:
+		return "merge"
+	case KindDiskFull:
+		return "disk full"
+	}
+
+	return fmt.Sprintf("ErrorKind(%d)", int(kind))
+}
+
+// DownloadError is returned by downloads to tell the kind of a failure with errors.As.
+// Its message is the one of the wrapped error.
+type DownloadError struct {
+	Kind ErrorKind
+	Err  error
+}
+
+func (err DownloadError) Error() string {
+	return err.Err.Error()
+}
+
+func (err DownloadError) Unwrap() error {
+	return err.Err
+}
+
+// downloadError wraps err into a DownloadError of the kind, unless it already is one.
+// Errors of missing ffmpeg and space are of that kind regardless.
+func downloadError(kind ErrorKind, err error) error {
+	if err == nil || errors.As(err, new(*DownloadError)) {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, ErrFFmpegNotFound):
+		kind = KindFFmpegMissing
+	case errors.As(err, new(*ErrInsufficientSpace)) || errors.Is(err, syscall.ENOSPC):
+		kind = KindDiskFull
+	}
+
+	return &DownloadError{Kind: kind, Err: err}
+}
+
+// ErrFFmpegFailed is returned when ffmpeg was started but exited with an error
+type ErrFFmpegFailed struct {
+	Err     error  // the error returned by the command, usually an *exec.ExitError
+	Stderr  string // what ffmpeg wrote to stderr
+	Command string // the command line as for a POSIX shell
+}
+
+func (err ErrFFmpegFailed) Error() string {
+	if err.Stderr == "" {
+		return fmt.Sprintf("ffmpeg failed: %v", err.Err)
+	}
+
+	return fmt.Sprintf("ffmpeg failed: %v: %s", err.Err, err.Stderr)
+}
+
+func (err ErrFFmpegFailed) Unwrap() error {
+	return err.Err
+}
+
+// ErrMergeFailed is returned when DownloadComposite fails to merge the downloaded streams.
+// They are kept next to the output file, so they can be merged manually.
+type ErrMergeFailed struct {
+	Err       error
+	Command   string // the failed ffmpeg command line, empty if ffmpeg didn't run
+	VideoFile string
+	AudioFile string
+}
+
+func (err ErrMergeFailed) Error() string {
+	msg := fmt.Sprintf("%v, the downloaded streams are kept as %s and %s", err.Err, err.VideoFile, err.AudioFile)
+	if err.Command != "" {
+		msg += ", the failed command was: " + err.Command
+	}
+
+	return msg
+}
+
+func (err ErrMergeFailed) Unwrap() error {
+	return err.Err
+}
+
+// ErrAudioLanguageUnavailable is returned when the video has no audio track in the requested language
+type ErrAudioLanguageUnavailable struct {
+	Requested string
+	Chosen    string   // language of the default track, empty if the video has a single unnamed track
+	Available []string // languages of all audio tracks, sorted
+}
+
+func (err ErrAudioLanguageUnavailable) Error() string {
+	msg := fmt.Sprintf("audio language %q is not available", err.Requested)
+	if err.Chosen != "" {
+		msg += fmt.Sprintf(", the default is %q", err.Chosen)
+	}
+	if len(err.Available) > 0 {
+		msg += ", available are " + strings.Join(err.Available, ", ")
+	}
+
+	return msg
+}
+
+// ErrNoFormatInSizeRange is returned when no format matches MinFilesize and MaxFilesize
+type ErrNoFormatInSizeRange struct {
+	Min       int64
+	Max       int64   // 0 means no upper limit
+	Available []int64 // estimated sizes of the formats
+}
+
+func (err ErrNoFormatInSizeRange) Error() string {
+	sizes := make([]string, len(err.Available))
+	for i, size := range err.Available {
+		sizes[i] = formatMiB(size)
+	}
+
+	limit := "of at least " + formatMiB(err.Min)
+	if err.Max > 0 {
+		limit = fmt.Sprintf("between %s and %s", formatMiB(err.Min), formatMiB(err.Max))
+	}
+
+	return fmt.Sprintf("no format with a size %s, available sizes: %s", limit, strings.Join(sizes, ", "))
+}
+
+func formatMiB(size int64) string {
+	return fmt.Sprintf("%.1f MiB", float64(size)/youtube.Size1Mb)
+}
+
+// ErrCodecUnavailable is returned when no format has the requested video or audio codec
+type ErrCodecUnavailable struct {
+	Kind      string // video or audio
+	Requested string
+	Available []string // codecs of the formats
+}
+
+func (err ErrCodecUnavailable) Error() string {
+	return fmt.Sprintf("no %s format with the codec %s, available codecs: %s", err.Kind, err.Requested, strings.Join(err.Available, ", "))
+}
+
+// ErrFPSUnavailable is returned when no format has the requested frame rate and StrictFPS is set
+type ErrFPSUnavailable struct {
+	Requested int
+	Available []int // frame rates of the formats
+}
+
+func (err ErrFPSUnavailable) Error() string {
+	rates := make([]string, len(err.Available))
+	for i, fps := range err.Available {
+		rates[i] = strconv.Itoa(fps)
+	}
+
+	return fmt.Sprintf("no format with a frame rate of at least %d fps, available frame rates: %s", err.Requested, strings.Join(rates, ", "))
+}
+
+// ErrInsufficientSpace is returned when the file system has not enough space for a download, see SkipSpaceCheck
+type ErrInsufficientSpace struct {
+	Dir       string
+	Needed    int64
+	Available int64
+}
+
+func (err ErrInsufficientSpace) Error() string {
+	return fmt.Sprintf("not enough space in %s: %s needed, %s available", err.Dir, formatMiB(err.Needed), formatMiB(err.Available))
+}
+
+// ErrFormatSelector is returned by ParseFormatSelector for a malformed expression
+type ErrFormatSelector struct {
+	Expression string
+	Offset     int    // of the offending token in bytes
+	Token      string // empty at the end of the expression
+	Reason     string
+}
+
+func (err ErrFormatSelector) Error() string {
+	token := "the end"
+	if err.Token != "" {
+		token = strconv.Quote(err.Token)
+	}
+
+	return fmt.Sprintf("invalid format selector %q: %s at offset %d: %s", err.Expression, token, err.Offset, err.Reason)
+}
+
+// ErrCaptionsUnavailable is returned when the video has no captions in the requested language
+type ErrCaptionsUnavailable struct {
+	Requested string
+	Available []string // language codes of the caption tracks
+}
+
+func (err ErrCaptionsUnavailable) Error() string {
+	if len(err.Available) == 0 {
+		return fmt.Sprintf("no captions found for language %s, the video has no captions", err.Requested)
+	}
+
+	return fmt.Sprintf("no captions found for language %s, available: %s", err.Requested, strings.Join(err.Available, ", "))
+}
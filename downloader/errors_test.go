@@ -0,0 +1,61 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func Test_downloadError(t *testing.T) {
+	assert.NoError(t, downloadError(KindStream, nil))
+
+	tests := []struct {
+		name string
+		err  error
+		want ErrorKind
+	}{
+		{name: "kind", err: errors.New("connection reset"), want: KindStream},
+		{name: "ffmpeg", err: fmt.Errorf("%w: exec: not found", ErrFFmpegNotFound), want: KindFFmpegMissing},
+		{name: "space", err: &ErrInsufficientSpace{Dir: "/tmp"}, want: KindDiskFull},
+		{name: "write", err: fmt.Errorf("write video.mp4: %w", syscall.ENOSPC), want: KindDiskFull},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := downloadError(KindStream, tt.err)
+
+			var downloadErr *DownloadError
+			require.ErrorAs(t, err, &downloadErr)
+			assert.Equal(t, tt.want, downloadErr.Kind)
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.err.Error(), err.Error(), "message is kept")
+		})
+	}
+
+	// the kind of wrapped errors is kept
+	err := downloadError(KindMerge, fmt.Errorf("merge failed: %w", downloadError(KindStream, errors.New("EOF"))))
+	var downloadErr *DownloadError
+	require.ErrorAs(t, err, &downloadErr)
+	assert.Equal(t, KindStream, downloadErr.Kind)
+}
+
+func TestDownloader_getVideoAudioFormats_errorKind(t *testing.T) {
+	video := &youtube.Video{Formats: youtube.FormatList{
+		{ItagNo: 140, MimeType: `audio/mp4; codecs="mp4a.40.2"`, AudioChannels: 2},
+	}}
+
+	dl := Downloader{}
+	_, _, err := dl.getVideoAudioFormats(video, "hd1080", "")
+	assert.EqualError(t, err, "no video format found after filtering")
+
+	var downloadErr *DownloadError
+	require.ErrorAs(t, err, &downloadErr)
+	assert.Equal(t, KindNoFormat, downloadErr.Kind)
+	assert.Equal(t, "no format", downloadErr.Kind.String())
+}
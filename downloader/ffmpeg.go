@@ -0,0 +1,171 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// number of trailing stderr lines of ffmpeg included in ErrFFmpegFailed
+const ffmpegStderrLines = 10
+
+// runFFmpeg runs ffmpeg with the given arguments, or prints the command with PrintFFmpegCommands.
+// The stderr output is captured for the returned error and optionally streamed to os.Stderr.
+func (dl *Downloader) runFFmpeg(ctx context.Context, args ...string) error {
+	return dl.execFFmpeg(ctx, nil, args)
+}
+
+// execFFmpeg is runFFmpeg with the command passed to configure, if not nil, before it is started
+func (dl *Downloader) execFFmpeg(ctx context.Context, configure func(cmd *exec.Cmd), args []string) error {
+	if dl.PrintFFmpegCommands {
+		fmt.Fprintln(os.Stderr, commandLine(dl.getFFmpegPath(), args))
+		return nil
+	}
+
+	var stderr bytes.Buffer
+
+	//nolint:gosec
+	cmd := exec.CommandContext(ctx, dl.getFFmpegPath(), args...)
+	cmd.Stderr = &stderr
+
+	if dl.ShowFFmpegOutput {
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	}
+
+	if configure != nil {
+		configure(cmd)
+	}
+
+	err := ffmpegError(cmd.Run(), lastLines(stderr.String(), ffmpegStderrLines))
+
+	var failed *ErrFFmpegFailed
+	if errors.As(err, &failed) {
+		failed.Command = commandLine(dl.getFFmpegPath(), args)
+	}
+
+	return downloadError(KindMerge, err)
+}
+
+func (dl *Downloader) getFFmpegPath() string {
+	if dl.FFmpegPath != "" {
+		return dl.FFmpegPath
+	}
+
+	return "ffmpeg"
+}
+
+// merge merges the video and audio file into destFile, see MergeRetry.
+// The chapters of a non-empty chaptersFile are written into it as well.
+// The audioArgs, like the ones of loudnormArgs, precede the FFmpegArgs.
+func (dl *Downloader) merge(ctx context.Context, videoFile, audioFile, chaptersFile, destFile string, audioArgs []string) error {
+	if err := checkFFmpegArgs(dl.FFmpegArgs); err != nil {
+		return err
+	}
+
+	extra := append(append([]string{}, audioArgs...), dl.FFmpegArgs...)
+
+	err := dl.runFFmpeg(ctx, mergeArgs(videoFile, audioFile, chaptersFile, destFile, false, extra)...)
+	if err == nil || !dl.MergeRetry || errors.Is(err, ErrFFmpegNotFound) || ctx.Err() != nil {
+		return err
+	}
+
+	youtube.Logger.Warn("merging by copying the streams failed, retrying with re-encoding", "error", err)
+
+	if retryErr := dl.runFFmpeg(ctx, mergeArgs(videoFile, audioFile, chaptersFile, destFile, true, extra)...); retryErr != nil {
+		return fmt.Errorf("merge failed: %w, retry with re-encoding failed: %w", err, retryErr)
+	}
+
+	return nil
+}
+
+// mergeArgs returns the ffmpeg arguments for merging the video and audio file.
+// Unless reencode is set the streams are copied as they are.
+// The chapters of a non-empty chaptersFile are added, it has no streams to select.
+// The extra arguments are inserted before the output file, so they override the preceding ones.
+func mergeArgs(videoFile, audioFile, chaptersFile, destFile string, reencode bool, extra []string) []string {
+	args := []string{"-y",
+		"-loglevel", "warning",
+		"-i", videoFile,
+		"-i", audioFile,
+	}
+
+	if chaptersFile != "" {
+		args = append(args, "-i", chaptersFile, "-map_chapters", "2")
+	}
+
+	if !reencode {
+		args = append(args, "-c", "copy") // Just copy without re-encoding
+	}
+
+	args = append(args, "-shortest") // Finish encoding when the shortest input stream ends
+	args = append(args, extra...)
+
+	return append(args, destFile)
+}
+
+// checkFFmpegArgs makes sure the FFmpegArgs don't add inputs to the merge
+func checkFFmpegArgs(args []string) error {
+	for _, arg := range args {
+		if arg == "-i" {
+			return fmt.Errorf("ffmpeg arguments must not add inputs with -i: %q", args)
+		}
+	}
+
+	return nil
+}
+
+// commandLine formats the command for a POSIX shell
+func commandLine(name string, args []string) string {
+	quoted := make([]string, 0, len(args)+1)
+	for _, arg := range append([]string{name}, args...) {
+		quoted = append(quoted, shellQuote(arg))
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// shellQuote quotes the argument if it contains characters interpreted by the shell
+func shellQuote(arg string) string {
+	if arg != "" && strings.IndexFunc(arg, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_.,:/=+@%", r))
+	}) < 0 {
+		return arg
+	}
+
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// lastLines returns the last n non-empty lines of s
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// ffmpegError maps the error of an ffmpeg invocation to ErrFFmpegNotFound or ErrFFmpegFailed
+func ffmpegError(err error, stderr string) error {
+	if err == nil {
+		return nil
+	}
+
+	var execErr *exec.Error
+	if errors.As(err, &execErr) {
+		return fmt.Errorf("%w: %v", ErrFFmpegNotFound, execErr)
+	}
+
+	return &ErrFFmpegFailed{
+		Err:    err,
+		Stderr: strings.TrimSpace(stderr),
+	}
+}
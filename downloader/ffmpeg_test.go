@@ -0,0 +1,162 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFFmpegError(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(ffmpegError(nil, ""))
+
+	err := ffmpegError(exec.Command("ffmpeg-does-not-exist").Run(), "")
+	assert.ErrorIs(err, ErrFFmpegNotFound)
+
+	exitErr := errors.New("exit status 1")
+	err = ffmpegError(exitErr, "Invalid data found when processing input\n")
+	assert.ErrorIs(err, exitErr)
+	assert.EqualError(err, "ffmpeg failed: exit status 1: Invalid data found when processing input")
+
+	var failed *ErrFFmpegFailed
+	if assert.ErrorAs(err, &failed) {
+		assert.Equal("Invalid data found when processing input", failed.Stderr)
+	}
+}
+
+func TestLastLines(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("", lastLines("", 3))
+	assert.Equal("a\nb", lastLines("a\nb\n", 3))
+	assert.Equal("c\nd", lastLines("a\nb\nc\nd\n", 2))
+}
+
+func TestMergeArgs(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]string{"-y", "-loglevel", "warning", "-i", "v.m4v", "-i", "a.m4a", "-c", "copy", "-shortest", "out.mp4"},
+		mergeArgs("v.m4v", "a.m4a", "", "out.mp4", false, nil))
+	assert.Equal([]string{"-y", "-loglevel", "warning", "-i", "v.m4v", "-i", "a.m4a", "-shortest", "out.mp4"},
+		mergeArgs("v.m4v", "a.m4a", "", "out.mp4", true, nil))
+	assert.Equal([]string{"-y", "-loglevel", "warning", "-i", "v.m4v", "-i", "a.m4a", "-c", "copy", "-shortest", "-movflags", "+faststart", "out.mp4"},
+		mergeArgs("v.m4v", "a.m4a", "", "out.mp4", false, []string{"-movflags", "+faststart"}))
+	assert.Equal([]string{"-y", "-loglevel", "warning", "-i", "v.m4v", "-i", "a.m4a", "-i", "chapters.txt", "-map_chapters", "2", "-c", "copy", "-shortest", "out.mp4"},
+		mergeArgs("v.m4v", "a.m4a", "chapters.txt", "out.mp4", false, nil))
+}
+
+func TestDownloader_merge_FFmpegArgs(t *testing.T) {
+	// ffmpeg must not be run
+	t.Setenv("PATH", t.TempDir())
+
+	dl := Downloader{FFmpegArgs: []string{"-i", "other.mp4"}}
+	err := dl.merge(context.Background(), "v.m4v", "a.m4a", "", "out.mp4", nil)
+	assert.EqualError(t, err, `ffmpeg arguments must not add inputs with -i: ["-i" "other.mp4"]`)
+}
+
+func TestDownloader_merge_retry(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+
+	// fake ffmpeg failing on every invocation, printing its arguments
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho \"cannot $*\" >&2\nexit 1\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ffmpeg"), []byte(script), 0o755))
+	t.Setenv("PATH", dir)
+
+	dl := Downloader{}
+	err := dl.merge(context.Background(), "v.m4v", "a.m4a", "", "out.mp4", nil)
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "retry")
+
+	dl.MergeRetry = true
+	err = dl.merge(context.Background(), "v.m4v", "a.m4a", "", "out.mp4", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot -y -loglevel warning -i v.m4v -i a.m4a -c copy -shortest")
+	assert.Contains(t, err.Error(), "retry with re-encoding failed")
+	assert.Contains(t, err.Error(), "cannot -y -loglevel warning -i v.m4v -i a.m4a -shortest")
+
+	var failed *ErrFFmpegFailed
+	assert.ErrorAs(t, err, &failed)
+}
+
+func TestCommandLine(t *testing.T) {
+	assert.Equal(t, "ffmpeg -y -i video.m4v -metadata 'title=It'\\''s a video' -metadata 'comment=a\nb' -metadata artist= out.mp4",
+		commandLine("ffmpeg", []string{"-y", "-i", "video.m4v", "-metadata", "title=It's a video", "-metadata", "comment=a\nb", "-metadata", "artist=", "out.mp4"}))
+	assert.Equal(t, "ffmpeg ''", commandLine("ffmpeg", []string{""}))
+}
+
+func TestRewriteArgs(t *testing.T) {
+	assert.Equal(t,
+		[]string{"-y", "-loglevel", "warning", "-i", "in.mp4", "-map", "0", "-c", "copy", "out.mp4"},
+		rewriteArgs("in.mp4", "out.mp4", []string{"-map", "0", "-c", "copy"}))
+}
+
+func TestDownloader_PrintFFmpegCommands(t *testing.T) {
+	// ffmpeg must not be run
+	t.Setenv("PATH", t.TempDir())
+
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	require.NoError(t, os.WriteFile(path, []byte("video"), 0o644))
+
+	dl := Downloader{PrintFFmpegCommands: true}
+	require.NoError(t, dl.merge(context.Background(), "v.m4v", "a.m4a", "", "out.mp4", nil))
+	require.NoError(t, dl.rewriteWithFFmpeg(context.Background(), path, "-c", "copy"))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "video", string(data))
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary file is removed")
+}
+
+func TestDownloader_FFmpegPath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+
+	// fake ffmpeg outside of PATH
+	t.Setenv("PATH", t.TempDir())
+	path := filepath.Join(t.TempDir(), "ffmpeg-static")
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\necho \"static $*\" >&2\nexit 1\n"), 0o755))
+
+	dl := Downloader{}
+	assert.ErrorIs(t, dl.runFFmpeg(context.Background(), "-version"), ErrFFmpegNotFound)
+
+	dl.FFmpegPath = path
+	err := dl.runFFmpeg(context.Background(), "-version")
+	var failed *ErrFFmpegFailed
+	require.ErrorAs(t, err, &failed)
+	assert.Equal(t, "static -version", failed.Stderr)
+}
+
+// fakeFFmpeg puts an ffmpeg shell script into PATH, which answers -version like a release and runs the script otherwise
+func fakeFFmpeg(t *testing.T, script string) {
+	dir := t.TempDir()
+	version := "#!/bin/sh\nif [ \"$1\" = -version ]; then echo 'ffmpeg version 6.1.1 Copyright (c) 2000-2023 the FFmpeg developers'; exit 0; fi\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ffmpeg"), []byte(version+script), 0o755))
+	t.Setenv("PATH", dir)
+}
+
+// fakeFFprobe puts an ffprobe shell script into PATH, in front of the fake ffmpeg
+func fakeFFprobe(t *testing.T, script string) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ffprobe"), []byte("#!/bin/sh\n"+script), 0o755))
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// probeStreams returns a fakeFFprobe script reporting the streams of a file of 10 seconds
+func probeStreams(streams string) string {
+	return "echo '{\"format\": {\"duration\": \"10.0\"}, \"streams\": " + streams + "}'\n"
+}
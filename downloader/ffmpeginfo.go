@@ -0,0 +1,110 @@
+package downloader
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// FFmpegInfo describes the ffmpeg executable of a Downloader, see Downloader.FFmpegInfo
+type FFmpegInfo struct {
+	Available bool
+	Path      string // the executable looked up in PATH, or FFmpegPath as it is
+	Version   string // e.g. "6.1.1" for releases and "N-113348-g0a5813fc68" for builds from git, empty if unknown
+	Err       error  // why ffmpeg is not available, ErrFFmpegNotFound or an *ErrFFmpegFailed
+}
+
+// MajorVersion returns the major version of ffmpeg releases, e.g. 6 for "6.1.1",
+// or 0 for builds from git and unknown versions
+func (info FFmpegInfo) MajorVersion() int {
+	major, _, _ := strings.Cut(strings.TrimPrefix(info.Version, "n"), ".")
+	n, _ := strconv.Atoi(major)
+
+	return n
+}
+
+// FFmpegInfo runs "ffmpeg -version" to check whether ffmpeg is available and returns its path and version.
+// The result is cached until FFmpegPath changes. DownloadComposite checks it before downloading the streams.
+func (dl *Downloader) FFmpegInfo() FFmpegInfo {
+	dl.ffmpegInfoMu.Lock()
+	defer dl.ffmpegInfoMu.Unlock()
+
+	if dl.ffmpegInfo == nil || dl.ffmpegInfoPath != dl.getFFmpegPath() {
+		info := probeFFmpeg(dl.getFFmpegPath())
+		dl.ffmpegInfo, dl.ffmpegInfoPath = &info, dl.getFFmpegPath()
+	}
+
+	return *dl.ffmpegInfo
+}
+
+// minFFmpegVersion is the oldest major version of ffmpeg not warned about
+const minFFmpegVersion = 4
+
+// probeFFmpeg runs the ffmpeg executable with -version
+func probeFFmpeg(name string) FFmpegInfo {
+	info := FFmpegInfo{Path: lookPath(name)}
+
+	var stderr bytes.Buffer
+
+	cmd := exec.Command(info.Path, "-version") //nolint:gosec
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		info.Err = ffmpegError(err, lastLines(stderr.String(), ffmpegStderrLines))
+		return info
+	}
+
+	info.Available = true
+	info.Version = parseFFmpegVersion(string(output))
+
+	youtube.Logger.Debug("found ffmpeg", "path", info.Path, "version", info.Version)
+
+	if major := info.MajorVersion(); major > 0 && major < minFFmpegVersion {
+		youtube.Logger.Warn("ffmpeg is old, filters like loudnorm might be missing", "version", info.Version, "path", info.Path)
+	}
+
+	return info
+}
+
+// lookPath returns the path of the executable in PATH, or the name if it isn't found there
+func lookPath(name string) string {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return name
+	}
+
+	return path
+}
+
+// parseFFmpegVersion returns the version of the first line of "ffmpeg -version",
+// like "ffmpeg version 6.1.1-3ubuntu5 Copyright (c) 2000-2023 the FFmpeg developers"
+func parseFFmpegVersion(output string) string {
+	line, _, _ := strings.Cut(output, "\n")
+
+	rest, ok := strings.CutPrefix(strings.TrimSpace(line), "ffmpeg version ")
+	if !ok {
+		return ""
+	}
+
+	version, _, _ := strings.Cut(rest, " ")
+
+	return version
+}
+
+// CheckFFmpeg returns a DownloadError of KindFFmpegMissing if ffmpeg isn't available, unless the commands are only printed
+func (dl *Downloader) CheckFFmpeg() error {
+	if dl.PrintFFmpegCommands {
+		return nil
+	}
+
+	if info := dl.FFmpegInfo(); !info.Available {
+		return &DownloadError{Kind: KindFFmpegMissing, Err: fmt.Errorf("checking %s: %w", info.Path, info.Err)}
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package downloader
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloader_FFmpegInfo(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+	fakeFFmpeg(t, "exit 1\n")
+
+	dl := Downloader{}
+	info := dl.FFmpegInfo()
+	assert.True(t, info.Available)
+	assert.NoError(t, info.Err)
+	assert.Equal(t, "6.1.1", info.Version)
+	assert.Equal(t, 6, info.MajorVersion())
+	assert.Equal(t, "ffmpeg", filepath.Base(info.Path))
+	assert.NoError(t, dl.CheckFFmpeg())
+
+	dl.FFmpegPath = "ffmpeg-does-not-exist"
+	info = dl.FFmpegInfo()
+	assert.False(t, info.Available)
+	assert.ErrorIs(t, info.Err, ErrFFmpegNotFound)
+
+	err := dl.CheckFFmpeg()
+	var dlErr *DownloadError
+	if assert.ErrorAs(t, err, &dlErr) {
+		assert.Equal(t, KindFFmpegMissing, dlErr.Kind)
+	}
+
+	dl.PrintFFmpegCommands = true
+	assert.NoError(t, dl.CheckFFmpeg(), "ffmpeg is not run")
+}
+
+func TestParseFFmpegVersion(t *testing.T) {
+	tests := map[string]string{
+		"ffmpeg version 6.1.1-3ubuntu5 Copyright (c) 2000-2023 the FFmpeg developers\nbuilt with gcc 13": "6.1.1-3ubuntu5",
+		"ffmpeg version n4.4.2 Copyright (c) 2000-2021 the FFmpeg developers":                            "n4.4.2",
+		"ffmpeg version N-113348-g0a5813fc68-20240120 Copyright (c) 2000-2024 the FFmpeg developers":     "N-113348-g0a5813fc68-20240120",
+		"avconv version 9.20": "",
+	}
+
+	for output, expected := range tests {
+		assert.Equal(t, expected, parseFFmpegVersion(output), output)
+	}
+
+	assert.Equal(t, 4, FFmpegInfo{Version: "n4.4.2"}.MajorVersion())
+	assert.Equal(t, 0, FFmpegInfo{Version: "N-113348-g0a5813fc68"}.MajorVersion())
+}
@@ -2,7 +2,10 @@ package downloader
 
 import (
 	"mime"
+	"path/filepath"
 	"regexp"
+	"strings"
+	"unicode/utf8"
 )
 
 const defaultExtension = ".mov"
@@ -27,14 +30,32 @@ var canonicals = map[string]string{
 	"video/mp4":        ".mp4",
 	"video/ogg":        ".ogv",
 	"video/mp2t":       ".ts",
+	"audio/mp4":        ".m4a",
+	"audio/webm":       ".webm",
+	"audio/mpeg":       ".mp3",
+	"audio/ogg":        ".ogg",
+	"image/jpeg":       ".jpg",
+	"image/webp":       ".webp",
+	"image/png":        ".png",
+}
+
+// Audio streams are named by codec rather than by container, as players expect e.g. opus audio in .opus files.
+var audioCodecs = map[string]string{
+	"opus": ".opus",
 }
 
 func pickIdealFileExtension(mediaType string) string {
-	mediaType, _, err := mime.ParseMediaType(mediaType)
+	mediaType, params, err := mime.ParseMediaType(mediaType)
 	if err != nil {
 		return defaultExtension
 	}
 
+	if strings.HasPrefix(mediaType, "audio/") {
+		if extension, ok := audioCodecs[params["codecs"]]; ok {
+			return extension
+		}
+	}
+
 	if extension, ok := canonicals[mediaType]; ok {
 		return extension
 	}
@@ -48,18 +69,71 @@ func pickIdealFileExtension(mediaType string) string {
 	return extensions[0]
 }
 
+// maxFilenameBytes is the length SanitizeFilename truncates names to. Filesystems allow 255 bytes,
+// the rest is left for suffixes like the resolution, the language of captions or " (1)".
+const maxFilenameBytes = 200
+
+// maxExtensionBytes is the longest suffix SanitizeFilename keeps as extension when truncating, e.g. ".webm"
+const maxExtensionBytes = 6
+
+var (
+	// control characters other than whitespace, which SanitizeFilename collapses into single spaces
+	invalidFilenameChars = regexp.MustCompile(`[:/<>\:"\\|?*\x00-\x08\x0e-\x1f]`)
+	whitespaces          = regexp.MustCompile(`\s+`)
+
+	// windowsReservedNames are device names, they can't be file names on windows even with an extension
+	windowsReservedNames = regexp.MustCompile(`(?i)^(CON|PRN|AUX|NUL|COM[0-9]|LPT[0-9])(\.|$)`)
+)
+
+// SanitizeFilename removes the characters not allowed in file names from fileName
+// and makes sure it is a valid file name on all systems:
+// it is at most maxFilenameBytes long, keeping the extension, doesn't end with dots or spaces
+// and isn't a reserved device name of windows.
 func SanitizeFilename(fileName string) string {
 	// Characters not allowed on mac
 	//	:/
 	// Characters not allowed on linux
 	//	/
 	// Characters not allowed on windows
-	//	<>:"/\|?*
+	//	<>:"/\|?* and control characters
 
 	// Ref https://docs.microsoft.com/en-us/windows/win32/fileio/naming-a-file#naming-conventions
 
-	fileName = regexp.MustCompile(`[:/<>\:"\\|?*]`).ReplaceAllString(fileName, "")
-	fileName = regexp.MustCompile(`\s+`).ReplaceAllString(fileName, " ")
+	fileName = invalidFilenameChars.ReplaceAllString(fileName, "")
+	fileName = whitespaces.ReplaceAllString(fileName, " ")
+
+	if len(fileName) > maxFilenameBytes {
+		ext := filepath.Ext(fileName)
+		if len(ext) > maxExtensionBytes || strings.Contains(ext, " ") {
+			// a dot in the title, not an extension
+			ext = ""
+		}
+		fileName = truncateUTF8(strings.TrimSuffix(fileName, ext), maxFilenameBytes-len(ext)) + ext
+	}
+
+	// windows drops trailing dots and spaces
+	fileName = strings.TrimRight(fileName, ". ")
+
+	if windowsReservedNames.MatchString(fileName) {
+		name, ext, _ := strings.Cut(fileName, ".")
+		fileName = name + "_"
+		if ext != "" {
+			fileName += "." + ext
+		}
+	}
 
 	return fileName
 }
+
+// truncateUTF8 shortens s to at most n bytes without splitting a multi-byte character
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n]
+}
@@ -1,7 +1,9 @@
 package downloader
 
 import (
+	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 func TestSanitizeFilename(t *testing.T) {
@@ -23,3 +25,69 @@ func TestSanitizeFilename(t *testing.T) {
 		t.Error("The common harmless symbols should remain valid")
 	}
 }
+
+func TestSanitizeFilename_reservedNames(t *testing.T) {
+	tests := map[string]string{
+		"CON":        "CON_",
+		"aux.txt":    "aux_.txt",
+		"lpt1":       "lpt1_",
+		"Console":    "Console",
+		"NUL ":       "NUL_",
+		"Title...":   "Title",
+		"Title . . ": "Title",
+		"a\tb\x00c":  "a bc",
+		"a | b":      "a b",
+		"A : B":      "A B",
+	}
+
+	for fileName, expected := range tests {
+		if sanitized := SanitizeFilename(fileName); sanitized != expected {
+			t.Errorf("expected %q for %q, got %q", expected, fileName, sanitized)
+		}
+	}
+}
+
+func TestSanitizeFilename_length(t *testing.T) {
+	// multi-byte characters must not be split
+	for _, title := range []string{strings.Repeat("a", 300), strings.Repeat("日本語", 100), strings.Repeat("🎵x", 100)} {
+		sanitized := SanitizeFilename(title)
+		if len(sanitized) > maxFilenameBytes || len(sanitized) < maxFilenameBytes-3 {
+			t.Errorf("expected about %d bytes, got %d", maxFilenameBytes, len(sanitized))
+		}
+		if !utf8.ValidString(sanitized) || !strings.HasPrefix(title, sanitized) {
+			t.Errorf("invalid truncation %q", sanitized)
+		}
+	}
+
+	sanitized := SanitizeFilename(strings.Repeat("日本語", 100) + ".webm")
+	if !strings.HasSuffix(sanitized, "本.webm") || !utf8.ValidString(sanitized) || len(sanitized) > maxFilenameBytes {
+		t.Errorf("the extension must be kept, got %q", sanitized)
+	}
+
+	// a dot of the title is not an extension
+	sanitized = SanitizeFilename(strings.Repeat("a", 300) + ". The End")
+	if sanitized != strings.Repeat("a", maxFilenameBytes) {
+		t.Errorf("expected the title to be truncated, got %q", sanitized)
+	}
+
+	if short := "Rob Pike - Simplicity is Complicated"; SanitizeFilename(short) != short {
+		t.Error("short names must be kept")
+	}
+}
+
+func TestPickIdealFileExtension(t *testing.T) {
+	tests := map[string]string{
+		`video/mp4; codecs="avc1.42001E, mp4a.40.2"`: ".mp4",
+		`video/webm; codecs="vp9"`:                   ".webm",
+		`audio/mp4; codecs="mp4a.40.2"`:              ".m4a",
+		`audio/webm; codecs="opus"`:                  ".opus",
+		`audio/webm; codecs="vorbis"`:                ".webm",
+		`invalid`:                                    defaultExtension,
+	}
+
+	for mimeType, expected := range tests {
+		if extension := pickIdealFileExtension(mimeType); extension != expected {
+			t.Errorf("expected %s for %s, got %s", expected, mimeType, extension)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package downloader
+
+import (
+	"github.com/kkdai/youtube/v2"
+)
+
+// FilterFilesize reduces the formats to the ones with an estimated size between MinFilesize and MaxFilesize.
+// It returns ErrNoFormatInSizeRange listing the available sizes if none matches.
+func (dl *Downloader) FilterFilesize(formats youtube.FormatList) (youtube.FormatList, error) {
+	if dl.MinFilesize == 0 && dl.MaxFilesize == 0 {
+		return formats, nil
+	}
+
+	if matching := formats.EstimatedSize(dl.MinFilesize, dl.MaxFilesize); len(matching) > 0 {
+		return matching, nil
+	}
+
+	err := &ErrNoFormatInSizeRange{
+		Min: dl.MinFilesize,
+		Max: dl.MaxFilesize,
+	}
+	for i := range formats {
+		if size := formats[i].EstimatedSize(); size > 0 {
+			err.Available = append(err.Available, size)
+		}
+	}
+
+	return nil, err
+}
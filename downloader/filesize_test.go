@@ -0,0 +1,45 @@
+package downloader
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func TestDownloader_FilterFilesize(t *testing.T) {
+	formats := youtube.FormatList{
+		{ItagNo: 137, ContentLength: 100 * youtube.Size1Mb},
+		{ItagNo: 136, ContentLength: 50 * youtube.Size1Mb},
+		{ItagNo: 135, AverageBitrate: 8 * youtube.Size1Mb, ApproxDurationMs: "2500"},
+		{ItagNo: 134},
+	}
+
+	dl := Downloader{}
+	got, err := dl.FilterFilesize(formats)
+	require.NoError(t, err)
+	assert.Len(t, got, 4)
+
+	dl.MaxFilesize = 60 * youtube.Size1Mb
+	got, err = dl.FilterFilesize(formats)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, 136, got[0].ItagNo)
+	assert.Equal(t, 135, got[1].ItagNo)
+
+	dl.MinFilesize = 10 * youtube.Size1Mb
+	dl.MaxFilesize = 20 * youtube.Size1Mb
+	_, err = dl.FilterFilesize(formats)
+	assert.EqualError(t, err, "no format with a size between 10.0 MiB and 20.0 MiB, available sizes: 100.0 MiB, 50.0 MiB, 2.5 MiB")
+
+	var sizeErr *ErrNoFormatInSizeRange
+	require.ErrorAs(t, err, &sizeErr)
+	assert.Len(t, sizeErr.Available, 3)
+
+	dl.MinFilesize = 200 * youtube.Size1Mb
+	dl.MaxFilesize = 0
+	_, err = dl.FilterFilesize(formats)
+	assert.EqualError(t, err, "no format with a size of at least 200.0 MiB, available sizes: 100.0 MiB, 50.0 MiB, 2.5 MiB")
+}
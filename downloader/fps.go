@@ -0,0 +1,48 @@
+package downloader
+
+import (
+	"slices"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// FilterFPS reduces the formats to the ones with a frame rate of at least MinFPS.
+// If none has it, the formats are kept and a warning logged, or ErrFPSUnavailable returned with StrictFPS.
+func (dl *Downloader) FilterFPS(formats youtube.FormatList) (youtube.FormatList, error) {
+	if dl.MinFPS == 0 {
+		return formats, nil
+	}
+
+	var (
+		matching  youtube.FormatList
+		available []int
+	)
+	for _, format := range formats {
+		if format.FPS >= dl.MinFPS {
+			matching = append(matching, format)
+		} else if format.FPS > 0 && !slices.Contains(available, format.FPS) {
+			available = append(available, format.FPS)
+		}
+	}
+
+	if len(matching) > 0 {
+		return matching, nil
+	}
+
+	slices.Sort(available)
+	err := &ErrFPSUnavailable{
+		Requested: dl.MinFPS,
+		Available: available,
+	}
+
+	if dl.StrictFPS {
+		return nil, err
+	}
+
+	youtube.Logger.Warn("requested frame rate is not available, using lower frame rates",
+		"requested", err.Requested,
+		"available", err.Available,
+	)
+
+	return formats, nil
+}
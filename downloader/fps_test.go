@@ -0,0 +1,59 @@
+package downloader
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func TestDownloader_FilterFPS(t *testing.T) {
+	formats := youtube.FormatList{
+		{ItagNo: 137, FPS: 30},
+		{ItagNo: 299, FPS: 60},
+		{ItagNo: 136, FPS: 30},
+		{ItagNo: 298, FPS: 60},
+		{ItagNo: 160, FPS: 24},
+	}
+
+	dl := Downloader{}
+	got, err := dl.FilterFPS(formats)
+	require.NoError(t, err)
+	assert.Len(t, got, 5)
+
+	dl.MinFPS = 50
+	got, err = dl.FilterFPS(formats)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, 299, got[0].ItagNo)
+	assert.Equal(t, 298, got[1].ItagNo)
+
+	// falls back to the lower frame rates
+	dl.MinFPS = 120
+	got, err = dl.FilterFPS(formats)
+	require.NoError(t, err)
+	assert.Len(t, got, 5)
+
+	dl.StrictFPS = true
+	_, err = dl.FilterFPS(formats)
+	assert.EqualError(t, err, "no format with a frame rate of at least 120 fps, available frame rates: 24, 30, 60")
+
+	var fpsErr *ErrFPSUnavailable
+	require.ErrorAs(t, err, &fpsErr)
+	assert.Equal(t, []int{24, 30, 60}, fpsErr.Available)
+}
+
+func TestDownloader_getVideoAudioFormats_fps(t *testing.T) {
+	video := &youtube.Video{Formats: youtube.FormatList{
+		{ItagNo: 137, MimeType: `video/mp4; codecs="avc1.640028"`, Quality: "hd1080", Width: 1920, FPS: 30},
+		{ItagNo: 299, MimeType: `video/mp4; codecs="avc1.64002a"`, Quality: "hd1080", Width: 1920, FPS: 60},
+		{ItagNo: 140, MimeType: `audio/mp4; codecs="mp4a.40.2"`, AudioChannels: 2},
+	}}
+
+	dl := Downloader{MinFPS: 60, PreferFPS: 30}
+	videoFormat, _, err := dl.getVideoAudioFormats(video, "hd1080", "")
+	require.NoError(t, err)
+	assert.Equal(t, 299, videoFormat.ItagNo)
+}
@@ -0,0 +1,56 @@
+package downloader
+
+import (
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// headerTransport is an http.RoundTripper adding headers to all requests, see Downloader.Headers
+type headerTransport struct {
+	next    http.RoundTripper
+	headers http.Header
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a RoundTripper must not modify the request
+	req = req.Clone(req.Context())
+
+	for key, values := range t.headers {
+		if key == "Cookie" {
+			// keep the cookies of the client, like the consent and CookiesFile
+			req.Header[key] = append(req.Header[key], values...)
+			continue
+		}
+		req.Header[key] = values
+	}
+
+	return t.next.RoundTrip(req)
+}
+
+// checkHeaders validates the names and values of headers, and normalizes the names
+func checkHeaders(headers http.Header) (http.Header, error) {
+	result := make(http.Header, len(headers))
+
+	for key, values := range headers {
+		if !httpguts.ValidHeaderFieldName(key) {
+			return nil, fmt.Errorf("invalid header name %q", key)
+		}
+
+		name := http.CanonicalHeaderKey(key)
+		if name == "Host" || name == "Content-Length" {
+			return nil, fmt.Errorf("header %s can't be set", name)
+		}
+
+		for _, value := range values {
+			if !httpguts.ValidHeaderFieldValue(value) {
+				return nil, fmt.Errorf("invalid value %q of header %s", value, name)
+			}
+		}
+
+		result[name] = append(result[name], values...)
+	}
+
+	return result, nil
+}
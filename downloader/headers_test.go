@@ -0,0 +1,65 @@
+package downloader
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func TestDownloader_Headers(t *testing.T) {
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.Write([]byte("video")) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	dl := Downloader{
+		ProgressOutput: io.Discard,
+		Headers: http.Header{
+			"user-agent":      {"test-agent"},
+			"Accept-Language": {"de"},
+			"Cookie":          {"PREF=hl=de"},
+		},
+	}
+	require.NoError(t, dl.SetupHTTPClient())
+
+	out, err := os.Create(filepath.Join(t.TempDir(), "video.mp4"))
+	require.NoError(t, err)
+	defer out.Close()
+
+	video := &youtube.Video{ID: "BaW_jenozKc"}
+	_, err = dl.videoDLWorker(context.Background(), out, video, &youtube.Format{URL: server.URL})
+	require.NoError(t, err)
+
+	assert.Equal(t, "test-agent", received.Get("User-Agent"))
+	assert.Equal(t, "de", received.Get("Accept-Language"))
+	// the headers of the client are kept
+	assert.Equal(t, "https://youtube.com", received.Get("Origin"))
+	assert.Contains(t, received.Get("Cookie"), "CONSENT=")
+	assert.Contains(t, received.Values("Cookie"), "PREF=hl=de")
+}
+
+func Test_checkHeaders(t *testing.T) {
+	headers, err := checkHeaders(http.Header{"accept-language": {"de"}})
+	require.NoError(t, err)
+	assert.Equal(t, http.Header{"Accept-Language": {"de"}}, headers)
+
+	_, err = checkHeaders(http.Header{"Bad Name": {"x"}})
+	assert.ErrorContains(t, err, `invalid header name "Bad Name"`)
+
+	_, err = checkHeaders(http.Header{"X-Test": {"line\nbreak"}})
+	assert.ErrorContains(t, err, "invalid value")
+
+	_, err = checkHeaders(http.Header{"Host": {"example.com"}})
+	assert.ErrorContains(t, err, "header Host can't be set")
+}
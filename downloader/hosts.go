@@ -0,0 +1,41 @@
+package downloader
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// matches CDN hosts like rr3---sn-4g5edn7s.googlevideo.com
+var cdnHostRegex = regexp.MustCompile(`^rr\d+---(sn-[a-z0-9-]+)\.googlevideo\.com$`)
+
+// alternateHosts returns the stream URL rewritten to the other CDN servers listed in its "mn" parameter.
+// The servers are addressed by their "fvip" variant, e.g. rr1---sn-4g5e6nsz.googlevideo.com.
+// Only the host is changed, as the query parameters are covered by the signatures.
+// This is best-effort, YouTube doesn't document the parameters.
+func alternateHosts(streamURL string) []string {
+	uri, err := url.Parse(streamURL)
+	if err != nil {
+		return nil
+	}
+
+	match := cdnHostRegex.FindStringSubmatch(uri.Host)
+	query := uri.Query()
+	fvip := query.Get("fvip")
+	if match == nil || fvip == "" || query.Get("mn") == "" {
+		return nil
+	}
+
+	var urls []string
+	for _, server := range strings.Split(query.Get("mn"), ",") {
+		if server == match[1] || !strings.HasPrefix(server, "sn-") {
+			continue
+		}
+
+		alternate := *uri
+		alternate.Host = "rr" + fvip + "---" + server + ".googlevideo.com"
+		urls = append(urls, alternate.String())
+	}
+
+	return urls
+}
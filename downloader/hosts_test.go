@@ -0,0 +1,20 @@
+package downloader
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_alternateHosts(t *testing.T) {
+	const query = "?expire=1700000000&itag=137&mn=sn-4g5edn7s%2Csn-4g5e6nsz&mm=31%2C29&fvip=5&sig=AOq0QJ8w&lsig=AGM4YrMw"
+
+	assert.Equal(t, []string{
+		"https://rr5---sn-4g5e6nsz.googlevideo.com/videoplayback" + query,
+	}, alternateHosts("https://rr3---sn-4g5edn7s.googlevideo.com/videoplayback"+query))
+
+	assert.Empty(t, alternateHosts("https://rr3---sn-4g5edn7s.googlevideo.com/videoplayback?mn=sn-4g5edn7s&fvip=5"))
+	assert.Empty(t, alternateHosts("https://rr3---sn-4g5edn7s.googlevideo.com/videoplayback?itag=137"))
+	assert.Empty(t, alternateHosts("https://example.com/videoplayback"+query))
+	assert.Empty(t, alternateHosts(""))
+}
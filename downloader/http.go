@@ -0,0 +1,38 @@
+package downloader
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// httpGetBodyBytes does a HTTP GET request with the HTTP client of the downloader and returns the body
+func (dl *Downloader) httpGetBodyBytes(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if dl.UserAgent != "" {
+		req.Header.Set("User-Agent", dl.UserAgent)
+	}
+
+	client := dl.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, youtube.ErrUnexpectedStatusCode(resp.StatusCode)
+	}
+
+	return io.ReadAll(resp.Body)
+}
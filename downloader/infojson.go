@@ -0,0 +1,93 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// InfoJSONVersion is the version of the schema of InfoJSON, raised on incompatible changes
+const InfoJSONVersion = 1
+
+// InfoJSON is the metadata of a video written by WriteInfoJSON
+type InfoJSON struct {
+	Version     int              `json:"_version"`
+	ID          string           `json:"id"`
+	Title       string           `json:"title"`
+	Author      string           `json:"author"`
+	ChannelID   string           `json:"channel_id,omitempty"`
+	Description string           `json:"description"`
+	Duration    int64            `json:"duration"`               // in seconds
+	PublishDate string           `json:"publish_date,omitempty"` // like 2006-01-02
+	ViewCount   int              `json:"view_count"`
+	Formats     []InfoJSONFormat `json:"formats"`
+}
+
+// InfoJSONFormat is an available format of the video of an InfoJSON
+type InfoJSONFormat struct {
+	Itag          int    `json:"itag"`
+	MimeType      string `json:"mime_type"`
+	Quality       string `json:"quality,omitempty"`
+	QualityLabel  string `json:"quality_label,omitempty"`
+	Bitrate       int    `json:"bitrate,omitempty"`
+	FPS           int    `json:"fps,omitempty"`
+	Width         int    `json:"width,omitempty"`
+	Height        int    `json:"height,omitempty"`
+	ContentLength int64  `json:"content_length,omitempty"`
+	AudioChannels int    `json:"audio_channels,omitempty"`
+}
+
+// NewInfoJSON returns the metadata of the video
+func NewInfoJSON(v *youtube.Video) *InfoJSON {
+	info := InfoJSON{
+		Version:     InfoJSONVersion,
+		ID:          v.ID,
+		Title:       v.Title,
+		Author:      v.Author,
+		ChannelID:   v.ChannelID,
+		Description: v.Description,
+		Duration:    int64(v.Duration.Seconds()),
+		ViewCount:   v.Views,
+		Formats:     make([]InfoJSONFormat, len(v.Formats)),
+	}
+
+	if !v.PublishDate.IsZero() {
+		info.PublishDate = v.PublishDate.Format("2006-01-02")
+	}
+
+	for i, format := range v.Formats {
+		info.Formats[i] = InfoJSONFormat{
+			Itag:          format.ItagNo,
+			MimeType:      format.MimeType,
+			Quality:       format.Quality,
+			QualityLabel:  format.QualityLabel,
+			Bitrate:       format.Bitrate,
+			FPS:           format.FPS,
+			Width:         format.Width,
+			Height:        format.Height,
+			ContentLength: format.ContentLength,
+			AudioChannels: format.AudioChannels,
+		}
+	}
+
+	return &info
+}
+
+// WriteInfoJSON writes the metadata of the video as InfoJSON next to the file,
+// replacing its extension with ".info.json", e.g. "Title.info.json" for "Title.mp4"
+type WriteInfoJSON struct{}
+
+// PostProcess implements the PostProcessor interface
+func (WriteInfoJSON) PostProcess(_ context.Context, _ *Downloader, v *youtube.Video, path string) (string, error) {
+	data, err := json.MarshalIndent(NewInfoJSON(v), "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	infoFile := sidecarFile(path, ".info.json")
+	youtube.Logger.Debug("writing info JSON", "path", infoFile)
+
+	return path, os.WriteFile(infoFile, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,52 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func TestWriteInfoJSON(t *testing.T) {
+	video := &youtube.Video{
+		ID:          "BaW_jenozKc",
+		Title:       "Title",
+		Author:      "Author",
+		Duration:    90500 * time.Millisecond,
+		PublishDate: time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC),
+		Views:       42,
+		Formats: youtube.FormatList{
+			{ItagNo: 137, MimeType: `video/mp4; codecs="avc1.640028"`, QualityLabel: "1080p", Width: 1920, Height: 1080, FPS: 30},
+			{ItagNo: 140, MimeType: `audio/mp4; codecs="mp4a.40.2"`, AudioChannels: 2, ContentLength: 1000},
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "Title.v1.mp4")
+	result, err := WriteInfoJSON{}.PostProcess(context.Background(), &Downloader{}, video, path)
+	require.NoError(t, err)
+	assert.Equal(t, path, result)
+
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(path), "Title.v1.info.json"))
+	require.NoError(t, err)
+
+	var fields map[string]any
+	require.NoError(t, json.Unmarshal(data, &fields))
+	assert.EqualValues(t, InfoJSONVersion, fields["_version"])
+	assert.Equal(t, "BaW_jenozKc", fields["id"])
+	assert.EqualValues(t, 90, fields["duration"])
+	assert.Equal(t, "2021-03-04", fields["publish_date"])
+	assert.EqualValues(t, 42, fields["view_count"])
+
+	var info InfoJSON
+	require.NoError(t, json.Unmarshal(data, &info))
+	require.Len(t, info.Formats, 2)
+	assert.Equal(t, InfoJSONFormat{Itag: 137, MimeType: `video/mp4; codecs="avc1.640028"`, QualityLabel: "1080p", Width: 1920, Height: 1080, FPS: 30}, info.Formats[0])
+	assert.Equal(t, 2, info.Formats[1].AudioChannels)
+}
@@ -0,0 +1,150 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// liveStopTimeout is how long ffmpeg may take to finish the file once interrupted, it is killed then
+const liveStopTimeout = 10 * time.Second
+
+// DownloadLive records the ongoing live stream of the video from its current edge with ffmpeg,
+// until ctx is done, e.g. on Ctrl+C, or the stream ends.
+// ffmpeg is interrupted to flush the file, a fragmented mp4 that stays playable even if it is killed.
+// The size is unknown, so the progress bar is a spinner.
+// The video must be live, see youtube.Video.IsLive, ErrNotLive is returned otherwise.
+func (dl *Downloader) DownloadLive(ctx context.Context, outputFile string, v *youtube.Video) (*DownloadResult, error) {
+	if !v.IsLive || v.HLSManifestURL == "" {
+		return nil, ErrNotLive
+	}
+
+	if outputFile == Stdout {
+		return nil, fmt.Errorf("%w, recording a live stream needs an output file", ErrStdoutUnsupported)
+	}
+
+	if err := dl.CheckFFmpeg(); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	// only for naming the file
+	format := &youtube.Format{MimeType: "video/mp4"}
+
+	var err error
+	if outputFile == "" {
+		outputFile, err = dl.getDefaultFile(ctx, v, format, ".mp4")
+		if err != nil {
+			return nil, err
+		}
+	}
+	destFile, err := dl.joinOutputDir(outputFile)
+	if err != nil {
+		return nil, err
+	}
+	destFile = dl.uniqueName(destFile)
+	defer dl.releaseName(destFile)
+
+	youtube.Logger.Info("Recording live stream", "id", v.ID, "output", destFile)
+
+	reporter := dl.progressReporter(v, format)
+	reporter.Start(0)
+
+	err = dl.execFFmpeg(ctx, func(cmd *exec.Cmd) {
+		cmd.Stdout = &ffmpegProgress{reporter: reporter}
+		// a killed ffmpeg leaves the last fragment out
+		cmd.Cancel = func() error {
+			if err := cmd.Process.Signal(os.Interrupt); err != nil {
+				return cmd.Process.Kill()
+			}
+			return nil
+		}
+		cmd.WaitDelay = liveStopTimeout
+	}, liveArgs(v.HLSManifestURL, destFile))
+	reporter.Finish()
+
+	var written int64
+	if info, statErr := os.Stat(destFile); statErr == nil {
+		written = info.Size()
+	}
+
+	// ffmpeg exits with an error when interrupted
+	stopped := ctx.Err() != nil
+	if err != nil && !(stopped && written > 0) {
+		return nil, err
+	}
+	if stopped {
+		youtube.Logger.Info("stopped recording the live stream", "path", destFile)
+	}
+
+	// the post-processors run even though ctx is done once the recording stopped
+	ctx = context.WithoutCancel(ctx)
+
+	if err = dl.verifyMedia(ctx, destFile, 0, 0); err != nil {
+		return nil, err
+	}
+
+	destFile, err = dl.runPostProcessors(ctx, v, destFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return dl.complete(&DownloadResult{
+		Path:    destFile,
+		Bytes:   written,
+		Elapsed: time.Since(start),
+	})
+}
+
+// liveArgs returns the ffmpeg arguments copying the streams of the HLS manifest into output,
+// with the progress written to stdout
+func liveArgs(manifest, output string) []string {
+	return []string{
+		"-y", "-nostdin",
+		"-loglevel", "warning",
+		"-i", manifest,
+		"-c", "copy",
+		"-movflags", "+frag_keyframe+empty_moov+default_base_moof",
+		"-progress", "pipe:1",
+		output,
+	}
+}
+
+// ffmpegProgress reports the total_size lines of "ffmpeg -progress" to a ProgressReporter
+type ffmpegProgress struct {
+	reporter ProgressReporter
+	buf      []byte
+	size     int64
+}
+
+func (p *ffmpegProgress) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := strings.TrimSpace(string(p.buf[:i]))
+		p.buf = p.buf[i+1:]
+
+		// the size is "N/A" before the first packet is written
+		value, ok := strings.CutPrefix(line, "total_size=")
+		if !ok {
+			continue
+		}
+		if size, err := strconv.ParseInt(value, 10, 64); err == nil && size > p.size {
+			p.reporter.Add(size - p.size)
+			p.size = size
+		}
+	}
+
+	return len(b), nil
+}
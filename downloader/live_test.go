@@ -0,0 +1,78 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func Test_ffmpegProgress(t *testing.T) {
+	reporter := &recordingReporter{}
+	progress := &ffmpegProgress{reporter: reporter}
+
+	_, err := progress.Write([]byte("frame=0\ntotal_size=N/A\nprogress=continue\ntotal_si"))
+	require.NoError(t, err)
+	assert.Zero(t, reporter.added.Load())
+
+	_, err = progress.Write([]byte("ze=1024\nprogress=continue\ntotal_size=4096\n"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(4096), reporter.added.Load())
+}
+
+func TestDownloader_DownloadLive_notLive(t *testing.T) {
+	dl := Downloader{}
+
+	_, err := dl.DownloadLive(context.Background(), "", &youtube.Video{ID: "BaW_jenozKc"})
+	assert.ErrorIs(t, err, ErrNotLive)
+}
+
+func TestDownloader_DownloadLive_interrupted(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ffmpeg is a shell script")
+	}
+
+	// records until interrupted, then exits with an error like ffmpeg
+	fakeFFmpeg(t, "eval out=\\${$#}\ntrap 'echo recorded > \"$out\"; exit 255' INT\necho total_size=9\nwhile :; do :; done\n")
+
+	reporter := &recordingReporter{}
+	dl := Downloader{OutputDir: t.TempDir(), Progress: reporter}
+	video := &youtube.Video{ID: "BaW_jenozKc", Title: "live", IsLive: true, HLSManifestURL: "https://example.com/index.m3u8"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	result, err := dl.DownloadLive(ctx, "", video)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dl.OutputDir, "live.mp4"), result.Path)
+	assert.Equal(t, int64(9), result.Bytes)
+	assert.Equal(t, int64(9), reporter.added.Load())
+	assert.Equal(t, int32(1), reporter.finished.Load())
+
+	data, err := os.ReadFile(result.Path)
+	require.NoError(t, err)
+	assert.Equal(t, "recorded\n", string(data))
+}
+
+func TestDownloader_DownloadLive_failed(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ffmpeg is a shell script")
+	}
+
+	fakeFFmpeg(t, "echo 'Server returned 403 Forbidden' >&2\nexit 1\n")
+
+	dl := Downloader{OutputDir: t.TempDir(), Silent: true}
+	video := &youtube.Video{ID: "BaW_jenozKc", Title: "live", IsLive: true, HLSManifestURL: "https://example.com/index.m3u8"}
+
+	_, err := dl.DownloadLive(context.Background(), "", video)
+	var failed *ErrFFmpegFailed
+	require.ErrorAs(t, err, &failed)
+	assert.Contains(t, failed.Stderr, "403 Forbidden")
+}
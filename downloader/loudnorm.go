@@ -0,0 +1,76 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// DefaultTargetLUFS is the integrated loudness NormalizeAudio aims for without TargetLUFS,
+// the one of most streaming services
+const DefaultTargetLUFS = -14.0
+
+// the range of integrated loudness the loudnorm filter of ffmpeg accepts
+const (
+	minTargetLUFS = -70.0
+	maxTargetLUFS = -5.0
+)
+
+// loudnormSampleRate is the sample rate of normalized audio, the filter upsamples to 192 kHz otherwise
+const loudnormSampleRate = "48000"
+
+// loudnormFilter returns the ffmpeg arguments applying the loudnorm filter to the audio, or nil without NormalizeAudio
+func (dl *Downloader) loudnormFilter() ([]string, error) {
+	if !dl.NormalizeAudio {
+		return nil, nil
+	}
+
+	target := dl.TargetLUFS
+	if target == 0 {
+		target = DefaultTargetLUFS
+	}
+	if target < minTargetLUFS || target > maxTargetLUFS {
+		return nil, fmt.Errorf("target loudness %v LUFS is out of the range %v to %v", target, minTargetLUFS, maxTargetLUFS)
+	}
+
+	return []string{
+		"-af", "loudnorm=I=" + strconv.FormatFloat(target, 'f', -1, 64) + ":TP=-1.5:LRA=11",
+		"-ar", loudnormSampleRate,
+	}, nil
+}
+
+// loudnormArgs returns the loudnormFilter with the arguments re-encoding the audio of the format, or nil without NormalizeAudio.
+// The encoder matches the codec of the format, so the container can still hold it.
+func (dl *Downloader) loudnormArgs(format *youtube.Format) ([]string, error) {
+	filter, err := dl.loudnormFilter()
+	if err != nil || filter == nil {
+		return nil, err
+	}
+
+	encode := audioTargets[".opus"].encode
+	if _, audio := formatCodecs(format); audio == "aac" {
+		encode = audioTargets[".m4a"].encode
+	}
+
+	return append(slices.Clone(encode), filter...), nil
+}
+
+// normalizeAudio applies the loudnormArgs of the format to the file downloaded from it with an extra ffmpeg pass,
+// the video is copied. Files without audio are left unchanged.
+func (dl *Downloader) normalizeAudio(ctx context.Context, path string, format *youtube.Format, args []string) error {
+	if args == nil {
+		return nil
+	}
+
+	if format.AudioChannels == 0 {
+		youtube.Logger.Info("the format has no audio, not normalizing it", "itag", format.ItagNo)
+		return nil
+	}
+
+	youtube.Logger.Debug("normalizing audio", "path", path)
+
+	return dl.rewriteWithFFmpeg(ctx, path, append([]string{"-map", "0", "-c", "copy"}, args...)...)
+}
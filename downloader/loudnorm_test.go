@@ -0,0 +1,57 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func TestDownloader_loudnormArgs(t *testing.T) {
+	aac := &youtube.Format{MimeType: `audio/mp4; codecs="mp4a.40.2"`}
+	muxed := &youtube.Format{MimeType: `video/mp4; codecs="avc1.42001E, mp4a.40.2"`}
+	opus := &youtube.Format{MimeType: `audio/webm; codecs="opus"`}
+
+	dl := Downloader{}
+	args, err := dl.loudnormArgs(aac)
+	require.NoError(t, err)
+	assert.Nil(t, args, "disabled")
+
+	dl.NormalizeAudio = true
+	args, err = dl.loudnormArgs(aac)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-c:a", "aac", "-b:a", "192k", "-af", "loudnorm=I=-14:TP=-1.5:LRA=11", "-ar", "48000"}, args)
+
+	args, err = dl.loudnormArgs(muxed)
+	require.NoError(t, err)
+	assert.Equal(t, "aac", args[1])
+
+	dl.TargetLUFS = -23.5
+	args, err = dl.loudnormArgs(opus)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-c:a", "libopus", "-b:a", "160k", "-af", "loudnorm=I=-23.5:TP=-1.5:LRA=11", "-ar", "48000"}, args)
+
+	dl.TargetLUFS = -3
+	_, err = dl.loudnormArgs(opus)
+	assert.EqualError(t, err, "target loudness -3 LUFS is out of the range -70 to -5")
+}
+
+func TestDownloader_normalizeAudio_noAudio(t *testing.T) {
+	// ffmpeg must not be run
+	t.Setenv("PATH", t.TempDir())
+
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	require.NoError(t, os.WriteFile(path, []byte("video"), 0o644))
+
+	dl := Downloader{NormalizeAudio: true}
+	format := &youtube.Format{ItagNo: 137, MimeType: `video/mp4; codecs="avc1.640028"`}
+	args, err := dl.loudnormArgs(format)
+	require.NoError(t, err)
+
+	require.NoError(t, dl.normalizeAudio(context.Background(), path, format, args))
+}
@@ -0,0 +1,71 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// errMmapUnsupported is returned by mapFile on platforms it isn't implemented for
+var errMmapUnsupported = errors.New("memory mapping files is not supported on this platform")
+
+// partWriter writes the parts of a concurrent download at their offsets
+type partWriter interface {
+	io.WriterAt
+	io.Closer
+}
+
+// openPartWriter returns the partWriter of a concurrent download of size bytes into out.
+// With UseMmap out is truncated to the size and mapped into memory, it is written at offsets if that fails.
+func (dl *Downloader) openPartWriter(out *os.File, size int64) (partWriter, error) {
+	if !dl.UseMmap {
+		return fileWriter{out}, nil
+	}
+
+	// the mapping can't grow the file
+	if err := out.Truncate(size); err != nil {
+		return nil, err
+	}
+
+	mapped, err := mapFile(out, size)
+	if err != nil {
+		youtube.Logger.Debug("can't map the output into memory, writing at offsets", "path", out.Name(), "error", err)
+		return fileWriter{out}, nil
+	}
+
+	return mapped, nil
+}
+
+// fileWriter is a partWriter of the file, which it doesn't close
+type fileWriter struct {
+	file *os.File
+}
+
+func (w fileWriter) WriteAt(p []byte, off int64) (int, error) {
+	return w.file.WriteAt(p, off)
+}
+
+func (w fileWriter) Close() error {
+	return nil
+}
+
+// mappedFile is a partWriter of a file mapped into memory, Close unmaps it
+type mappedFile struct {
+	data []byte
+}
+
+func (m *mappedFile) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 || off > int64(len(m.data)) {
+		return 0, fmt.Errorf("offset %d is outside of the %d mapped bytes", off, len(m.data))
+	}
+
+	n := copy(m.data[off:], p)
+	if n < len(p) {
+		return n, io.ErrShortWrite
+	}
+
+	return n, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd
+
+package downloader
+
+import "os"
+
+func mapFile(*os.File, int64) (*mappedFile, error) {
+	return nil, errMmapUnsupported
+}
+
+func (m *mappedFile) Close() error {
+	return nil
+}
@@ -0,0 +1,30 @@
+//go:build linux || darwin || freebsd
+
+package downloader
+
+import (
+	"fmt"
+	"math"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// mapFile maps the first size bytes of the file into memory for writing, the file has to be that large
+func mapFile(file *os.File, size int64) (*mappedFile, error) {
+	if size <= 0 || size > math.MaxInt {
+		return nil, fmt.Errorf("can't map %d bytes", size)
+	}
+
+	data, err := unix.Mmap(int(file.Fd()), 0, int(size), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mappedFile{data: data}, nil
+}
+
+// Close unmaps the file, the written bytes are in the page cache of the file already
+func (m *mappedFile) Close() error {
+	return unix.Munmap(m.data)
+}
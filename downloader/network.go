@@ -0,0 +1,154 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// SetupHTTPClient applies the network options of the Downloader, like DNSServer, CookiesFile, Headers and TrafficLog, to its HTTPClient.
+// The transport of HTTPClient is cloned, so it has to be an *http.Transport or nil.
+// Call it once after configuring the Downloader and before downloading.
+func (dl *Downloader) SetupHTTPClient() error {
+	client := http.Client{}
+	if dl.HTTPClient != nil {
+		client = *dl.HTTPClient
+	}
+
+	var transport *http.Transport
+	switch t := client.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		return fmt.Errorf("unsupported HTTP transport %T", client.Transport)
+	}
+
+	if dl.DNSServer != "" {
+		server, err := dnsServerAddress(dl.DNSServer)
+		if err != nil {
+			return err
+		}
+
+		dial := transport.DialContext
+		if dial == nil {
+			dial = (&net.Dialer{}).DialContext
+		}
+		transport.DialContext = resolvingDialer(dnsResolver(server), dial)
+	}
+
+	if dl.ProxyURL != "" {
+		proxy, err := parseProxyURL(dl.ProxyURL)
+		if err != nil {
+			return err
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if dl.CookiesFile != "" {
+		jar, err := loadCookies(dl.CookiesFile)
+		if err != nil {
+			return err
+		}
+		client.Jar = jar
+	}
+
+	client.Transport = transport
+	if dl.TrafficLog != nil {
+		client.Transport = &trafficLogger{next: transport, out: dl.TrafficLog}
+	}
+	if len(dl.Headers) > 0 {
+		// added before the traffic log, so it shows them
+		headers, err := checkHeaders(dl.Headers)
+		if err != nil {
+			return err
+		}
+		client.Transport = &headerTransport{next: client.Transport, headers: headers}
+	}
+	dl.HTTPClient = &client
+
+	return nil
+}
+
+// proxySchemes are the proxy protocols supported by http.Transport
+var proxySchemes = []string{"http", "https", "socks5", "socks5h"}
+
+// parseProxyURL parses the URL of a proxy and checks its scheme is supported
+func parseProxyURL(rawURL string) (*url.URL, error) {
+	proxy, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+
+	if !slices.Contains(proxySchemes, proxy.Scheme) {
+		return nil, fmt.Errorf("invalid proxy URL %q: unsupported scheme %q, use one of %s", rawURL, proxy.Scheme, strings.Join(proxySchemes, ", "))
+	}
+
+	if proxy.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: no host", rawURL)
+	}
+
+	return proxy, nil
+}
+
+// dnsServerAddress validates the address of a DNS server and adds the default port
+func dnsServerAddress(server string) (string, error) {
+	host, port, err := net.SplitHostPort(server)
+	if err != nil {
+		// no port given
+		host, port = server, "53"
+	}
+
+	if net.ParseIP(host) == nil {
+		return "", fmt.Errorf("invalid DNS server %q: not an IP address", server)
+	}
+
+	return net.JoinHostPort(host, port), nil
+}
+
+// dnsResolver returns a resolver querying the DNS server at the address
+func dnsResolver(server string) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// resolvingDialer looks up host names with the resolver before dialing.
+// If the lookup fails, dial resolves the name with the system DNS instead.
+func resolvingDialer(resolver *net.Resolver, dial dialFunc) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+
+		ips, err := resolver.LookupHost(ctx, host)
+		if err != nil {
+			youtube.Logger.Warn("DNS lookup failed, falling back to the system resolver", "host", host, "error", err)
+			return dial(ctx, network, addr)
+		}
+
+		for _, ip := range ips {
+			var conn net.Conn
+			conn, err = dial(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+		}
+
+		return nil, err
+	}
+}
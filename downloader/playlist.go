@@ -0,0 +1,38 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// PlaylistEntry is the position of a video in a playlist, see WithPlaylistEntry
+type PlaylistEntry struct {
+	Title string // of the playlist
+	Index int    // of the video, starting at 1
+	Count int    // number of videos of the playlist, the index is zero-padded to its width
+}
+
+type playlistEntryKey struct{}
+
+// WithPlaylistEntry returns a context for downloading the video of the entry.
+// The generated file names of the downloads can reference the entry with the {playlist} and {playlist_index}
+// tokens of OutputTemplate, and are nested in a directory of the playlist with PlaylistDir.
+func WithPlaylistEntry(ctx context.Context, entry PlaylistEntry) context.Context {
+	return context.WithValue(ctx, playlistEntryKey{}, entry)
+}
+
+// playlistEntryFrom returns the PlaylistEntry of the context, or nil
+func playlistEntryFrom(ctx context.Context) *PlaylistEntry {
+	entry, ok := ctx.Value(playlistEntryKey{}).(PlaylistEntry)
+	if !ok {
+		return nil
+	}
+
+	return &entry
+}
+
+// paddedIndex returns the index zero-padded to the width of the count, e.g. "007" of 120 videos
+func (entry *PlaylistEntry) paddedIndex() string {
+	return fmt.Sprintf("%0*d", len(strconv.Itoa(entry.Count)), entry.Index)
+}
@@ -0,0 +1,266 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// PostProcessor processes a downloaded file.
+// It returns the path of the processed file, which is the path of the next PostProcessor's input.
+type PostProcessor interface {
+	PostProcess(ctx context.Context, dl *Downloader, v *youtube.Video, path string) (string, error)
+}
+
+// PostProcessorFunc allows the use of ordinary functions as PostProcessor
+type PostProcessorFunc func(ctx context.Context, dl *Downloader, v *youtube.Video, path string) (string, error)
+
+// PostProcess calls f(ctx, dl, v, path)
+func (f PostProcessorFunc) PostProcess(ctx context.Context, dl *Downloader, v *youtube.Video, path string) (string, error) {
+	return f(ctx, dl, v, path)
+}
+
+// runPostProcessors runs the PostProcessors in order and returns the path of the final file
+func (dl *Downloader) runPostProcessors(ctx context.Context, v *youtube.Video, path string) (string, error) {
+	for _, processor := range dl.PostProcessors {
+		var err error
+
+		path, err = processor.PostProcess(ctx, dl, v, path)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return path, nil
+}
+
+// SetMtime sets the modification time of the file to the publish date of the video, e.g. for archives sorted by date.
+// Videos without publish date keep the time of the download. Run it last, the others rewrite the file.
+type SetMtime struct{}
+
+// PostProcess implements the PostProcessor interface
+func (SetMtime) PostProcess(_ context.Context, dl *Downloader, v *youtube.Video, path string) (string, error) {
+	if v.PublishDate.IsZero() {
+		youtube.Logger.Warn("the video has no publish date, keeping the modification time", "id", v.ID)
+		return path, nil
+	}
+
+	// the printed commands don't write the file
+	if dl.PrintFFmpegCommands {
+		return path, nil
+	}
+
+	youtube.Logger.Debug("setting modification time", "path", path, "date", v.PublishDate)
+
+	return path, os.Chtimes(path, v.PublishDate, v.PublishDate)
+}
+
+// WriteMetadata writes the title, author and publish date of the video into the metadata of the file
+type WriteMetadata struct {
+	// IncludeDescription also writes the video description, as comment into mp4 files and as DESCRIPTION into mkv/webm
+	IncludeDescription bool
+
+	// IncludeSourceURL also writes the URL of the video, as URL into mkv/webm and appended to the comment of mp4 files
+	IncludeSourceURL bool
+}
+
+// PostProcess implements the PostProcessor interface
+func (w WriteMetadata) PostProcess(ctx context.Context, dl *Downloader, v *youtube.Video, path string) (string, error) {
+	youtube.Logger.Debug("writing metadata", "path", path)
+
+	return path, dl.rewriteWithFFmpeg(ctx, path, w.args(v, filepath.Ext(path))...)
+}
+
+// args returns the ffmpeg arguments writing the metadata into a file with the extension
+func (w WriteMetadata) args(v *youtube.Video, ext string) []string {
+	args := []string{
+		"-map", "0",
+		"-c", "copy",
+		"-metadata", "title=" + v.Title,
+		"-metadata", "artist=" + v.Author,
+	}
+
+	if !v.PublishDate.IsZero() {
+		args = append(args, "-metadata", "date="+v.PublishDate.Format("2006-01-02"))
+	}
+
+	fields := containerFields[".mp4"]
+	if f, ok := containerFields[strings.ToLower(ext)]; ok {
+		fields = f
+	}
+
+	var description string
+	if w.IncludeDescription {
+		description = truncateRunes(cleanMetadataValue(v.Description), fields.maxDescription)
+	}
+
+	if w.IncludeSourceURL {
+		sourceURL := "https://www.youtube.com/watch?v=" + v.ID
+
+		switch {
+		case fields.url != "":
+			args = append(args, "-metadata", fields.url+"="+sourceURL)
+		case description != "":
+			// the container has no field for it
+			description += "\n\n" + sourceURL
+		default:
+			description = sourceURL
+		}
+	}
+
+	if description != "" {
+		args = append(args, "-metadata", fields.description+"="+description)
+	}
+
+	return args
+}
+
+// containerFields are the metadata fields for the description and source URL per container.
+// ffmpeg stores "comment" as the ©cmt atom of mp4 files, players tend to cut it off after a few thousand characters.
+// It doesn't write a URL atom into them.
+var containerFields = map[string]struct {
+	description    string
+	maxDescription int // in runes
+	url            string
+}{
+	".mp4":  {"comment", 4096, ""},
+	".m4a":  {"comment", 4096, ""},
+	".m4v":  {"comment", 4096, ""},
+	".mov":  {"comment", 4096, ""},
+	".mkv":  {"DESCRIPTION", 32768, "URL"},
+	".webm": {"DESCRIPTION", 32768, "URL"},
+}
+
+// cleanMetadataValue normalizes line breaks and removes control characters, which ffmpeg or the players choke on
+func cleanMetadataValue(value string) string {
+	value = strings.ReplaceAll(value, "\r\n", "\n")
+
+	return strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' || !unicode.IsControl(r) {
+			return r
+		}
+		return -1
+	}, value))
+}
+
+// truncateRunes shortens s to at most n runes
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+
+	return string(runes[:n])
+}
+
+// EmbedThumbnail embeds the largest thumbnail of the video as cover art into the file.
+// mp4, m4a, mov and mp3 files get it as attached picture and mkv files as attachment.
+// Other containers, like webm, can't hold one and are left unchanged.
+type EmbedThumbnail struct{}
+
+// PostProcess implements the PostProcessor interface
+func (EmbedThumbnail) PostProcess(ctx context.Context, dl *Downloader, v *youtube.Video, path string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if _, ok := coverArtStreams[ext]; !ok && ext != ".mkv" {
+		youtube.Logger.Warn("the container can't hold a thumbnail, not embedding it", "path", path)
+		return path, nil
+	}
+
+	dir, err := dl.getTempDir(filepath.Dir(path))
+	if err != nil {
+		return "", err
+	}
+
+	thumbnail, err := os.CreateTemp(dir, "youtube_*."+ThumbnailJPG)
+	if err != nil {
+		return "", err
+	}
+	thumbnail.Close()
+	defer dl.removeIntermediate(thumbnail.Name())
+
+	err = dl.writeThumbnail(ctx, v, ThumbnailJPG, thumbnail.Name())
+	if errors.Is(err, ErrNoThumbnail) {
+		youtube.Logger.Warn("the video has no thumbnail to embed", "id", v.ID)
+		return path, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	youtube.Logger.Debug("embedding thumbnail", "path", path)
+
+	return path, dl.rewriteWithFFmpeg(ctx, path, embedThumbnailArgs(ext, thumbnail.Name())...)
+}
+
+// coverArtStreams is the number of video streams of the files that take the thumbnail as attached picture,
+// which is the index of the picture among the video streams of the output.
+// Video files only contain a single video stream, the downloaded or merged one.
+var coverArtStreams = map[string]int{
+	".mp4": 1,
+	".m4v": 1,
+	".mov": 1,
+	".m4a": 0,
+	".mp3": 0,
+}
+
+// embedThumbnailArgs returns the ffmpeg arguments adding the thumbnail to a file with the extension
+func embedThumbnailArgs(ext string, thumbnail string) []string {
+	if ext == ".mkv" {
+		return []string{
+			"-map", "0",
+			"-c", "copy",
+			"-attach", thumbnail,
+			"-metadata:s:t", "mimetype=image/jpeg",
+			"-metadata:s:t", "filename=cover.jpg",
+		}
+	}
+
+	return []string{
+		"-i", thumbnail,
+		"-map", "0",
+		"-map", "1",
+		"-c", "copy",
+		"-disposition:v:" + strconv.Itoa(coverArtStreams[ext]), "attached_pic",
+	}
+}
+
+// rewriteWithFFmpeg runs ffmpeg with the file as first input and replaces the file with the output.
+// The args are inserted between the first input and the output file.
+// With PrintFFmpegCommands the file is left unchanged.
+func (dl *Downloader) rewriteWithFFmpeg(ctx context.Context, path string, args ...string) error {
+	return dl.replaceWithFFmpeg(ctx, path, func(output string) []string {
+		return rewriteArgs(path, output, args)
+	})
+}
+
+// replaceWithFFmpeg runs ffmpeg with the arguments for a temporary output file next to the file
+// and replaces the file with it. With PrintFFmpegCommands the file is left unchanged.
+func (dl *Downloader) replaceWithFFmpeg(ctx context.Context, path string, args func(output string) []string) error {
+	// the extension tells ffmpeg which container to write
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), "youtube_*"+filepath.Ext(path))
+	if err != nil {
+		return err
+	}
+	tmpFile.Close()
+
+	if err = dl.runFFmpeg(ctx, args(tmpFile.Name())...); err != nil || dl.PrintFFmpegCommands {
+		os.Remove(tmpFile.Name())
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), path)
+}
+
+// rewriteArgs returns the ffmpeg arguments for writing the input with the args applied to the output
+func rewriteArgs(input, output string, args []string) []string {
+	result := []string{"-y", "-loglevel", "warning", "-i", input}
+	result = append(result, args...)
+
+	return append(result, output)
+}
@@ -0,0 +1,164 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func TestRunPostProcessors(t *testing.T) {
+	require := require.New(t)
+
+	appendSuffix := func(suffix string) PostProcessor {
+		return PostProcessorFunc(func(_ context.Context, _ *Downloader, _ *youtube.Video, path string) (string, error) {
+			return path + suffix, nil
+		})
+	}
+
+	dl := Downloader{
+		PostProcessors: []PostProcessor{appendSuffix(".a"), appendSuffix(".b")},
+	}
+
+	path, err := dl.runPostProcessors(context.Background(), &youtube.Video{}, "video.mp4")
+	require.NoError(err)
+	require.Equal("video.mp4.a.b", path)
+
+	failure := errors.New("failed")
+	dl.PostProcessors = append(dl.PostProcessors, PostProcessorFunc(func(context.Context, *Downloader, *youtube.Video, string) (string, error) {
+		return "", failure
+	}))
+
+	_, err = dl.runPostProcessors(context.Background(), &youtube.Video{}, "video.mp4")
+	require.ErrorIs(err, failure)
+}
+
+func TestWriteMetadata_args(t *testing.T) {
+	require := require.New(t)
+
+	v := &youtube.Video{
+		Title:       "Title",
+		Author:      "Author",
+		PublishDate: time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC),
+		Description: "First line\r\nSecond line\x00\n",
+	}
+
+	require.Equal([]string{
+		"-map", "0", "-c", "copy",
+		"-metadata", "title=Title",
+		"-metadata", "artist=Author",
+		"-metadata", "date=2021-03-04",
+	}, WriteMetadata{}.args(v, ".mp4"))
+
+	args := WriteMetadata{IncludeDescription: true}.args(v, ".mp4")
+	require.Equal([]string{"-metadata", "comment=First line\nSecond line"}, args[len(args)-2:])
+
+	args = WriteMetadata{IncludeDescription: true}.args(v, ".MKV")
+	require.Equal([]string{"-metadata", "DESCRIPTION=First line\nSecond line"}, args[len(args)-2:])
+
+	v.Description = strings.Repeat("ä", 5000)
+	args = WriteMetadata{IncludeDescription: true}.args(v, ".m4a")
+	require.Equal("comment="+strings.Repeat("ä", 4096), args[len(args)-1])
+}
+
+func TestWriteMetadata_args_sourceURL(t *testing.T) {
+	require := require.New(t)
+
+	v := &youtube.Video{ID: "BaW_jenozKc", Title: "Title", Author: "Author", Description: "Description"}
+
+	args := WriteMetadata{IncludeSourceURL: true}.args(v, ".webm")
+	require.Equal([]string{"-metadata", "URL=https://www.youtube.com/watch?v=BaW_jenozKc"}, args[len(args)-2:])
+
+	args = WriteMetadata{IncludeSourceURL: true, IncludeDescription: true}.args(v, ".mkv")
+	require.Equal([]string{
+		"-metadata", "URL=https://www.youtube.com/watch?v=BaW_jenozKc",
+		"-metadata", "DESCRIPTION=Description",
+	}, args[len(args)-4:])
+
+	// mp4 has no URL field
+	args = WriteMetadata{IncludeSourceURL: true}.args(v, ".mp4")
+	require.Equal([]string{"-metadata", "comment=https://www.youtube.com/watch?v=BaW_jenozKc"}, args[len(args)-2:])
+
+	args = WriteMetadata{IncludeSourceURL: true, IncludeDescription: true}.args(v, ".m4a")
+	require.Equal([]string{"-metadata", "comment=Description\n\nhttps://www.youtube.com/watch?v=BaW_jenozKc"}, args[len(args)-2:])
+}
+
+func TestEmbedThumbnailArgs(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal([]string{
+		"-i", "cover.jpg", "-map", "0", "-map", "1", "-c", "copy", "-disposition:v:1", "attached_pic",
+	}, embedThumbnailArgs(".mp4", "cover.jpg"))
+
+	require.Equal([]string{
+		"-i", "cover.jpg", "-map", "0", "-map", "1", "-c", "copy", "-disposition:v:0", "attached_pic",
+	}, embedThumbnailArgs(".m4a", "cover.jpg"))
+
+	require.Equal([]string{
+		"-map", "0", "-c", "copy", "-attach", "cover.jpg",
+		"-metadata:s:t", "mimetype=image/jpeg", "-metadata:s:t", "filename=cover.jpg",
+	}, embedThumbnailArgs(".mkv", "cover.jpg"))
+}
+
+func TestEmbedThumbnail_skipped(t *testing.T) {
+	require := require.New(t)
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	setThumbnailBaseURL(t, server.URL)
+
+	// ffmpeg must not be run
+	t.Setenv("PATH", t.TempDir())
+
+	dir := t.TempDir()
+	dl := &Downloader{}
+
+	for _, name := range []string{"audio.webm", "video.mp4"} {
+		path := filepath.Join(dir, name)
+		require.NoError(os.WriteFile(path, []byte("data"), 0o644))
+
+		result, err := EmbedThumbnail{}.PostProcess(context.Background(), dl, &youtube.Video{ID: "missing"}, path)
+		require.NoError(err)
+		require.Equal(path, result)
+	}
+
+	// the temporary thumbnail is removed
+	entries, err := os.ReadDir(dir)
+	require.NoError(err)
+	require.Len(entries, 2)
+}
+
+func TestSetMtime(t *testing.T) {
+	require := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	require.NoError(os.WriteFile(path, []byte("mp4"), 0o644))
+
+	published := time.Date(2009, 10, 24, 0, 0, 0, 0, time.UTC)
+	result, err := SetMtime{}.PostProcess(context.Background(), &Downloader{}, &youtube.Video{PublishDate: published}, path)
+	require.NoError(err)
+	require.Equal(path, result)
+
+	info, err := os.Stat(path)
+	require.NoError(err)
+	require.True(info.ModTime().Equal(published))
+
+	// without publish date the file is left unchanged
+	now := time.Now().Truncate(time.Second)
+	require.NoError(os.Chtimes(path, now, now))
+	_, err = SetMtime{}.PostProcess(context.Background(), &Downloader{}, &youtube.Video{ID: "BaW_jenozKc"}, path)
+	require.NoError(err)
+
+	info, err = os.Stat(path)
+	require.NoError(err)
+	require.True(info.ModTime().Equal(now))
+}
@@ -0,0 +1,196 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// ProbeResult contains the information reported by ffprobe about a media file
+type ProbeResult struct {
+	Duration time.Duration
+	Streams  []ProbeStream
+}
+
+// ProbeStream describes a single stream of a media file
+type ProbeStream struct {
+	Index     int    `json:"index"`
+	CodecType string `json:"codec_type"` // video, audio, subtitle, ...
+	CodecName string `json:"codec_name"`
+}
+
+// Probe runs ffprobe on the given file
+func (dl *Downloader) Probe(ctx context.Context, path string) (*ProbeResult, error) {
+	var stdout, stderr bytes.Buffer
+
+	//nolint:gosec
+	cmd := exec.CommandContext(ctx, dl.getFFprobePath(),
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		path,
+	)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, ffmpegError(err, lastLines(stderr.String(), ffmpegStderrLines))
+	}
+
+	return parseProbeOutput(stdout.Bytes())
+}
+
+// getFFprobePath returns FFprobePath, or the ffprobe next to FFmpegPath
+func (dl *Downloader) getFFprobePath() string {
+	if dl.FFprobePath != "" {
+		return dl.FFprobePath
+	}
+
+	name := filepath.Base(dl.FFmpegPath)
+	if dl.FFmpegPath == "" || name == dl.FFmpegPath {
+		return "ffprobe"
+	}
+
+	ext := filepath.Ext(name)
+	if !strings.EqualFold(ext, ".exe") {
+		ext = ""
+	}
+
+	return strings.TrimSuffix(dl.FFmpegPath, name) + "ffprobe" + ext
+}
+
+func parseProbeOutput(data []byte) (*ProbeResult, error) {
+	var output struct {
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+		Streams []ProbeStream `json:"streams"`
+	}
+
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, fmt.Errorf("unable to parse ffprobe output: %w", err)
+	}
+
+	result := &ProbeResult{
+		Streams: output.Streams,
+	}
+
+	if seconds, err := strconv.ParseFloat(output.Format.Duration, 64); err == nil {
+		result.Duration = time.Duration(seconds * float64(time.Second))
+	}
+
+	return result, nil
+}
+
+// verifyWithProbe checks with ffprobe that the file has a duration and all streams of the format
+func (dl *Downloader) verifyWithProbe(ctx context.Context, path string, format *youtube.Format) error {
+	result, err := dl.Probe(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	if result.Duration <= 0 {
+		return fmt.Errorf("%w: %s has no duration", ErrIncompleteDownload, path)
+	}
+
+	if expected := expectedStreams(format); len(result.Streams) < expected {
+		return fmt.Errorf("%w: %s has %d streams, expected %d", ErrIncompleteDownload, path, len(result.Streams), expected)
+	}
+
+	return nil
+}
+
+// expectedStreams returns the number of streams a file of the given format contains
+func expectedStreams(format *youtube.Format) int {
+	var streams int
+
+	if strings.HasPrefix(format.MimeType, "video/") {
+		streams++
+	}
+
+	if format.AudioChannels > 0 || strings.HasPrefix(format.MimeType, "audio/") {
+		streams++
+	}
+
+	return streams
+}
+
+// mediaDurationTolerance is how much the duration of a file may differ from the expected one with VerifyMedia,
+// the length of videos is reported in whole seconds and their streams rarely end at the same time
+const mediaDurationTolerance = 2 * time.Second
+
+// expectedDuration returns the duration of a download of the whole video for VerifyMedia, or 0 if it is unknown
+func (dl *Downloader) expectedDuration(v *youtube.Video) time.Duration {
+	if dl.TestMode {
+		// the samples are cut by an estimated number of bytes
+		return 0
+	}
+
+	return v.Duration
+}
+
+// verifyMedia checks the finished file with ffprobe if VerifyMedia is set, see checkMedia.
+// A file failing it is removed, unless KeepStreams is set.
+func (dl *Downloader) verifyMedia(ctx context.Context, path string, minDuration, maxDuration time.Duration) error {
+	// the printed commands don't write the file to probe
+	if !dl.VerifyMedia || dl.PrintFFmpegCommands {
+		return nil
+	}
+
+	youtube.Logger.Debug("verifying media", "path", path)
+
+	result, err := dl.Probe(ctx, path)
+	var failed *ErrFFmpegFailed
+	if errors.As(err, &failed) {
+		// ffprobe fails on corrupt and truncated files
+		err = fmt.Errorf("%w: %s: %w", ErrInvalidMedia, path, err)
+	} else if err == nil {
+		err = checkMedia(path, result, minDuration, maxDuration)
+	}
+	if err == nil || errors.Is(err, ErrFFmpegNotFound) {
+		return err
+	}
+
+	if dl.KeepStreams {
+		youtube.Logger.Info("kept the file failing verification", "path", path)
+	} else {
+		os.Remove(path)
+	}
+
+	return err
+}
+
+// checkMedia returns ErrInvalidMedia unless the probed file has a video or audio stream and a duration
+// from minDuration to maxDuration within mediaDurationTolerance, a bound of 0 isn't checked
+func checkMedia(path string, result *ProbeResult, minDuration, maxDuration time.Duration) error {
+	if !slices.ContainsFunc(result.Streams, func(stream ProbeStream) bool {
+		return (stream.CodecType == "video" || stream.CodecType == "audio") && stream.CodecName != ""
+	}) {
+		return fmt.Errorf("%w: %s has no video or audio stream", ErrInvalidMedia, path)
+	}
+
+	if result.Duration <= 0 {
+		return fmt.Errorf("%w: %s has no duration", ErrInvalidMedia, path)
+	}
+
+	if minDuration > 0 && result.Duration < minDuration-mediaDurationTolerance {
+		return fmt.Errorf("%w: %s is %s long, expected %s", ErrInvalidMedia, path, result.Duration, minDuration)
+	}
+	if maxDuration > 0 && result.Duration > maxDuration+mediaDurationTolerance {
+		return fmt.Errorf("%w: %s is %s long, expected %s", ErrInvalidMedia, path, result.Duration, maxDuration)
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func TestParseProbeOutput(t *testing.T) {
+	require := require.New(t)
+
+	result, err := parseProbeOutput([]byte(`{
+		"streams": [
+			{"index": 0, "codec_name": "h264", "codec_type": "video"},
+			{"index": 1, "codec_name": "aac", "codec_type": "audio"}
+		],
+		"format": {"filename": "video.mp4", "duration": "10.500000"}
+	}`))
+	require.NoError(err)
+	require.Equal(10500*time.Millisecond, result.Duration)
+	require.Len(result.Streams, 2)
+	require.Equal("audio", result.Streams[1].CodecType)
+
+	_, err = parseProbeOutput([]byte("not json"))
+	require.Error(err)
+}
+
+func TestDownloader_getFFprobePath(t *testing.T) {
+	tests := []struct {
+		ffmpeg, ffprobe string
+		want            string
+	}{
+		{"", "", "ffprobe"},
+		{"ffmpeg6", "", "ffprobe"},
+		{"/opt/ffmpeg/bin/ffmpeg", "", "/opt/ffmpeg/bin/ffprobe"},
+		{"./ffmpeg-6.1", "", "./ffprobe"},
+		{"/opt/ffmpeg/ffmpeg.EXE", "", "/opt/ffmpeg/ffprobe.EXE"},
+		{"/opt/ffmpeg/bin/ffmpeg", "/usr/bin/ffprobe", "/usr/bin/ffprobe"},
+	}
+
+	for _, tt := range tests {
+		dl := Downloader{FFmpegPath: tt.ffmpeg, FFprobePath: tt.ffprobe}
+		assert.Equal(t, tt.want, dl.getFFprobePath(), tt.ffmpeg)
+	}
+}
+
+func TestExpectedStreams(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(2, expectedStreams(&youtube.Format{MimeType: `video/mp4; codecs="avc1.42001E, mp4a.40.2"`, AudioChannels: 2}))
+	assert.Equal(1, expectedStreams(&youtube.Format{MimeType: `video/webm; codecs="vp9"`}))
+	assert.Equal(1, expectedStreams(&youtube.Format{MimeType: `audio/webm; codecs="opus"`, AudioChannels: 2}))
+}
+
+func TestCheckMedia(t *testing.T) {
+	streams := []ProbeStream{{Index: 0, CodecType: "video", CodecName: "h264"}}
+
+	tests := []struct {
+		name     string
+		result   *ProbeResult
+		min, max time.Duration
+		want     string
+	}{
+		{"valid", &ProbeResult{Duration: 59500 * time.Millisecond, Streams: streams}, time.Minute, time.Minute, ""},
+		{"unbounded", &ProbeResult{Duration: time.Hour, Streams: streams}, 0, 0, ""},
+		{"no streams", &ProbeResult{Duration: time.Minute}, time.Minute, time.Minute, "invalid media file: video.mp4 has no video or audio stream"},
+		{"only subtitles", &ProbeResult{Duration: time.Minute, Streams: []ProbeStream{{CodecType: "subtitle", CodecName: "mov_text"}}}, 0, 0, "invalid media file: video.mp4 has no video or audio stream"},
+		{"no duration", &ProbeResult{Streams: streams}, 0, 0, "invalid media file: video.mp4 has no duration"},
+		{"truncated", &ProbeResult{Duration: 30 * time.Second, Streams: streams}, time.Minute, time.Minute, "invalid media file: video.mp4 is 30s long, expected 1m0s"},
+		{"too long", &ProbeResult{Duration: 2 * time.Minute, Streams: streams}, time.Minute, time.Minute, "invalid media file: video.mp4 is 2m0s long, expected 1m0s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkMedia("video.mp4", tt.result, tt.min, tt.max)
+			if tt.want == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.want)
+			assert.ErrorIs(t, err, ErrInvalidMedia)
+		})
+	}
+}
+
+func TestDownloader_verifyMedia(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ffprobe is a shell script")
+	}
+
+	fakeFFmpeg(t, "")
+	// the duration is 10 seconds
+	fakeFFprobe(t, probeStreams(`[{"index": 0, "codec_type": "audio", "codec_name": "opus"}]`))
+
+	path := filepath.Join(t.TempDir(), "audio.opus")
+	require.NoError(t, os.WriteFile(path, []byte("opus"), 0o644))
+
+	dl := &Downloader{}
+	require.NoError(t, dl.verifyMedia(context.Background(), path, time.Hour, time.Hour), "disabled")
+
+	dl.VerifyMedia = true
+	require.NoError(t, dl.verifyMedia(context.Background(), path, 10*time.Second, 10*time.Second))
+
+	dl.KeepStreams = true
+	err := dl.verifyMedia(context.Background(), path, time.Minute, time.Minute)
+	require.ErrorIs(t, err, ErrInvalidMedia)
+	assert.FileExists(t, path, "kept with KeepStreams")
+
+	dl.KeepStreams = false
+	err = dl.verifyMedia(context.Background(), path, time.Minute, time.Minute)
+	require.ErrorIs(t, err, ErrInvalidMedia)
+	assert.NoFileExists(t, path)
+}
+
+func TestDownloader_verifyMedia_corrupt(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ffprobe is a shell script")
+	}
+
+	fakeFFmpeg(t, "")
+	fakeFFprobe(t, "echo 'moov atom not found' >&2\nexit 1\n")
+
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	require.NoError(t, os.WriteFile(path, []byte("mp4"), 0o644))
+
+	dl := &Downloader{VerifyMedia: true}
+	err := dl.verifyMedia(context.Background(), path, 0, 0)
+	require.ErrorIs(t, err, ErrInvalidMedia)
+	assert.ErrorContains(t, err, "moov atom not found")
+	assert.NoFileExists(t, path)
+}
@@ -1,5 +1,332 @@
 package downloader
 
+import (
+	"encoding/json"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/VividCortex/ewma"
+	"github.com/vbauerster/mpb/v5"
+	"github.com/vbauerster/mpb/v5/decor"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// ProgressReporter receives the progress of the downloaded streams.
+// Start is called before copying a stream with its size, Add with the number of bytes of every write
+// and Finish once the stream is complete or failed.
+// Add is called concurrently for streams downloaded in parts, see Downloader.Concurrency.
+type ProgressReporter interface {
+	Start(total int64)
+	Add(n int64)
+	Finish()
+}
+
+// progressReporter returns Progress, or a new progress bar or JSON progress of the stream
+// written to ProgressOutput unless Silent is set
+func (dl *Downloader) progressReporter(video *youtube.Video, format *youtube.Format) ProgressReporter {
+	switch {
+	case dl.Progress != nil:
+		return dl.Progress
+	case dl.ProgressJSON:
+		return &jsonReporter{encoder: json.NewEncoder(dl.getProgressOutput()), id: video.ID, itag: format.ItagNo}
+	case dl.Silent:
+		return silentReporter{}
+	}
+
+	dl.barsMu.Lock()
+	defer dl.barsMu.Unlock()
+
+	return &barReporter{dl: dl, batch: dl.batch}
+}
+
+// silentReporter ignores the progress
+type silentReporter struct{}
+
+func (silentReporter) Start(int64) {}
+func (silentReporter) Add(int64)   {}
+func (silentReporter) Finish()     {}
+
+// barReporter is the default ProgressReporter, rendering a progress bar for a single stream.
+// The bars of concurrent streams share one mpb.Progress of the Downloader, so they are rendered below each other.
+type barReporter struct {
+	dl    *Downloader
+	batch *BatchProgress // the overall bar of the batch of the stream, if any
+
+	mu      sync.Mutex
+	bar     *mpb.Bar
+	total   int64
+	current int64
+	last    time.Time
+
+	// the averages of the ETA and speed decorators, in time per byte, see watchStall
+	eta, speed ewma.MovingAverage
+	stalled    bool
+	done       chan struct{}
+}
+
+// A stream receiving no bytes for stallTimeout is stalled: every stallCheckInterval its bar counts
+// the time since as spent on a single byte, so the speed drops to zero and the ETA grows instead of freezing
+const (
+	stallTimeout       = 3 * time.Second
+	stallCheckInterval = time.Second
+)
+
+func (r *barReporter) Start(total int64) {
+	// thread safe, so watchStall can update them while the bar renders
+	r.eta = decor.NewThreadSafeMovingAverage(ewma.NewMovingAverage(90))
+	r.speed = decor.NewThreadSafeMovingAverage(ewma.NewMovingAverage(60))
+
+	filler := mpb.NewBarFiller(mpb.DefaultBarStyle, false)
+	options := []mpb.BarOption{
+		mpb.PrependDecorators(
+			decor.CountersKibiByte("% .2f / % .2f"),
+			decor.Percentage(decor.WCSyncSpace),
+		),
+		mpb.AppendDecorators(
+			decor.MovingAverageETA(decor.ET_STYLE_GO, r.eta, nil),
+			decor.Name(" ] "),
+			decor.MovingAverageSpeed(decor.UnitKiB, "% .2f", r.speed),
+		),
+	}
+	if total <= 0 {
+		// without a size there is no percentage or ETA, a spinner shows the download goes on
+		filler = mpb.NewSpinnerFiller(mpb.DefaultSpinnerStyle, mpb.SpinnerOnLeft)
+		options = []mpb.BarOption{
+			mpb.PrependDecorators(
+				decor.CurrentKibiByte("% .2f", decor.WCSyncSpace),
+			),
+			mpb.AppendDecorators(
+				decor.Elapsed(decor.ET_STYLE_GO),
+				decor.Name(" ] "),
+				decor.MovingAverageSpeed(decor.UnitKiB, "% .2f", r.speed),
+			),
+		}
+	}
+	if r.batch != nil {
+		// only the bars of the running streams stay below the overall bar
+		options = append(options, mpb.BarRemoveOnComplete())
+		r.batch.startStream(total)
+	}
+
+	r.bar = r.dl.addBar(total, filler, options...)
+	if total <= 0 {
+		// mpb completes bars without a total on the first write
+		r.bar.SetTotal(0, false)
+	}
+	r.total = total
+	r.last = time.Now()
+	r.done = make(chan struct{})
+
+	go r.watchStall(r.done)
+}
+
+// watchStall updates the averages of a stalled stream until done is closed, see stallTimeout
+func (r *barReporter) watchStall(done <-chan struct{}) {
+	ticker := time.NewTicker(stallCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			r.checkStall(now)
+		}
+	}
+}
+
+// checkStall counts the time since the last update as spent on a single byte if the stream is stalled at now
+func (r *barReporter) checkStall(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.stalled && now.Sub(r.last) < stallTimeout {
+		return
+	}
+
+	r.stalled = true
+	r.eta.Add(float64(now.Sub(r.last)))
+	r.speed.Add(float64(now.Sub(r.last)))
+	r.last = now
+}
+
+// Add advances the bar, the increment and the speed update must not interleave with other calls,
+// see mpb.Bar.DecoratorEwmaUpdate
+func (r *barReporter) Add(n int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.bar.IncrInt64(n)
+	if r.stalled && n > 0 {
+		// the averages restart with the first bytes after a stall, so the speed recovers right away
+		perByte := float64(time.Since(r.last)) / float64(n)
+		r.eta.Set(perByte)
+		r.speed.Set(perByte)
+		r.stalled = false
+	} else {
+		r.bar.DecoratorEwmaUpdate(time.Since(r.last))
+	}
+	r.current += n
+	r.last = time.Now()
+
+	if r.batch != nil && r.total > 0 {
+		r.batch.add(n)
+	}
+}
+
+func (r *barReporter) Finish() {
+	close(r.done)
+
+	if r.total > 0 && r.current < r.total {
+		// failed, leave the bar at where it stopped, or remove it in a batch
+		r.bar.Abort(r.batch != nil)
+		if r.batch != nil {
+			r.batch.failStream(r.total - r.current)
+		}
+	} else {
+		// streams of unknown size are complete with what was written
+		r.bar.SetTotal(0, true)
+	}
+
+	r.dl.releaseBar()
+}
+
+// addBar adds a bar to the progress bars of the Downloader, starting to render them if there are none yet
+func (dl *Downloader) addBar(total int64, filler mpb.BarFiller, options ...mpb.BarOption) *mpb.Bar {
+	dl.barsMu.Lock()
+	defer dl.barsMu.Unlock()
+
+	if dl.bars == nil {
+		dl.bars = mpb.New(
+			mpb.WithWidth(64),
+			mpb.WithOutput(dl.getProgressOutput()),
+		)
+	}
+	dl.activeBars++
+
+	return dl.bars.Add(total, filler, options...)
+}
+
+// releaseBar is called for every finished bar, the last one waits for the bars to be rendered completely
+func (dl *Downloader) releaseBar() {
+	dl.barsMu.Lock()
+	dl.activeBars--
+	if dl.activeBars > 0 {
+		dl.barsMu.Unlock()
+		return
+	}
+
+	bars := dl.bars
+	dl.bars = nil
+	dl.barsMu.Unlock()
+
+	bars.Wait()
+}
+
+// The statuses of ProgressEvent
+const (
+	ProgressDownloading = "downloading" // a stream is being downloaded
+	ProgressComplete    = "complete"    // a stream has been downloaded completely
+	ProgressFailed      = "failed"      // the download of a stream stopped before its end
+	ProgressFinished    = "finished"    // the output file is written, not emitted by the Downloader itself
+)
+
+// ProgressEvent is a line of the JSON progress written with ProgressJSON.
+// The fields and statuses are stable, new ones might be added.
+type ProgressEvent struct {
+	Status     string  `json:"status"`
+	ID         string  `json:"id"`
+	Itag       int     `json:"itag,omitempty"`
+	Downloaded int64   `json:"downloaded_bytes"`
+	Total      int64   `json:"total_bytes"`    // 0 if the size of the stream is unknown
+	Percent    float64 `json:"percent"`        // 0 if the size of the stream is unknown
+	Speed      float64 `json:"speed"`          // bytes per second since the previous event
+	Path       string  `json:"path,omitempty"` // the output file of a finished download
+}
+
+// jsonProgressInterval is how often jsonReporter writes the progress of a stream
+const jsonProgressInterval = time.Second
+
+// jsonReporter writes the progress of a stream as a line of JSON about every second, see ProgressEvent
+type jsonReporter struct {
+	encoder *json.Encoder
+	id      string
+	itag    int
+
+	mu       sync.Mutex
+	total    int64
+	current  int64
+	reported int64
+	last     time.Time
+}
+
+func (r *jsonReporter) Start(total int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.total = total
+	r.last = time.Now()
+	r.report(ProgressDownloading)
+}
+
+func (r *jsonReporter) Add(n int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.current += n
+	if time.Since(r.last) >= jsonProgressInterval {
+		r.report(ProgressDownloading)
+	}
+}
+
+func (r *jsonReporter) Finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.total > 0 && r.current < r.total {
+		r.report(ProgressFailed)
+	} else {
+		r.report(ProgressComplete)
+	}
+}
+
+// report writes the current progress, the caller holds mu
+func (r *jsonReporter) report(status string) {
+	now := time.Now()
+
+	event := ProgressEvent{
+		Status:     status,
+		ID:         r.id,
+		Itag:       r.itag,
+		Downloaded: r.current,
+		Total:      r.total,
+	}
+	if r.total > 0 {
+		event.Percent = math.Round(float64(r.current)/float64(r.total)*1000) / 10
+	}
+	if elapsed := now.Sub(r.last).Seconds(); elapsed > 0 {
+		event.Speed = math.Round(float64(r.current-r.reported) / elapsed)
+	}
+
+	// the progress is best-effort, a failing output must not fail the download
+	_ = r.encoder.Encode(event)
+
+	r.reported = r.current
+	r.last = now
+}
+
+// progressWriter reports the writes to a ProgressReporter
+type progressWriter struct {
+	reporter ProgressReporter
+}
+
+func (w progressWriter) Write(p []byte) (int, error) {
+	w.reporter.Add(int64(len(p)))
+	return len(p), nil
+}
+
 type progress struct {
 	contentLength     float64
 	totalWrittenBytes float64
@@ -9,6 +336,10 @@ type progress struct {
 func (dl *progress) Write(p []byte) (n int, err error) {
 	n = len(p)
 	dl.totalWrittenBytes = dl.totalWrittenBytes + float64(n)
+	// there is no level without the size of the stream
+	if dl.contentLength <= 0 {
+		return
+	}
 	currentPercent := (dl.totalWrittenBytes / dl.contentLength) * 100
 	if (dl.downloadLevel <= currentPercent) && (dl.downloadLevel < 100) {
 		dl.downloadLevel++
@@ -0,0 +1,251 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vbauerster/mpb/v5/decor"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// recordingReporter is a ProgressReporter counting the calls
+type recordingReporter struct {
+	total    atomic.Int64
+	added    atomic.Int64
+	finished atomic.Int32
+}
+
+func (r *recordingReporter) Start(total int64) { r.total.Store(total) }
+func (r *recordingReporter) Add(n int64)       { r.added.Add(n) }
+func (r *recordingReporter) Finish()           { r.finished.Add(1) }
+
+func TestDownloader_Progress(t *testing.T) {
+	const content = "0123456789"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var start, end int
+		_, err := fmt.Sscanf(r.URL.Query().Get("range"), "%d-%d", &start, &end)
+		require.NoError(t, err)
+		w.Write([]byte(content[start : end+1])) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	video := &youtube.Video{ID: "BaW_jenozKc"}
+	format := &youtube.Format{URL: server.URL, ContentLength: int64(len(content))}
+
+	for _, concurrency := range []int{1, 3} {
+		out, err := os.Create(filepath.Join(t.TempDir(), "video.mp4"))
+		require.NoError(t, err)
+		defer out.Close()
+
+		reporter := &recordingReporter{}
+		dl := Downloader{Progress: reporter, Concurrency: concurrency}
+		dl.ChunkSize = 4
+
+		_, err = dl.videoDLWorker(context.Background(), out, video, format)
+		require.NoError(t, err)
+
+		assert.EqualValues(t, len(content), reporter.total.Load(), "concurrency %d", concurrency)
+		assert.EqualValues(t, len(content), reporter.added.Load(), "concurrency %d", concurrency)
+		assert.EqualValues(t, 1, reporter.finished.Load(), "concurrency %d", concurrency)
+	}
+}
+
+func TestDownloader_progressReporter(t *testing.T) {
+	video := &youtube.Video{ID: "BaW_jenozKc"}
+	format := &youtube.Format{ItagNo: 18}
+
+	dl := Downloader{}
+	assert.IsType(t, &barReporter{}, dl.progressReporter(video, format))
+
+	dl.Silent = true
+	assert.Equal(t, silentReporter{}, dl.progressReporter(video, format))
+
+	dl.ProgressJSON = true
+	assert.IsType(t, &jsonReporter{}, dl.progressReporter(video, format))
+
+	reporter := &recordingReporter{}
+	dl.Progress = reporter
+	assert.Same(t, reporter, dl.progressReporter(video, format))
+}
+
+func TestDownloader_ProgressJSON(t *testing.T) {
+	const content = "0123456789"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(content)) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	video := &youtube.Video{ID: "BaW_jenozKc"}
+	format := &youtube.Format{URL: server.URL, ItagNo: 18}
+
+	out, err := os.Create(filepath.Join(t.TempDir(), "video.mp4"))
+	require.NoError(t, err)
+	defer out.Close()
+
+	var output bytes.Buffer
+	dl := Downloader{ProgressOutput: &output, ProgressJSON: true}
+	_, err = dl.videoDLWorker(context.Background(), out, video, format)
+	require.NoError(t, err)
+
+	var events []ProgressEvent
+	decoder := json.NewDecoder(&output)
+	for decoder.More() {
+		var event ProgressEvent
+		require.NoError(t, decoder.Decode(&event))
+		events = append(events, event)
+	}
+
+	require.Len(t, events, 2)
+	assert.Equal(t, ProgressEvent{Status: ProgressDownloading, ID: video.ID, Itag: 18, Total: 10}, events[0])
+	assert.Equal(t, ProgressComplete, events[1].Status)
+	assert.EqualValues(t, 10, events[1].Downloaded)
+	assert.EqualValues(t, 100, events[1].Percent)
+}
+
+func Test_jsonReporter_failed(t *testing.T) {
+	var output bytes.Buffer
+	r := &jsonReporter{encoder: json.NewEncoder(&output), id: "BaW_jenozKc"}
+	r.Start(10)
+	r.Add(4)
+	r.Finish()
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[1], `"status":"failed"`)
+	assert.Contains(t, lines[1], `"downloaded_bytes":4,"total_bytes":10,"percent":40`)
+}
+
+func TestDownloader_barsShared(t *testing.T) {
+	dl := Downloader{ProgressOutput: io.Discard}
+
+	first := dl.progressReporter(&youtube.Video{}, &youtube.Format{})
+	second := dl.progressReporter(&youtube.Video{}, &youtube.Format{})
+
+	first.Start(10)
+	bars := dl.bars
+	second.Start(0)
+	assert.Same(t, bars, dl.bars, "concurrent streams share the bars")
+
+	first.Add(10)
+	first.Finish()
+	assert.NotNil(t, dl.bars)
+
+	second.Add(5)
+	second.Finish()
+	assert.Nil(t, dl.bars, "the last bar stops rendering")
+}
+
+func TestDownloader_StartBatch(t *testing.T) {
+	dl := Downloader{ProgressOutput: io.Discard}
+
+	batch := dl.StartBatch(2)
+	bars := dl.bars
+	require.NotNil(t, bars)
+
+	first := dl.progressReporter(&youtube.Video{}, &youtube.Format{})
+	first.Start(10)
+	first.Add(10)
+	first.Finish()
+	batch.FileDone()
+	assert.Same(t, bars, dl.bars, "the overall bar keeps rendering between the files")
+
+	second := dl.progressReporter(&youtube.Video{}, &youtube.Format{})
+	second.Start(0)
+	second.Add(5)
+	second.Finish()
+	batch.FileDone()
+
+	assert.EqualValues(t, 10, batch.total, "streams of unknown size are left out")
+	assert.Equal(t, " + 1 streams of unknown size", batch.unknownDecorator(decor.Statistics{}))
+	assert.Equal(t, "file 2 of 2", batch.filesDecorator(decor.Statistics{}))
+
+	batch.Finish()
+	assert.Nil(t, dl.bars, "the overall bar stops rendering")
+	assert.Nil(t, dl.batch)
+}
+
+func TestDownloader_StartBatch_silent(t *testing.T) {
+	dl := Downloader{Silent: true}
+
+	batch := dl.StartBatch(2)
+	batch.FileDone()
+	batch.Finish()
+	assert.Nil(t, dl.bars)
+}
+
+func TestDownloader_progressReporter_unknownSize(t *testing.T) {
+	var output bytes.Buffer
+	dl := Downloader{ProgressOutput: &output}
+
+	reporter := dl.progressReporter(&youtube.Video{}, &youtube.Format{})
+	reporter.Start(0)
+	reporter.Add(2048)
+	reporter.Finish()
+
+	assert.Contains(t, output.String(), "2.00 KiB")
+	assert.NotContains(t, output.String(), "%", "no percentage without a size")
+}
+
+func Test_progress_unknownSize(t *testing.T) {
+	prog := &progress{}
+
+	_, err := prog.Write(make([]byte, 10))
+	require.NoError(t, err)
+	assert.Equal(t, 10.0, prog.totalWrittenBytes)
+	assert.Zero(t, prog.downloadLevel)
+}
+
+func Test_barReporter_stall(t *testing.T) {
+	dl := &Downloader{ProgressOutput: io.Discard}
+	r := &barReporter{dl: dl}
+	r.Start(10 * youtube.Size1Mb)
+	defer r.Finish()
+
+	// a steady microsecond per byte, past the warmup of the averages
+	for i := 0; i < 20; i++ {
+		r.mu.Lock()
+		r.last = time.Now().Add(-time.Millisecond)
+		r.mu.Unlock()
+		r.Add(1000)
+	}
+	steady := r.speed.Value()
+	require.Greater(t, steady, 0.0)
+
+	r.checkStall(r.last.Add(time.Second))
+	assert.False(t, r.stalled, "not stalled before stallTimeout")
+	assert.Equal(t, steady, r.speed.Value())
+
+	stall := r.last.Add(stallTimeout)
+	r.checkStall(stall)
+	assert.True(t, r.stalled)
+	assert.Greater(t, r.speed.Value(), 1000*steady, "a second per byte, the speed is about zero")
+	assert.Greater(t, r.eta.Value(), 1000*steady)
+
+	// every check while stalled adds to it
+	stalledValue := r.speed.Value()
+	r.checkStall(stall.Add(stallCheckInterval))
+	assert.Greater(t, r.speed.Value(), stalledValue)
+
+	r.mu.Lock()
+	r.last = time.Now().Add(-time.Millisecond)
+	r.mu.Unlock()
+	r.Add(1000)
+	assert.False(t, r.stalled)
+	assert.Less(t, r.speed.Value(), 10*steady, "the bytes after the stall restart the averages")
+}
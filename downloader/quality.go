@@ -0,0 +1,43 @@
+package downloader
+
+import (
+	"strings"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// QualityBest and QualityWorst select the formats by their order instead of a quality label:
+// the video and audio formats sorting first, or last. They match all formats in FilterQuality.
+const (
+	QualityBest  = "best"
+	QualityWorst = "worst"
+)
+
+// FilterQuality reduces the formats to the ones of the first quality of a comma separated list that has any,
+// e.g. "hd1080,hd720,medium" selects the 720p formats of a video without 1080p ones, and returns that quality.
+// Each quality is matched like FormatList.Quality, the result is empty if none matches.
+// QualityBest and QualityWorst match all formats, e.g. "hd1080,best" falls back to the best quality there is.
+func FilterQuality(formats youtube.FormatList, qualities string) (youtube.FormatList, string) {
+	candidates := strings.Split(qualities, ",")
+
+	for _, quality := range candidates {
+		quality = strings.TrimSpace(quality)
+		if quality == "" {
+			continue
+		}
+
+		matching := formats.Quality(quality)
+		if quality == QualityBest || quality == QualityWorst {
+			matching = formats
+		}
+
+		if len(matching) > 0 {
+			if len(candidates) > 1 {
+				youtube.Logger.Info("using quality", "quality", quality, "requested", qualities)
+			}
+			return matching, quality
+		}
+	}
+
+	return nil, ""
+}
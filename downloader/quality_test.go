@@ -0,0 +1,92 @@
+package downloader
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func TestFilterQuality(t *testing.T) {
+	formats := youtube.FormatList{
+		{ItagNo: 136, Quality: "hd720", QualityLabel: "720p"},
+		{ItagNo: 247, Quality: "hd720", QualityLabel: "720p"},
+		{ItagNo: 18, Quality: "medium", QualityLabel: "360p"},
+	}
+
+	tests := []struct {
+		qualities string
+		want      youtube.FormatList
+		matched   string
+	}{
+		{"hd720", formats[:2], "hd720"},
+		{"hd1080,hd720,medium", formats[:2], "hd720"},
+		{"hd1080, medium", formats[2:], "medium"},
+		{"18", formats[2:], "18"},
+		{"hd1080,hd1440", nil, ""},
+		{"best", formats, "best"},
+		{"hd1080,worst", formats, "worst"},
+		{"worst,hd720", formats, "worst"},
+	}
+
+	for _, tt := range tests {
+		got, matched := FilterQuality(formats, tt.qualities)
+		assert.Equal(t, tt.want, got, tt.qualities)
+		assert.Equal(t, tt.matched, matched, tt.qualities)
+	}
+}
+
+func TestDownloader_getVideoAudioFormats_qualityList(t *testing.T) {
+	video := &youtube.Video{Formats: youtube.FormatList{
+		{ItagNo: 136, MimeType: `video/mp4; codecs="avc1.4d401f"`, Quality: "hd720", QualityLabel: "720p"},
+		{ItagNo: 134, MimeType: `video/mp4; codecs="avc1.4d401e"`, Quality: "medium", QualityLabel: "360p"},
+		{ItagNo: 140, MimeType: `audio/mp4; codecs="mp4a.40.2"`, AudioChannels: 2},
+	}}
+
+	dl := Downloader{}
+	videoFormat, _, err := dl.getVideoAudioFormats(video, "hd1080,hd720,medium", "")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 136, videoFormat.ItagNo)
+	}
+
+	_, _, err = dl.getVideoAudioFormats(video, "hd1080,hd1440", "")
+	assert.EqualError(t, err, "no video format found after filtering")
+}
+
+func TestDownloader_getVideoAudioFormats_bestWorst(t *testing.T) {
+	video := &youtube.Video{Formats: youtube.FormatList{
+		{ItagNo: 134, MimeType: `video/mp4; codecs="avc1.4d401e"`, Quality: "medium", QualityLabel: "360p", Width: 640},
+		{ItagNo: 137, MimeType: `video/mp4; codecs="avc1.640028"`, Quality: "hd1080", QualityLabel: "1080p", Width: 1920},
+		{ItagNo: 160, MimeType: `video/mp4; codecs="avc1.4d400c"`, Quality: "tiny", QualityLabel: "144p", Width: 256},
+		{ItagNo: 139, MimeType: `audio/mp4; codecs="mp4a.40.5"`, AudioChannels: 2, Bitrate: 48000},
+		{ItagNo: 140, MimeType: `audio/mp4; codecs="mp4a.40.2"`, AudioChannels: 2, Bitrate: 128000},
+		{ItagNo: 18, MimeType: `video/mp4; codecs="avc1.42001E, mp4a.40.2"`, Quality: "medium", QualityLabel: "360p", Width: 640, AudioChannels: 2},
+	}}
+
+	dl := Downloader{}
+	videoFormat, audioFormat, err := dl.getVideoAudioFormats(video, QualityBest, "")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 137, videoFormat.ItagNo)
+		assert.Equal(t, 140, audioFormat.ItagNo)
+	}
+
+	tests := []struct {
+		quality              string
+		wantVideo, wantAudio int
+	}{
+		{QualityWorst, 160, 139},
+		{"hd1440,worst", 160, 139},
+		{"worst,hd1080", 160, 139},
+		{"hd1080,worst", 137, 140},
+		{"hd1440,best", 137, 140},
+	}
+
+	for _, tt := range tests {
+		videoFormat, audioFormat, err := dl.getVideoAudioFormats(video, tt.quality, "")
+		if assert.NoError(t, err, tt.quality) {
+			assert.Equal(t, tt.wantVideo, videoFormat.ItagNo, tt.quality)
+			assert.Equal(t, tt.wantAudio, audioFormat.ItagNo, tt.quality)
+		}
+	}
+}
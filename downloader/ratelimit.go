@@ -0,0 +1,128 @@
+package downloader
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// rateLimiter is a token bucket of bytes, refilled at rate per second and holding up to one second of bytes
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	return &rateLimiter{
+		rate:   float64(bytesPerSecond),
+		tokens: float64(bytesPerSecond),
+		last:   time.Now(),
+	}
+}
+
+// burst is the most bytes a single read may take
+func (l *rateLimiter) burst() int {
+	return max(int(l.rate), 1)
+}
+
+// wait takes n bytes from the bucket, blocking until they are available or ctx is done
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*l.rate, l.rate)
+	l.last = now
+	// the bytes are reserved right away, so readers sharing the bucket queue up behind each other
+	l.tokens -= float64(n)
+	deficit := -l.tokens
+	l.mu.Unlock()
+
+	if deficit <= 0 {
+		return ctx.Err()
+	}
+
+	return sleepContext(ctx, time.Duration(deficit/l.rate*float64(time.Second)))
+}
+
+// rateLimitedReader throttles the reads of r with a rateLimiter
+type rateLimitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rateLimiter
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	if burst := r.limiter.burst(); len(p) > burst {
+		p = p[:burst]
+	}
+
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if werr := r.limiter.wait(r.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+
+	return n, err
+}
+
+// getRateLimiter returns the limiter shared by all streams, or nil without MaxBytesPerSecond.
+// It is created on first use and again when MaxBytesPerSecond changes.
+func (dl *Downloader) getRateLimiter() *rateLimiter {
+	if dl.MaxBytesPerSecond <= 0 {
+		return nil
+	}
+
+	dl.limiterMu.Lock()
+	defer dl.limiterMu.Unlock()
+
+	if dl.limiter == nil || dl.limiter.rate != float64(dl.MaxBytesPerSecond) {
+		dl.limiter = newRateLimiter(dl.MaxBytesPerSecond)
+	}
+
+	return dl.limiter
+}
+
+// limitRate wraps r to read at most as fast as the limiter allows, a nil limiter returns r
+func limitRate(ctx context.Context, r io.Reader, limiter *rateLimiter) io.Reader {
+	if limiter == nil {
+		return r
+	}
+
+	return &rateLimitedReader{ctx: ctx, r: r, limiter: limiter}
+}
+
+// requestJitter is the largest share of RequestInterval added to a wait, so the requests aren't perfectly periodic
+const requestJitter = 0.25
+
+// waitRequest blocks until the next video may be fetched after RequestInterval, or ctx is done.
+// The first request doesn't wait, concurrent ones are spaced out behind each other.
+func (dl *Downloader) waitRequest(ctx context.Context) error {
+	if dl.RequestInterval <= 0 {
+		return ctx.Err()
+	}
+
+	dl.requestMu.Lock()
+	at := time.Now()
+	if dl.nextRequest.After(at) {
+		at = dl.nextRequest
+	}
+	jitter := time.Duration(rand.Int63n(int64(float64(dl.RequestInterval)*requestJitter) + 1)) //nolint:gosec
+	// reserved right away, like the bytes of rateLimiter
+	dl.nextRequest = at.Add(dl.RequestInterval + jitter)
+	dl.requestMu.Unlock()
+
+	wait := time.Until(at)
+	if wait <= 0 {
+		return ctx.Err()
+	}
+
+	youtube.Logger.Debug("waiting before fetching the video", "wait", wait)
+
+	return sleepContext(ctx, wait)
+}
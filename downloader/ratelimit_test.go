@@ -0,0 +1,127 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func Test_rateLimitedReader(t *testing.T) {
+	data := make([]byte, 3000)
+	r := limitRate(context.Background(), bytes.NewReader(data), newRateLimiter(2000))
+
+	start := time.Now()
+	n, err := io.Copy(io.Discard, r)
+	require.NoError(t, err)
+	assert.EqualValues(t, len(data), n)
+
+	// the first second of bytes is the burst, the rest takes half a second
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+}
+
+func Test_rateLimitedReader_cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	r := limitRate(ctx, bytes.NewReader(make([]byte, 1000)), newRateLimiter(10))
+
+	start := time.Now()
+	_, err := io.Copy(io.Discard, r)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func Test_limitRate_unlimited(t *testing.T) {
+	r := bytes.NewReader(nil)
+	assert.Same(t, r, limitRate(context.Background(), r, (&Downloader{}).getRateLimiter()))
+}
+
+func TestDownloader_getRateLimiter(t *testing.T) {
+	dl := &Downloader{MaxBytesPerSecond: 1000}
+
+	limiter := dl.getRateLimiter()
+	assert.Same(t, limiter, dl.getRateLimiter(), "all streams share the limiter")
+
+	dl.MaxBytesPerSecond = 2000
+	assert.NotSame(t, limiter, dl.getRateLimiter())
+	assert.Equal(t, 2000.0, dl.getRateLimiter().rate)
+}
+
+func TestDownloader_videoDLWorker_MaxBytesPerSecond(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 300)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	video := &youtube.Video{ID: "BaW_jenozKc"}
+	format := &youtube.Format{URL: server.URL}
+
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	out, err := os.Create(path)
+	require.NoError(t, err)
+	defer out.Close()
+
+	reporter := &recordingReporter{}
+	dl := Downloader{
+		Progress:          reporter,
+		MaxBytesPerSecond: 2000,
+	}
+
+	start := time.Now()
+	written, err := dl.videoDLWorker(context.Background(), out, video, format)
+	require.NoError(t, err)
+	assert.EqualValues(t, len(content), written)
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+
+	// the progress sees every byte despite the throttle
+	assert.EqualValues(t, len(content), reporter.added.Load())
+}
+
+func TestDownloader_waitRequest(t *testing.T) {
+	dl := &Downloader{RequestInterval: 100 * time.Millisecond}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, dl.waitRequest(context.Background()))
+	}
+	elapsed := time.Since(start)
+
+	// the first request doesn't wait, the jitter adds up to a quarter of each interval
+	assert.GreaterOrEqual(t, elapsed, 200*time.Millisecond)
+	assert.Less(t, elapsed, 2*time.Second)
+}
+
+func TestDownloader_waitRequest_cancel(t *testing.T) {
+	dl := &Downloader{RequestInterval: time.Hour}
+	require.NoError(t, dl.waitRequest(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	assert.ErrorIs(t, dl.waitRequest(ctx), context.Canceled)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestDownloader_waitRequest_disabled(t *testing.T) {
+	dl := &Downloader{}
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		require.NoError(t, dl.waitRequest(context.Background()))
+	}
+	assert.Less(t, time.Since(start), time.Second)
+}
@@ -0,0 +1,99 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// maxURLRefreshes limits how often the stream URL of a download is refreshed, a video blocked for good keeps failing
+const maxURLRefreshes = 3
+
+// urlRefresher replaces the stream URL of a format expiring during its download with a fresh one, see RefreshURLsOn403.
+// The parts of a concurrent download share it, so the video is fetched again once for all of them.
+type urlRefresher struct {
+	dl    *Downloader
+	video *youtube.Video
+
+	mu        sync.Mutex
+	format    *youtube.Format
+	refreshes int
+}
+
+func (dl *Downloader) newURLRefresher(video *youtube.Video, format *youtube.Format) *urlRefresher {
+	return &urlRefresher{dl: dl, video: video, format: format}
+}
+
+// current returns the format with the latest URL
+func (r *urlRefresher) current() *youtube.Format {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.format
+}
+
+// refresh fetches the video again after the URL of the failed format was forbidden with cause,
+// and returns the format of the same itag and audio track with a fresh URL.
+// If another part refreshed the URL meanwhile, its format is returned without fetching the video.
+// Without RefreshURLsOn403 or after maxURLRefreshes it returns cause.
+func (r *urlRefresher) refresh(ctx context.Context, failed *youtube.Format, cause error) (*youtube.Format, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.format != failed {
+		return r.format, nil
+	}
+
+	if !r.dl.RefreshURLsOn403 || r.refreshes >= maxURLRefreshes {
+		return nil, cause
+	}
+	r.refreshes++
+
+	youtube.Logger.Warn("stream URL forbidden, it probably expired, fetching the video again for a fresh one",
+		"id", r.video.ID, "itag", failed.ItagNo, "refresh", r.refreshes)
+
+	video, err := r.dl.fetchVideo(ctx, r.video.ID)
+	if err != nil {
+		return nil, fmt.Errorf("%w, refreshing the stream URL failed: %w", cause, err)
+	}
+
+	fresh := findSameFormat(video.Formats, failed)
+	if fresh == nil {
+		return nil, fmt.Errorf("%w, the refreshed video has no format with itag %d", cause, failed.ItagNo)
+	}
+	if fresh.ContentLength != failed.ContentLength {
+		return nil, fmt.Errorf("%w, the refreshed format has %d bytes instead of %d", cause, fresh.ContentLength, failed.ContentLength)
+	}
+
+	// decipher the URL once, before the parts share the client
+	if _, err := r.dl.GetStreamURLContext(ctx, r.video, fresh); err != nil {
+		return nil, fmt.Errorf("%w, refreshing the stream URL failed: %w", cause, err)
+	}
+
+	youtube.Logger.Info("refreshed the stream URL, resuming the download", "id", r.video.ID, "itag", fresh.ItagNo)
+
+	r.format = fresh
+
+	return fresh, nil
+}
+
+// findSameFormat returns the format of the list with the itag and audio track of format, or nil
+func findSameFormat(list youtube.FormatList, format *youtube.Format) *youtube.Format {
+	for i := range list {
+		if list[i].ItagNo == format.ItagNo && audioTrackID(&list[i]) == audioTrackID(format) {
+			return &list[i]
+		}
+	}
+
+	return nil
+}
+
+func audioTrackID(format *youtube.Format) string {
+	if format.AudioTrack == nil {
+		return ""
+	}
+
+	return format.AudioTrack.ID
+}
@@ -0,0 +1,142 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// playerTransport answers the player requests of youtube.com with response and sends the others to the test server
+type playerTransport struct {
+	response string
+	players  atomic.Int32
+}
+
+func (t *playerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != "www.youtube.com" {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+
+	t.players.Add(1)
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(t.response)),
+		Request:    req,
+	}, nil
+}
+
+func TestDownloader_videoDLWorker_refreshURLsOn403(t *testing.T) {
+	const content = "0123456789"
+
+	var expiredRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var start, end int
+		_, err := fmt.Sscanf(r.URL.Query().Get("range"), "%d-%d", &start, &end)
+		require.NoError(t, err)
+
+		// the expired URL is forbidden after the first chunk
+		if r.URL.Path == "/expired" {
+			expiredRequests.Add(1)
+			if start > 0 {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+		}
+		w.Write([]byte(content[start : end+1])) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	video := &youtube.Video{ID: "BaW_jenozKc"}
+	format := &youtube.Format{ItagNo: 137, URL: server.URL + "/expired", ContentLength: int64(len(content))}
+
+	for _, concurrency := range []int{1, 2} {
+		t.Run(fmt.Sprintf("concurrency %d", concurrency), func(t *testing.T) {
+			expiredRequests.Store(0)
+			transport := &playerTransport{response: `{
+				"playabilityStatus": {"status": "OK"},
+				"streamingData": {"adaptiveFormats": [
+					{"itag": 137, "url": "` + server.URL + `/fresh", "mimeType": "video/mp4", "contentLength": "10"}
+				]}
+			}`}
+
+			path := filepath.Join(t.TempDir(), "video.mp4")
+			out, err := os.Create(path)
+			require.NoError(t, err)
+			defer out.Close()
+
+			dl := Downloader{ProgressOutput: io.Discard, Concurrency: concurrency}
+			dl.HTTPClient = &http.Client{Transport: transport}
+			dl.ChunkSize = 4
+			dl.MaxRoutines = 1
+
+			_, err = dl.videoDLWorker(context.Background(), out, video, format)
+			assert.ErrorIs(t, err, youtube.ErrUnexpectedStatusCode(http.StatusForbidden), "disabled")
+			assert.Zero(t, transport.players.Load())
+
+			require.NoError(t, rewind(out))
+			dl.RefreshURLsOn403 = true
+			written, err := dl.videoDLWorker(context.Background(), out, video, format)
+			require.NoError(t, err)
+			assert.EqualValues(t, len(content), written)
+			assert.EqualValues(t, 1, transport.players.Load(), "the video is fetched once")
+
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, content, string(data))
+		})
+	}
+}
+
+func TestURLRefresher_refresh(t *testing.T) {
+	video := &youtube.Video{ID: "BaW_jenozKc"}
+	format := &youtube.Format{ItagNo: 137, URL: "https://example.com/expired", ContentLength: 10}
+	cause := youtube.ErrUnexpectedStatusCode(http.StatusForbidden)
+
+	transport := &playerTransport{response: `{
+		"playabilityStatus": {"status": "OK"},
+		"streamingData": {"adaptiveFormats": [
+			{"itag": 137, "url": "https://example.com/fresh", "mimeType": "video/mp4", "contentLength": "20"}
+		]}
+	}`}
+	dl := Downloader{RefreshURLsOn403: true}
+	dl.HTTPClient = &http.Client{Transport: transport}
+
+	refresher := dl.newURLRefresher(video, format)
+	_, err := refresher.refresh(context.Background(), format, cause)
+	assert.ErrorIs(t, err, cause)
+	assert.ErrorContains(t, err, "the refreshed format has 20 bytes instead of 10")
+
+	format.ContentLength = 20
+	fresh, err := refresher.refresh(context.Background(), format, cause)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/fresh", fresh.URL)
+
+	// the format failing in another part was refreshed already
+	again, err := refresher.refresh(context.Background(), format, cause)
+	require.NoError(t, err)
+	assert.Same(t, fresh, again)
+	assert.EqualValues(t, 2, transport.players.Load())
+
+	format.ItagNo = 22
+	refresher = dl.newURLRefresher(video, format)
+	_, err = refresher.refresh(context.Background(), format, cause)
+	assert.ErrorContains(t, err, "the refreshed video has no format with itag 22")
+
+	refresher.refreshes = maxURLRefreshes
+	_, err = refresher.refresh(context.Background(), format, cause)
+	assert.Equal(t, cause, err, "too many refreshes")
+}
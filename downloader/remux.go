@@ -0,0 +1,91 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// Remux copies the streams of the file into another container, like mp4 for a webm download,
+// and renames it to the extension of the container. The codecs are checked with ffprobe first:
+// if the container can't hold them the file is left unchanged and an error returned, re-encoding is up to the caller.
+// Files already in the container are left unchanged. An existing file of the container is kept with SkipExisting,
+// the remuxed one is given a new name with UniqueNames and replaces it otherwise, like the output of a download.
+// Run it before WriteMetadata and EmbedThumbnail, which depend on the container.
+type Remux struct {
+	Container string // ContainerMP4, ContainerWebM or ContainerMKV
+}
+
+// PostProcess implements the PostProcessor interface
+func (r Remux) PostProcess(ctx context.Context, dl *Downloader, v *youtube.Video, path string) (string, error) {
+	if _, ok := containerCodecs[r.Container]; !ok {
+		return "", fmt.Errorf("unsupported container %s, use %s, %s or %s", r.Container, ContainerMP4, ContainerWebM, ContainerMKV)
+	}
+
+	ext := filepath.Ext(path)
+	if strings.EqualFold(ext, "."+r.Container) {
+		return path, nil
+	}
+
+	// the target is named like the output of a download, so an existing file isn't overwritten with UniqueNames
+	destFile := dl.uniqueName(strings.TrimSuffix(path, ext) + "." + r.Container)
+	defer dl.releaseName(destFile)
+	if dl.skipExisting(destFile, 0) {
+		if dl.PrintFFmpegCommands {
+			return path, nil
+		}
+		// remuxed before, the download is a duplicate of it
+		return destFile, os.Remove(path)
+	}
+
+	// the printed commands don't write the file to probe
+	if !dl.PrintFFmpegCommands {
+		if err := r.checkCodecs(ctx, dl, path); err != nil {
+			return "", err
+		}
+	}
+
+	youtube.Logger.Debug("remuxing", "path", path, "container", r.Container)
+
+	// only the video and audio, other containers may not hold the subtitles or data streams
+	args := rewriteArgs(path, destFile, []string{"-map", "0:v?", "-map", "0:a?", "-c", "copy"})
+	if err := dl.runFFmpeg(ctx, args...); err != nil {
+		os.Remove(destFile)
+		return "", err
+	}
+	if dl.PrintFFmpegCommands {
+		return path, nil
+	}
+
+	return destFile, os.Remove(path)
+}
+
+// checkCodecs returns an error if the container can't hold the video and audio streams of the file
+func (r Remux) checkCodecs(ctx context.Context, dl *Downloader, path string) error {
+	probe, err := dl.Probe(ctx, path)
+	if err != nil {
+		return fmt.Errorf("probing the codecs of %s: %w", path, err)
+	}
+
+	for _, stream := range probe.Streams {
+		if stream.CodecType != "video" && stream.CodecType != "audio" {
+			continue
+		}
+
+		// ffprobe names the codecs like formatCodecs
+		videoCodec, audioCodec := stream.CodecName, ""
+		if stream.CodecType == "audio" {
+			videoCodec, audioCodec = "", stream.CodecName
+		}
+		if !canHold(r.Container, videoCodec, audioCodec) {
+			return fmt.Errorf("can't remux %s into %s, the container can't hold its %s %s stream, use %s or re-encode it",
+				path, r.Container, stream.CodecName, stream.CodecType, ContainerMKV)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func TestRemux(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ffmpeg is a shell script")
+	}
+
+	fakeFFmpeg(t, "eval out=\\${$#}\necho remuxed > \"$out\"\n")
+	fakeFFprobe(t, probeStreams(`[{"index": 0, "codec_type": "video", "codec_name": "vp9"}, {"index": 1, "codec_type": "audio", "codec_name": "opus"}]`))
+
+	path := filepath.Join(t.TempDir(), "video.webm")
+	require.NoError(t, os.WriteFile(path, []byte("webm"), 0o644))
+
+	dl := &Downloader{}
+	video := &youtube.Video{ID: "BaW_jenozKc"}
+
+	// vp9 doesn't fit into mp4
+	_, err := Remux{Container: ContainerMP4}.PostProcess(context.Background(), dl, video, path)
+	assert.EqualError(t, err, "can't remux "+path+" into mp4, the container can't hold its vp9 video stream, use mkv or re-encode it")
+	assert.FileExists(t, path)
+
+	remuxed, err := Remux{Container: ContainerMKV}.PostProcess(context.Background(), dl, video, path)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(filepath.Dir(path), "video.mkv"), remuxed)
+	assert.NoFileExists(t, path)
+
+	data, err := os.ReadFile(remuxed)
+	require.NoError(t, err)
+	assert.Equal(t, "remuxed\n", string(data))
+
+	// already in the container
+	same, err := Remux{Container: ContainerMKV}.PostProcess(context.Background(), dl, video, remuxed)
+	require.NoError(t, err)
+	assert.Equal(t, remuxed, same)
+
+	_, err = Remux{Container: "avi"}.PostProcess(context.Background(), dl, video, remuxed)
+	assert.EqualError(t, err, "unsupported container avi, use mp4, webm or mkv")
+}
+
+func TestRemux_probeNames(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ffmpeg is a shell script")
+	}
+
+	fakeFFmpeg(t, "eval out=\\${$#}\necho remuxed > \"$out\"\n")
+	fakeFFprobe(t, probeStreams(`[{"index": 0, "codec_type": "video", "codec_name": "h264"}, {"index": 1, "codec_type": "audio", "codec_name": "aac"}]`))
+
+	path := filepath.Join(t.TempDir(), "video.mkv")
+	require.NoError(t, os.WriteFile(path, []byte("mkv"), 0o644))
+
+	remuxed, err := Remux{Container: ContainerMP4}.PostProcess(context.Background(), &Downloader{}, &youtube.Video{}, path)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(filepath.Dir(path), "video.mp4"), remuxed)
+}
+
+func TestRemux_existing(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ffmpeg is a shell script")
+	}
+
+	fakeFFmpeg(t, "eval out=\\${$#}\necho remuxed > \"$out\"\n")
+	fakeFFprobe(t, probeStreams(`[{"index": 0, "codec_type": "video", "codec_name": "vp9"}]`))
+
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "video.mkv")
+	require.NoError(t, os.WriteFile(existing, []byte("existing"), 0o644))
+
+	tests := []struct {
+		dl   *Downloader
+		want string
+	}{
+		{&Downloader{UniqueNames: true}, filepath.Join(dir, "video (1).mkv")},
+		{&Downloader{SkipExisting: true}, existing},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(dir, "video.webm")
+		require.NoError(t, os.WriteFile(path, []byte("webm"), 0o644))
+
+		remuxed, err := Remux{Container: ContainerMKV}.PostProcess(context.Background(), tt.dl, &youtube.Video{}, path)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, remuxed)
+		assert.NoFileExists(t, path)
+
+		data, err := os.ReadFile(existing)
+		require.NoError(t, err)
+		assert.Equal(t, "existing", string(data), "not overwritten")
+	}
+}
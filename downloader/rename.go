@@ -0,0 +1,64 @@
+package downloader
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// rename is replaced by tests to simulate moves across filesystems
+var rename = os.Rename
+
+// safeRename moves the file like os.Rename, but also between filesystems.
+// There the file is copied next to dst and renamed, so dst appears complete or not at all.
+func safeRename(src, dst string) error {
+	err := rename(src, dst)
+	if err == nil || !isCrossDevice(err) {
+		return err
+	}
+
+	if err = copyFile(src, dst); err != nil {
+		return err
+	}
+
+	return os.Remove(src)
+}
+
+// copyFile copies src to dst through a temporary file in the directory of dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.CreateTemp(filepath.Dir(dst), "youtube_*"+filepath.Ext(dst))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name()) // fails once renamed
+
+	if err = out.Chmod(info.Mode().Perm()); err == nil {
+		_, err = io.Copy(out, in)
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(out.Name(), dst)
+}
+
+// isCrossDevice reports whether the rename failed because src and dst are on different filesystems
+func isCrossDevice(err error) bool {
+	var linkErr *os.LinkError
+	return errors.As(err, &linkErr) && errors.Is(linkErr.Err, errCrossDevice)
+}
@@ -0,0 +1,7 @@
+//go:build !windows
+
+package downloader
+
+import "syscall"
+
+const errCrossDevice = syscall.EXDEV
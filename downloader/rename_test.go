@@ -0,0 +1,78 @@
+package downloader
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func TestSafeRename_crossDevice(t *testing.T) {
+	// os.Rename fails like between filesystems
+	rename = func(src, dst string) error {
+		return &os.LinkError{Op: "rename", Old: src, New: dst, Err: errCrossDevice}
+	}
+	t.Cleanup(func() { rename = os.Rename })
+
+	src := filepath.Join(t.TempDir(), "youtube_123.mp4")
+	dst := filepath.Join(t.TempDir(), "video.mp4")
+	require.NoError(t, os.WriteFile(src, []byte("video"), 0o644))
+
+	require.NoError(t, safeRename(src, dst))
+
+	data, err := os.ReadFile(dst)
+	require.NoError(t, err)
+	assert.Equal(t, "video", string(data))
+	assert.NoFileExists(t, src)
+
+	entries, err := os.ReadDir(filepath.Dir(dst))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no temporary file is left")
+
+	if info, err := os.Stat(dst); assert.NoError(t, err) && runtime.GOOS != "windows" {
+		assert.Equal(t, os.FileMode(0o644), info.Mode().Perm())
+	}
+}
+
+func TestSafeRename_otherErrors(t *testing.T) {
+	rename = func(src, dst string) error {
+		return &os.LinkError{Op: "rename", Old: src, New: dst, Err: syscall.EACCES}
+	}
+	t.Cleanup(func() { rename = os.Rename })
+
+	src := filepath.Join(t.TempDir(), "youtube_123.mp4")
+	require.NoError(t, os.WriteFile(src, []byte("video"), 0o644))
+
+	assert.ErrorIs(t, safeRename(src, filepath.Join(t.TempDir(), "video.mp4")), syscall.EACCES)
+	assert.FileExists(t, src)
+}
+
+func TestDownloader_Download_tempDir(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("video")) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	dl := Downloader{OutputDir: t.TempDir(), TempDir: t.TempDir(), ProgressOutput: io.Discard}
+	result, err := dl.Download(context.Background(), &youtube.Video{ID: "BaW_jenozKc", Title: "Title"}, &youtube.Format{URL: server.URL, MimeType: "video/mp4"}, "")
+	require.NoError(t, err)
+
+	assert.Equal(t, filepath.Join(dl.OutputDir, "Title.mp4"), result.Path)
+	data, err := os.ReadFile(result.Path)
+	require.NoError(t, err)
+	assert.Equal(t, "video", string(data))
+
+	entries, err := os.ReadDir(dl.TempDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
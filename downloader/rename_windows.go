@@ -0,0 +1,8 @@
+//go:build windows
+
+package downloader
+
+import "syscall"
+
+// ERROR_NOT_SAME_DEVICE
+const errCrossDevice = syscall.Errno(17)
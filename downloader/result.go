@@ -0,0 +1,34 @@
+package downloader
+
+import (
+	"time"
+)
+
+// DownloadResult describes a finished download
+type DownloadResult struct {
+	Path         string        // the written file
+	Itag         int           // the downloaded format, the video format of composite downloads
+	AudioItag    int           // the audio format of composite downloads
+	Bytes        int64         // number of bytes downloaded
+	Elapsed      time.Duration // time the whole download took
+	MergeElapsed time.Duration // time the merge of composite downloads took
+	Skipped      bool          // the file already existed, see Downloader.SkipExisting
+}
+
+// complete reports the result of a successful download to OnComplete and returns it
+func (dl *Downloader) complete(result *DownloadResult) (*DownloadResult, error) {
+	if dl.OnComplete != nil {
+		dl.OnComplete(*result)
+	}
+
+	return result, nil
+}
+
+// BytesPerSecond returns the average download speed, including the time spent merging and post-processing
+func (r *DownloadResult) BytesPerSecond() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+
+	return float64(r.Bytes) / r.Elapsed.Seconds()
+}
@@ -0,0 +1,73 @@
+package downloader
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func TestDownloadResult_BytesPerSecond(t *testing.T) {
+	result := DownloadResult{Bytes: 3 << 20, Elapsed: 2 * time.Second}
+	assert.InDelta(t, 1.5*(1<<20), result.BytesPerSecond(), 1)
+
+	// skipped downloads might not have taken any time
+	assert.Zero(t, (&DownloadResult{}).BytesPerSecond())
+}
+
+func TestDownloader_OnComplete(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("video")) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	video := &youtube.Video{ID: "BaW_jenozKc", Title: "Title"}
+	format := &youtube.Format{ItagNo: 18, URL: server.URL, MimeType: "video/mp4"}
+
+	var results []DownloadResult
+	dl := Downloader{OutputDir: t.TempDir(), ProgressOutput: io.Discard, OnComplete: func(result DownloadResult) {
+		results = append(results, result)
+	}}
+
+	_, err := dl.Download(context.Background(), video, &youtube.Format{URL: server.URL + "/missing"}, "")
+	require.Error(t, err)
+	assert.Empty(t, results, "failed downloads are not reported")
+
+	_, err = dl.Download(context.Background(), video, format, "")
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, filepath.Join(dl.OutputDir, "Title.mp4"), results[0].Path)
+	assert.Equal(t, 18, results[0].Itag)
+	assert.EqualValues(t, 5, results[0].Bytes)
+
+	dl.SkipExisting = true
+	_, err = dl.Download(context.Background(), video, format, "")
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.True(t, results[1].Skipped)
+
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+	fakeFFmpeg(t, "eval out=\\${$#}\necho merged > \"$out\"\n")
+
+	dl.SkipExisting = false
+	result, err := dl.DownloadComposite(context.Background(), "", compositeTestVideo(t), "hd1080", "")
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, *result, results[2])
+	assert.Equal(t, 140, results[2].AudioItag)
+}
@@ -0,0 +1,101 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+const (
+	retryBackoffBase = time.Second
+	retryBackoffMax  = 30 * time.Second
+)
+
+// streamWithRetries downloads the stream of the format into out, reopening it up to MaxRetries times on transient errors.
+// Forbidden URLs are refreshed apart from the retries, see RefreshURLsOn403.
+func (dl *Downloader) streamWithRetries(ctx context.Context, out *os.File, video *youtube.Video, format *youtube.Format) (int64, error) {
+	var written int64
+
+	refresher := dl.newURLRefresher(video, format)
+
+	for attempt := 1; ; attempt++ {
+		n, err := dl.streamToFile(ctx, out, video, format, written)
+		written += n
+
+		switch {
+		case err == nil || ctx.Err() != nil:
+			return written, err
+		case isForbidden(err):
+			if format, err = refresher.refresh(ctx, format, err); err != nil {
+				return written, err
+			}
+			attempt--
+		case attempt > dl.MaxRetries || !isTransient(err):
+			return written, err
+		default:
+			youtube.Logger.Warn("download failed, retrying", "id", video.ID, "itag", format.ItagNo, "attempt", attempt, "error", err)
+
+			if err = sleepContext(ctx, dl.getRetryBackoff()(attempt)); err != nil {
+				return written, err
+			}
+		}
+
+		if format.ContentLength == 0 {
+			// streams of unknown size can't be resumed
+			if err = rewind(out); err != nil {
+				return 0, err
+			}
+			written = 0
+		}
+	}
+}
+
+func (dl *Downloader) getRetryBackoff() func(attempt int) time.Duration {
+	if dl.RetryBackoff != nil {
+		return dl.RetryBackoff
+	}
+
+	return defaultRetryBackoff
+}
+
+func defaultRetryBackoff(attempt int) time.Duration {
+	if attempt > 5 {
+		return retryBackoffMax
+	}
+
+	return min(retryBackoffBase<<(attempt-1), retryBackoffMax)
+}
+
+// isTransient reports whether a download failing with err might succeed when retried
+func isTransient(err error) bool {
+	var statusErr youtube.ErrUnexpectedStatusCode
+	if errors.As(err, &statusErr) {
+		return statusErr >= 500
+	}
+
+	// errors of writing the file
+	var pathErr *fs.PathError
+
+	return !errors.As(err, &pathErr) &&
+		!errors.Is(err, context.Canceled) &&
+		!errors.Is(err, context.DeadlineExceeded) &&
+		!errors.Is(err, ErrIncompleteDownload) &&
+		!errors.Is(err, youtube.ErrRangeNotSupported)
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
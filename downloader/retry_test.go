@@ -0,0 +1,120 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func TestDownloader_videoDLWorker_retries(t *testing.T) {
+	const content = "0123456789"
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var start, end int
+		_, err := fmt.Sscanf(r.URL.Query().Get("range"), "%d-%d", &start, &end)
+		require.NoError(t, err)
+
+		// the second chunk fails once
+		if start == 4 && requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(content[start : end+1])) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	video := &youtube.Video{ID: "BaW_jenozKc"}
+	format := &youtube.Format{URL: server.URL, ContentLength: int64(len(content))}
+
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	out, err := os.Create(path)
+	require.NoError(t, err)
+	defer out.Close()
+
+	var backoffs []int
+	dl := Downloader{
+		ProgressOutput: io.Discard,
+		MaxRetries:     2,
+		RetryBackoff: func(attempt int) time.Duration {
+			backoffs = append(backoffs, attempt)
+			return 0
+		},
+	}
+	dl.ChunkSize = 4
+	dl.MaxRoutines = 1
+
+	written, err := dl.videoDLWorker(context.Background(), out, video, format)
+	require.NoError(t, err)
+	assert.EqualValues(t, len(content), written)
+	assert.Equal(t, []int{1}, backoffs)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data))
+}
+
+func TestDownloader_videoDLWorker_retriesStop(t *testing.T) {
+	var requests atomic.Int32
+	status := http.StatusNotFound
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	video := &youtube.Video{ID: "BaW_jenozKc"}
+	format := &youtube.Format{URL: server.URL}
+
+	out, err := os.Create(filepath.Join(t.TempDir(), "video.mp4"))
+	require.NoError(t, err)
+	defer out.Close()
+
+	t.Run("not transient", func(t *testing.T) {
+		requests.Store(0)
+		dl := Downloader{ProgressOutput: io.Discard, MaxRetries: 3}
+
+		_, err := dl.videoDLWorker(context.Background(), out, video, format)
+		assert.ErrorIs(t, err, youtube.ErrUnexpectedStatusCode(http.StatusNotFound))
+		assert.EqualValues(t, 1, requests.Load())
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		requests.Store(0)
+		status = http.StatusInternalServerError
+
+		ctx, cancel := context.WithCancel(context.Background())
+		dl := Downloader{
+			ProgressOutput: io.Discard,
+			MaxRetries:     3,
+			RetryBackoff: func(int) time.Duration {
+				cancel()
+				return time.Hour
+			},
+		}
+
+		_, err := dl.videoDLWorker(ctx, out, video, format)
+		assert.True(t, errors.Is(err, context.Canceled), err)
+		assert.EqualValues(t, 1, requests.Load())
+	})
+}
+
+func Test_defaultRetryBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, defaultRetryBackoff(1))
+	assert.Equal(t, 4*time.Second, defaultRetryBackoff(3))
+	assert.Equal(t, 30*time.Second, defaultRetryBackoff(6))
+	assert.Equal(t, 30*time.Second, defaultRetryBackoff(100))
+}
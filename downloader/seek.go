@@ -0,0 +1,122 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// OpenStream returns a seekable reader of the stream of the format and its size, e.g. for http.ServeContent.
+// Reads request ranges of ChunkSize bytes from the current offset, seeking closes the running request
+// and the next read starts a new one at the target offset.
+// Only formats with a ContentLength can be opened, the stream URL is deciphered for each range.
+func (dl *Downloader) OpenStream(ctx context.Context, v *youtube.Video, format *youtube.Format) (io.ReadSeekCloser, int64, error) {
+	if format.ContentLength <= 0 {
+		return nil, 0, fmt.Errorf("can't seek in the stream of format %d of unknown size", format.ItagNo)
+	}
+
+	return &streamReader{ctx: ctx, dl: dl, video: v, format: format}, format.ContentLength, nil
+}
+
+// streamReader reads a stream with ranged requests from its offset, see OpenStream
+type streamReader struct {
+	ctx    context.Context
+	dl     *Downloader
+	video  *youtube.Video
+	format *youtube.Format
+
+	offset int64         // of the next read
+	body   io.ReadCloser // of the running request, nil before the first read and after seeking
+	reader io.Reader     // the body, rate limited
+	end    int64         // last byte of the range of body
+	closed bool
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	if s.closed {
+		return 0, errors.New("read of closed stream")
+	}
+
+	if s.offset >= s.format.ContentLength {
+		return 0, io.EOF
+	}
+
+	if s.body == nil {
+		if err := s.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := s.reader.Read(p)
+	s.offset += int64(n)
+
+	if errors.Is(err, io.EOF) {
+		s.closeBody()
+		if s.offset <= s.end {
+			return n, fmt.Errorf("%w: the range ended at %d of %d", io.ErrUnexpectedEOF, s.offset, s.end+1)
+		}
+		// the next read requests the next range, or ends the stream
+		err = nil
+	}
+
+	return n, err
+}
+
+// open requests the range of ChunkSize bytes from the offset
+func (s *streamReader) open() error {
+	chunkSize := s.dl.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = youtube.Size10Mb
+	}
+	end := min(s.offset+chunkSize, s.format.ContentLength) - 1
+
+	body, err := s.dl.GetStreamRangeContext(s.ctx, s.video, s.format, s.offset, end)
+	if err != nil {
+		return err
+	}
+
+	s.body, s.end = body, end
+	s.reader = limitRate(s.ctx, body, s.dl.getRateLimiter())
+
+	return nil
+}
+
+func (s *streamReader) closeBody() {
+	if s.body != nil {
+		s.body.Close()
+		s.body, s.reader = nil, nil
+	}
+}
+
+func (s *streamReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.offset
+	case io.SeekEnd:
+		offset += s.format.ContentLength
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+
+	if offset < 0 {
+		return 0, fmt.Errorf("seek to negative offset %d", offset)
+	}
+
+	if offset != s.offset {
+		s.closeBody()
+		s.offset = offset
+	}
+
+	return offset, nil
+}
+
+func (s *streamReader) Close() error {
+	s.closeBody()
+	s.closed = true
+
+	return nil
+}
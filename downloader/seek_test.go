@@ -0,0 +1,99 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func TestDownloader_OpenStream(t *testing.T) {
+	const content = "0123456789"
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var start, end int
+		_, err := fmt.Sscanf(r.URL.Query().Get("range"), "%d-%d", &start, &end)
+		require.NoError(t, err)
+		w.Write([]byte(content[start : end+1])) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	video := &youtube.Video{ID: "BaW_jenozKc"}
+	format := &youtube.Format{URL: server.URL, ContentLength: int64(len(content))}
+
+	dl := Downloader{}
+	dl.ChunkSize = 4
+
+	stream, size, err := dl.OpenStream(context.Background(), video, format)
+	require.NoError(t, err)
+	defer stream.Close()
+	assert.EqualValues(t, len(content), size)
+
+	// seeking alone doesn't request anything
+	offset, err := stream.Seek(-3, io.SeekEnd)
+	require.NoError(t, err)
+	assert.EqualValues(t, 7, offset)
+	_, err = stream.Seek(2, io.SeekStart)
+	require.NoError(t, err)
+	assert.Zero(t, requests.Load())
+
+	data, err := io.ReadAll(stream)
+	require.NoError(t, err)
+	assert.Equal(t, content[2:], string(data))
+	assert.EqualValues(t, 2, requests.Load(), "ranges of the chunk size")
+
+	offset, err = stream.Seek(-4, io.SeekCurrent)
+	require.NoError(t, err)
+	assert.EqualValues(t, 6, offset)
+
+	buf := make([]byte, 2)
+	_, err = io.ReadFull(stream, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "67", string(buf))
+
+	_, err = stream.Seek(-1, io.SeekStart)
+	assert.EqualError(t, err, "seek to negative offset -1")
+}
+
+func TestDownloader_OpenStream_serveContent(t *testing.T) {
+	const content = "0123456789"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var start, end int
+		_, err := fmt.Sscanf(r.URL.Query().Get("range"), "%d-%d", &start, &end)
+		require.NoError(t, err)
+		w.Write([]byte(content[start : end+1])) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	dl := Downloader{}
+	stream, _, err := dl.OpenStream(context.Background(), &youtube.Video{}, &youtube.Format{URL: server.URL, ContentLength: int64(len(content))})
+	require.NoError(t, err)
+	defer stream.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/video.mp4", nil)
+	req.Header.Set("Range", "bytes=3-5")
+	recorder := httptest.NewRecorder()
+	http.ServeContent(recorder, req, "video.mp4", time.Time{}, stream)
+
+	assert.Equal(t, http.StatusPartialContent, recorder.Code)
+	assert.Equal(t, "345", recorder.Body.String())
+}
+
+func TestDownloader_OpenStream_unknownSize(t *testing.T) {
+	dl := Downloader{}
+
+	_, _, err := dl.OpenStream(context.Background(), &youtube.Video{}, &youtube.Format{ItagNo: 18})
+	assert.EqualError(t, err, "can't seek in the stream of format 18 of unknown size")
+}
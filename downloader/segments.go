@@ -0,0 +1,166 @@
+package downloader
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// Segment is a time range of a video, from Start to End
+type Segment struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+func (s Segment) String() string {
+	return s.Start.String() + "-" + s.End.String()
+}
+
+// SkipSegments cuts the segments, e.g. sponsored ones or intros, out of the file and joins the remaining parts.
+// Overlapping segments and segments out of order are normalized first, segments beyond the duration of the video are rejected.
+// The streams are copied, so each remaining part begins at the keyframe before its start.
+// The chapters of the file are dropped, as their times no longer match.
+type SkipSegments struct {
+	Segments []Segment
+}
+
+// PostProcess implements the PostProcessor interface
+func (s SkipSegments) PostProcess(ctx context.Context, dl *Downloader, v *youtube.Video, path string) (string, error) {
+	segments, err := normalizeSegments(s.Segments, v.Duration)
+	if err != nil || len(segments) == 0 {
+		return path, err
+	}
+
+	parts := keptParts(segments, v.Duration)
+	if len(parts) == 0 {
+		return path, errors.New("the segments to skip cover the whole video")
+	}
+
+	dir, err := dl.getTempDir(filepath.Dir(path))
+	if err != nil {
+		return path, err
+	}
+
+	listFile, err := writeConcatList(dir, path, parts)
+	if err != nil {
+		return path, err
+	}
+	defer dl.removeIntermediate(listFile)
+
+	youtube.Logger.Info("skipping segments", "path", path, "segments", segments)
+
+	return path, dl.replaceWithFFmpeg(ctx, path, func(output string) []string {
+		return []string{
+			"-y",
+			"-loglevel", "warning",
+			"-f", "concat",
+			"-safe", "0",
+			"-i", listFile,
+			"-map", "0",
+			"-map_chapters", "-1",
+			"-c", "copy",
+			output,
+		}
+	})
+}
+
+// normalizeSegments checks the segments are parts of a video of the duration,
+// and returns them sorted by their start with overlapping and adjacent ones merged.
+// Videos of unknown duration are not limited.
+func normalizeSegments(segments []Segment, duration time.Duration) ([]Segment, error) {
+	for _, s := range segments {
+		switch {
+		case s.Start < 0:
+			return nil, fmt.Errorf("segment start %s is negative", s.Start)
+		case s.End <= s.Start:
+			return nil, fmt.Errorf("segment end %s is not after its start %s", s.End, s.Start)
+		case duration > 0 && s.End > duration:
+			return nil, fmt.Errorf("segment %s is beyond the duration %s of the video", s, duration)
+		}
+	}
+
+	sorted := slices.Clone(segments)
+	slices.SortFunc(sorted, func(a, b Segment) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+
+	var result []Segment
+	for _, s := range sorted {
+		if last := len(result) - 1; last >= 0 && s.Start <= result[last].End {
+			result[last].End = max(result[last].End, s.End)
+			continue
+		}
+		result = append(result, s)
+	}
+
+	return result, nil
+}
+
+// keptParts returns the parts of a video of the duration between the normalized segments.
+// The End of the last part is 0 if it lasts until the end of a video of unknown duration.
+func keptParts(segments []Segment, duration time.Duration) []Segment {
+	var parts []Segment
+
+	var start time.Duration
+	for _, s := range segments {
+		if s.Start > start {
+			parts = append(parts, Segment{Start: start, End: s.Start})
+		}
+		start = s.End
+	}
+
+	if duration == 0 || start < duration {
+		parts = append(parts, Segment{Start: start, End: duration})
+	}
+
+	return parts
+}
+
+// writeConcatList writes a list for the concat demuxer of ffmpeg with the parts of the file into dir and returns its path
+func writeConcatList(dir, path string, parts []Segment) (string, error) {
+	// the paths in the list are relative to it
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.CreateTemp(dir, "youtube_*.concat.txt")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err = file.WriteString(concatList(path, parts)); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+
+	return file.Name(), file.Close()
+}
+
+// concatList formats the parts of the file in the ffconcat format
+func concatList(path string, parts []Segment) string {
+	var b strings.Builder
+
+	b.WriteString("ffconcat version 1.0\n")
+
+	for _, part := range parts {
+		b.WriteString("file '" + strings.ReplaceAll(path, "'", `'\''`) + "'\n")
+		if part.Start > 0 {
+			b.WriteString("inpoint " + ffmpegDuration(part.Start) + "\n")
+		}
+		if part.End > 0 {
+			b.WriteString("outpoint " + ffmpegDuration(part.End) + "\n")
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,110 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func Test_normalizeSegments(t *testing.T) {
+	s := time.Second
+
+	tests := []struct {
+		name     string
+		segments []Segment
+		want     []Segment
+		wantErr  string
+	}{
+		{name: "none"},
+		{name: "sorted", segments: []Segment{{30 * s, 45 * s}, {120 * s, 135 * s}}, want: []Segment{{30 * s, 45 * s}, {120 * s, 135 * s}}},
+		{name: "out of order", segments: []Segment{{120 * s, 135 * s}, {30 * s, 45 * s}}, want: []Segment{{30 * s, 45 * s}, {120 * s, 135 * s}}},
+		{name: "overlapping", segments: []Segment{{30 * s, 45 * s}, {40 * s, 50 * s}, {35 * s, 36 * s}}, want: []Segment{{30 * s, 50 * s}}},
+		{name: "adjacent", segments: []Segment{{45 * s, 60 * s}, {30 * s, 45 * s}}, want: []Segment{{30 * s, 60 * s}}},
+		{name: "negative", segments: []Segment{{-s, s}}, wantErr: "segment start -1s is negative"},
+		{name: "empty", segments: []Segment{{10 * s, 10 * s}}, wantErr: "segment end 10s is not after its start 10s"},
+		{name: "too long", segments: []Segment{{0, 30 * s}, {170 * s, 190 * s}}, wantErr: "segment 2m50s-3m10s is beyond the duration 3m0s of the video"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments, err := normalizeSegments(tt.segments, 3*time.Minute)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, segments)
+		})
+	}
+
+	// videos of unknown duration are not limited
+	_, err := normalizeSegments([]Segment{{0, time.Hour}}, 0)
+	assert.NoError(t, err)
+}
+
+func Test_keptParts(t *testing.T) {
+	s := time.Second
+
+	assert.Equal(t, []Segment{{0, 30 * s}, {45 * s, 120 * s}, {135 * s, 180 * s}},
+		keptParts([]Segment{{30 * s, 45 * s}, {120 * s, 135 * s}}, 3*time.Minute))
+	assert.Equal(t, []Segment{{45 * s, 170 * s}},
+		keptParts([]Segment{{0, 45 * s}, {170 * s, 180 * s}}, 3*time.Minute), "at the start and end")
+	assert.Equal(t, []Segment{{0, 30 * s}, {45 * s, 0}},
+		keptParts([]Segment{{30 * s, 45 * s}}, 0), "unknown duration")
+	assert.Empty(t, keptParts([]Segment{{0, 180 * s}}, 3*time.Minute))
+}
+
+func Test_concatList(t *testing.T) {
+	list := concatList("/videos/Rock'n'Roll.mp4", []Segment{{0, 30 * time.Second}, {45500 * time.Millisecond, 0}})
+	assert.Equal(t, `ffconcat version 1.0
+file '/videos/Rock'\''n'\''Roll.mp4'
+outpoint 30.000
+file '/videos/Rock'\''n'\''Roll.mp4'
+inpoint 45.500
+`, list)
+}
+
+func TestSkipSegments(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+
+	// fake ffmpeg keeping the concat list and writing the output
+	log := filepath.Join(t.TempDir(), "concat.txt")
+	fakeFFmpeg(t, "while IFS= read -r line; do printf '%s\\n' \"$line\"; done < \"$9\" > "+log+"\neval out=\\${$#}\necho cut > \"$out\"\n")
+
+	path := filepath.Join(t.TempDir(), "Talk.mp4")
+	require.NoError(t, os.WriteFile(path, []byte("video"), 0o644))
+
+	video := &youtube.Video{ID: "BaW_jenozKc", Duration: 3 * time.Minute}
+	dl := &Downloader{}
+
+	processor := SkipSegments{Segments: []Segment{{120 * time.Second, 135 * time.Second}, {30 * time.Second, 45 * time.Second}}}
+	result, err := processor.PostProcess(context.Background(), dl, video, path)
+	require.NoError(t, err)
+	assert.Equal(t, path, result)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "cut\n", string(data))
+
+	list, err := os.ReadFile(log)
+	require.NoError(t, err)
+	assert.Equal(t, concatList(path, []Segment{{0, 30 * time.Second}, {45 * time.Second, 120 * time.Second}, {135 * time.Second, 3 * time.Minute}}), string(list))
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "the concat list is removed")
+
+	processor = SkipSegments{Segments: []Segment{{0, 3 * time.Minute}}}
+	_, err = processor.PostProcess(context.Background(), dl, video, path)
+	assert.EqualError(t, err, "the segments to skip cover the whole video")
+}
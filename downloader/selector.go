@@ -0,0 +1,393 @@
+package downloader
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// The selectors of format selection expressions, see ParseFormatSelector
+const (
+	SelectorBest       = "best"       // the best format with video and audio
+	SelectorWorst      = "worst"      // the worst format with video and audio
+	SelectorBestVideo  = "bestvideo"  // the best video only format
+	SelectorWorstVideo = "worstvideo" // the worst video only format
+	SelectorBestAudio  = "bestaudio"  // the best audio only format
+	SelectorWorstAudio = "worstaudio" // the worst audio only format
+)
+
+var selectors = []string{SelectorBest, SelectorWorst, SelectorBestVideo, SelectorWorstVideo, SelectorBestAudio, SelectorWorstAudio}
+
+// the fields of filters and the operators they take
+var (
+	numericFields    = []string{"height", "fps"}
+	stringFields     = []string{"vcodec", "acodec", "ext"}
+	numericOperators = []string{"<=", ">=", "!=", "=", "<", ">"}
+	stringOperators  = []string{"!=", "^=", "$=", "*=", "="}
+)
+
+// FormatSelector is a parsed format selection expression, see ParseFormatSelector
+type FormatSelector struct {
+	expression string
+	format     formatSelection
+	audio      *formatSelection // the audio format merged with the format, nil without "+"
+}
+
+// formatSelection selects a format by a selector or itag and filters
+type formatSelection struct {
+	text     string // the part of the expression
+	selector string // empty for an itag
+	itag     int
+	filters  []formatFilter
+}
+
+// formatFilter is a filter like "[height<=1080]"
+type formatFilter struct {
+	field    string
+	operator string
+	value    string
+	number   int // the value of numeric fields
+}
+
+// ParseFormatSelector parses a format selection expression like "bestvideo[height<=1080][vcodec^=avc1]+bestaudio[ext=m4a]".
+// A selection is one of the selectors best, worst, bestvideo, worstvideo, bestaudio, worstaudio or an itag,
+// followed by filters in brackets:
+//
+//   - height and fps compared with =, !=, <, <=, > or >=
+//   - vcodec and acodec, the codec identifiers like "avc1.640028" or their names like "h264",
+//     and ext, the file extension like "m4a", compared with =, != or ^=, $=, *= for a prefix, suffix or substring
+//
+// Two selections joined by "+" select a video and an audio format to merge, see DownloadCompositeFormats.
+// Malformed expressions return an *ErrFormatSelector pointing at the offending token.
+func ParseFormatSelector(expression string) (*FormatSelector, error) {
+	p := &selectorParser{expression: expression}
+
+	format, err := p.selection()
+	if err != nil {
+		return nil, err
+	}
+	selector := &FormatSelector{expression: expression, format: format}
+
+	if p.peek() == '+' {
+		if format.selector != "" && format.selector != SelectorBestVideo && format.selector != SelectorWorstVideo {
+			return nil, p.errorAt(0, format.selector, "a merge starts with bestvideo, worstvideo or an itag")
+		}
+		p.pos++
+
+		audio, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		if audio.selector != "" && audio.selector != SelectorBestAudio && audio.selector != SelectorWorstAudio {
+			return nil, p.errorAt(p.pos-len(audio.text), audio.selector, `the format after "+" is bestaudio, worstaudio or an itag`)
+		}
+		selector.audio = &audio
+	}
+
+	if p.pos < len(expression) {
+		return nil, p.errorAt(p.pos, expression[p.pos:], `expected "[", "+" or the end`)
+	}
+
+	return selector, nil
+}
+
+// String returns the expression of the selector
+func (s *FormatSelector) String() string {
+	return s.expression
+}
+
+// IsMerge reports whether the selector selects a video and an audio format to merge
+func (s *FormatSelector) IsMerge() bool {
+	return s.audio != nil
+}
+
+// SelectFormats returns the format of the selector among the formats, and the audio format to merge it with,
+// nil unless the selector IsMerge. The best and worst formats are the first and last ones of SortFormats,
+// bestaudio and worstaudio select among the formats of the track in AudioLanguage, see FilterAudioLanguage.
+// No matching format is a DownloadError of KindNoFormat.
+func (dl *Downloader) SelectFormats(formats youtube.FormatList, selector *FormatSelector) (format, audio *youtube.Format, err error) {
+	format, err = dl.selectFormat(formats, &selector.format)
+	if err != nil || selector.audio == nil {
+		return format, nil, err
+	}
+
+	if !strings.HasPrefix(format.MimeType, "video/") {
+		return nil, nil, downloadError(KindNoFormat, fmt.Errorf("format %d of %s has no video to merge", format.ItagNo, selector.format.text))
+	}
+
+	audio, err = dl.selectFormat(formats, selector.audio)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !strings.HasPrefix(audio.MimeType, "audio/") {
+		return nil, nil, downloadError(KindNoFormat, fmt.Errorf("format %d of %s is not an audio only format", audio.ItagNo, selector.audio.text))
+	}
+
+	return format, audio, nil
+}
+
+// selectFormat returns the format of the selection among the formats
+func (dl *Downloader) selectFormat(formats youtube.FormatList, selection *formatSelection) (*youtube.Format, error) {
+	var candidates youtube.FormatList
+	for _, format := range formats {
+		if selection.kindMatches(&format) && selection.filtersMatch(&format) {
+			candidates = append(candidates, format)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, downloadError(KindNoFormat, fmt.Errorf("no format matches %s", selection.text))
+	}
+
+	if selection.selector == SelectorBestAudio || selection.selector == SelectorWorstAudio {
+		var err error
+		if candidates, err = dl.FilterAudioLanguage(candidates); err != nil {
+			return nil, downloadError(KindNoFormat, err)
+		}
+	}
+
+	dl.SortFormats(candidates)
+	if strings.HasPrefix(selection.selector, "worst") {
+		return &candidates[len(candidates)-1], nil
+	}
+
+	return &candidates[0], nil
+}
+
+func (s *formatSelection) kindMatches(format *youtube.Format) bool {
+	video := strings.HasPrefix(format.MimeType, "video/")
+
+	switch s.selector {
+	case "":
+		return format.ItagNo == s.itag
+	case SelectorBest, SelectorWorst:
+		return video && format.AudioChannels > 0
+	case SelectorBestVideo, SelectorWorstVideo:
+		return video && format.AudioChannels == 0
+	default:
+		return strings.HasPrefix(format.MimeType, "audio/")
+	}
+}
+
+func (s *formatSelection) filtersMatch(format *youtube.Format) bool {
+	for _, filter := range s.filters {
+		if !filter.matches(format) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (f *formatFilter) matches(format *youtube.Format) bool {
+	switch f.field {
+	case "height":
+		return compareNumber(f.operator, format.Height, f.number)
+	case "fps":
+		return compareNumber(f.operator, format.FPS, f.number)
+	}
+
+	mediaType, video, audio := formatCodecIDs(format)
+	if f.field == "ext" {
+		// opus audio is named .opus but comes in webm
+		_, container, _ := strings.Cut(mediaType, "/")
+		return compareString(f.operator, []string{strings.TrimPrefix(pickIdealFileExtension(format.MimeType), "."), container}, f.value)
+	}
+
+	codec := video
+	if f.field == "acodec" {
+		codec = audio
+	}
+
+	return compareString(f.operator, []string{codec, codecName(codec)}, f.value)
+}
+
+func compareNumber(operator string, actual, value int) bool {
+	switch operator {
+	case "<=":
+		return actual <= value
+	case ">=":
+		return actual >= value
+	case "<":
+		return actual < value
+	case ">":
+		return actual > value
+	case "!=":
+		return actual != value
+	default:
+		return actual == value
+	}
+}
+
+// compareString reports whether any of the candidates compares to the value, or none is equal for "!="
+func compareString(operator string, candidates []string, value string) bool {
+	if operator == "!=" {
+		return !compareString("=", candidates, value)
+	}
+
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+
+		var matches bool
+		switch operator {
+		case "^=":
+			matches = strings.HasPrefix(candidate, value)
+		case "$=":
+			matches = strings.HasSuffix(candidate, value)
+		case "*=":
+			matches = strings.Contains(candidate, value)
+		default:
+			matches = candidate == value
+		}
+		if matches {
+			return true
+		}
+	}
+
+	return false
+}
+
+// selectorParser parses format selection expressions
+type selectorParser struct {
+	expression string
+	pos        int
+}
+
+func (p *selectorParser) peek() byte {
+	if p.pos >= len(p.expression) {
+		return 0
+	}
+
+	return p.expression[p.pos]
+}
+
+// selection parses a selector or itag with its filters
+func (p *selectorParser) selection() (formatSelection, error) {
+	start := p.pos
+	for p.pos < len(p.expression) && isSelectorChar(p.expression[p.pos]) {
+		p.pos++
+	}
+	name := p.expression[start:p.pos]
+
+	selection := formatSelection{selector: name}
+	switch {
+	case name == "":
+		return selection, p.errorAt(start, p.rest(start), "expected a selector or itag")
+	case isDigits(name):
+		selection.selector = ""
+		selection.itag, _ = strconv.Atoi(name)
+	case !slices.Contains(selectors, name):
+		return selection, p.errorAt(start, name, "unknown selector, use "+strings.Join(selectors, ", ")+" or an itag")
+	}
+
+	for p.peek() == '[' {
+		filter, err := p.filter()
+		if err != nil {
+			return selection, err
+		}
+		selection.filters = append(selection.filters, filter)
+	}
+
+	selection.text = p.expression[start:p.pos]
+
+	return selection, nil
+}
+
+// filter parses a filter in brackets
+func (p *selectorParser) filter() (formatFilter, error) {
+	open := p.pos
+	p.pos++
+
+	end := strings.IndexByte(p.expression[p.pos:], ']')
+	if end < 0 {
+		return formatFilter{}, p.errorAt(open, p.expression[open:], `missing "]"`)
+	}
+	end += p.pos
+
+	fieldStart := p.pos
+	for p.pos < end && p.expression[p.pos] >= 'a' && p.expression[p.pos] <= 'z' {
+		p.pos++
+	}
+	filter := formatFilter{field: p.expression[fieldStart:p.pos]}
+
+	operators := stringOperators
+	switch {
+	case slices.Contains(numericFields, filter.field):
+		operators = numericOperators
+	case !slices.Contains(stringFields, filter.field):
+		token := filter.field
+		if token == "" {
+			token = p.rest(fieldStart)
+		}
+		return filter, p.errorAt(fieldStart, token, "unknown field, use "+joinOr(append(slices.Clone(numericFields), stringFields...)))
+	}
+
+	for _, operator := range operators {
+		if strings.HasPrefix(p.expression[p.pos:end], operator) {
+			filter.operator = operator
+			break
+		}
+	}
+	if filter.operator == "" {
+		return filter, p.errorAt(p.pos, p.rest(p.pos), "expected one of the operators "+strings.Join(operators, " ")+" of "+filter.field)
+	}
+	p.pos += len(filter.operator)
+
+	filter.value = p.expression[p.pos:end]
+	switch {
+	case filter.value == "":
+		return filter, p.errorAt(p.pos, "]", "expected a value")
+	case slices.Contains(numericFields, filter.field):
+		number, err := strconv.Atoi(filter.value)
+		if err != nil || number < 0 {
+			return filter, p.errorAt(p.pos, filter.value, filter.field+" is compared with a number")
+		}
+		filter.number = number
+	}
+
+	p.pos = end + 1
+
+	return filter, nil
+}
+
+// rest returns the character at pos, or "" at the end
+func (p *selectorParser) rest(pos int) string {
+	if pos >= len(p.expression) {
+		return ""
+	}
+
+	return p.expression[pos : pos+1]
+}
+
+func (p *selectorParser) errorAt(pos int, token, reason string) error {
+	return &ErrFormatSelector{Expression: p.expression, Offset: pos, Token: token, Reason: reason}
+}
+
+func isSelectorChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= '0' && c <= '9'
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// joinOr joins the words like "a, b or c"
+func joinOr(words []string) string {
+	if len(words) < 2 {
+		return strings.Join(words, "")
+	}
+
+	return strings.Join(words[:len(words)-1], ", ") + " or " + words[len(words)-1]
+}
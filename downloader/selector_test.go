@@ -0,0 +1,125 @@
+package downloader
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+var selectorTestFormats = youtube.FormatList{
+	{ItagNo: 18, MimeType: `video/mp4; codecs="avc1.42001E, mp4a.40.2"`, Width: 640, Height: 360, FPS: 30, AudioChannels: 2},
+	{ItagNo: 137, MimeType: `video/mp4; codecs="avc1.640028"`, Width: 1920, Height: 1080, FPS: 30},
+	{ItagNo: 248, MimeType: `video/webm; codecs="vp9"`, Width: 1920, Height: 1080, FPS: 30},
+	{ItagNo: 313, MimeType: `video/webm; codecs="vp9"`, Width: 3840, Height: 2160, FPS: 30},
+	{ItagNo: 136, MimeType: `video/mp4; codecs="avc1.4d401f"`, Width: 1280, Height: 720, FPS: 30},
+	{ItagNo: 140, MimeType: `audio/mp4; codecs="mp4a.40.2"`, Bitrate: 130000, AudioChannels: 2},
+	{ItagNo: 251, MimeType: `audio/webm; codecs="opus"`, Bitrate: 160000, AudioChannels: 2},
+}
+
+func TestDownloader_SelectFormats(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       int
+		wantAudio  int
+	}{
+		{expression: "best", want: 18},
+		{expression: "worst", want: 18},
+		{expression: "bestvideo", want: 313},
+		{expression: "worstvideo", want: 136},
+		{expression: "bestvideo[height<=1080]", want: 137},
+		{expression: "bestvideo[height<=1080][vcodec^=vp]", want: 248},
+		{expression: "bestvideo[vcodec=h264][height<1080]", want: 136},
+		{expression: "bestvideo[vcodec!=vp9][fps>=30]", want: 137},
+		{expression: "bestaudio[ext=m4a]", want: 140},
+		{expression: "bestaudio[acodec=opus][ext=webm]", want: 251},
+		{expression: "137", want: 137},
+		{expression: "bestvideo[height<=1080][vcodec^=avc1]+bestaudio[ext=m4a]", want: 137, wantAudio: 140},
+		{expression: "313+251", want: 313, wantAudio: 251},
+	}
+
+	dl := Downloader{}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			selector, err := ParseFormatSelector(tt.expression)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAudio != 0, selector.IsMerge())
+
+			format, audio, err := dl.SelectFormats(selectorTestFormats, selector)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, format.ItagNo)
+			if tt.wantAudio == 0 {
+				assert.Nil(t, audio)
+			} else {
+				assert.Equal(t, tt.wantAudio, audio.ItagNo)
+			}
+		})
+	}
+}
+
+func TestDownloader_SelectFormats_noMatch(t *testing.T) {
+	dl := Downloader{}
+
+	selector, err := ParseFormatSelector("bestvideo[height>2160]+bestaudio")
+	require.NoError(t, err)
+	_, _, err = dl.SelectFormats(selectorTestFormats, selector)
+	assert.EqualError(t, err, "no format matches bestvideo[height>2160]")
+	var downloadErr *DownloadError
+	require.ErrorAs(t, err, &downloadErr)
+	assert.Equal(t, KindNoFormat, downloadErr.Kind)
+
+	selector, err = ParseFormatSelector("140+251")
+	require.NoError(t, err)
+	_, _, err = dl.SelectFormats(selectorTestFormats, selector)
+	assert.EqualError(t, err, "format 140 of 140 has no video to merge")
+}
+
+func TestParseFormatSelector_errors(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"", `invalid format selector "": the end at offset 0: expected a selector or itag`},
+		{"bestest", `invalid format selector "bestest": "bestest" at offset 0: unknown selector, use best, worst, bestvideo, worstvideo, bestaudio, worstaudio or an itag`},
+		{"bestvideo[heigth<=1080]", `invalid format selector "bestvideo[heigth<=1080]": "heigth" at offset 10: unknown field, use height, fps, vcodec, acodec or ext`},
+		{"bestvideo[height~1080]", `invalid format selector "bestvideo[height~1080]": "~" at offset 16: expected one of the operators <= >= != = < > of height`},
+		{"bestvideo[height<=hd]", `invalid format selector "bestvideo[height<=hd]": "hd" at offset 18: height is compared with a number`},
+		{"bestvideo[vcodec<avc1]", `invalid format selector "bestvideo[vcodec<avc1]": "<" at offset 16: expected one of the operators != ^= $= *= = of vcodec`},
+		{"bestvideo[ext=]", `invalid format selector "bestvideo[ext=]": "]" at offset 14: expected a value`},
+		{"bestvideo[height<=1080", `invalid format selector "bestvideo[height<=1080": "[height<=1080" at offset 9: missing "]"`},
+		{"bestvideo+", `invalid format selector "bestvideo+": the end at offset 10: expected a selector or itag`},
+		{"bestaudio+bestvideo", `invalid format selector "bestaudio+bestvideo": "bestaudio" at offset 0: a merge starts with bestvideo, worstvideo or an itag`},
+		{"bestvideo+bestvideo[height<=720]", `invalid format selector "bestvideo+bestvideo[height<=720]": "bestvideo" at offset 10: the format after "+" is bestaudio, worstaudio or an itag`},
+		{"best/worst", `invalid format selector "best/worst": "/worst" at offset 4: expected "[", "+" or the end`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := ParseFormatSelector(tt.expression)
+			var selectorErr *ErrFormatSelector
+			require.ErrorAs(t, err, &selectorErr)
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+}
+
+func TestDownloader_SelectFormats_audioLanguage(t *testing.T) {
+	video := loadMultiAudioVideo(t)
+
+	selector, err := ParseFormatSelector("bestvideo+bestaudio[ext=m4a]")
+	require.NoError(t, err)
+
+	dl := Downloader{AudioLanguage: "de"}
+	_, audio, err := dl.SelectFormats(video.Formats, selector)
+	require.NoError(t, err)
+	assert.Equal(t, "de", audio.AudioTrack.Language())
+
+	dl = Downloader{AudioLanguage: "fr", StrictAudioLang: true}
+	_, _, err = dl.SelectFormats(video.Formats, selector)
+	assert.EqualError(t, err, `audio language "fr" is not available, the default is "en", available are de, en, es-419`)
+	var downloadErr *DownloadError
+	require.ErrorAs(t, err, &downloadErr)
+	assert.Equal(t, KindNoFormat, downloadErr.Kind)
+}
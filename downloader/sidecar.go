@@ -0,0 +1,56 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// WriteDescription writes the description of the video next to the file,
+// replacing its extension with ".description". Videos without description are skipped.
+type WriteDescription struct{}
+
+// PostProcess implements the PostProcessor interface
+func (WriteDescription) PostProcess(_ context.Context, _ *Downloader, v *youtube.Video, path string) (string, error) {
+	if strings.TrimSpace(v.Description) == "" {
+		youtube.Logger.Info("the video has no description, not writing it", "id", v.ID)
+		return path, nil
+	}
+
+	descriptionFile := sidecarFile(path, ".description")
+	youtube.Logger.Debug("writing description", "path", descriptionFile)
+
+	return path, os.WriteFile(descriptionFile, []byte(v.Description), 0o644)
+}
+
+// WriteThumbnail downloads the largest thumbnail of the video next to the file, preferably in ThumbnailFormat.
+// Unlike DownloadThumbnail it is never converted, the extension of the file follows the type of the image,
+// e.g. "Title.webp" for "Title.mp4". Videos without thumbnail are skipped.
+type WriteThumbnail struct{}
+
+// PostProcess implements the PostProcessor interface
+func (WriteThumbnail) PostProcess(ctx context.Context, dl *Downloader, v *youtube.Video, path string) (string, error) {
+	data, _, err := dl.fetchThumbnail(ctx, v, dl.thumbnailFormat())
+	if errors.Is(err, ErrNoThumbnail) {
+		youtube.Logger.Info("the video has no thumbnail, not writing it", "id", v.ID)
+		return path, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	thumbnailFile := sidecarFile(path, pickIdealFileExtension(http.DetectContentType(data)))
+	youtube.Logger.Debug("writing thumbnail", "path", thumbnailFile)
+
+	return path, os.WriteFile(thumbnailFile, data, 0o644)
+}
+
+// sidecarFile returns the path of a file next to path, with ext instead of its extension
+func sidecarFile(path, ext string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ext
+}
@@ -0,0 +1,71 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func TestWriteDescription(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Title.mp4")
+
+	video := &youtube.Video{ID: "BaW_jenozKc", Description: "first line\nsecond line"}
+	result, err := WriteDescription{}.PostProcess(context.Background(), &Downloader{}, video, path)
+	require.NoError(t, err)
+	assert.Equal(t, path, result)
+
+	data, err := os.ReadFile(filepath.Join(dir, "Title.description"))
+	require.NoError(t, err)
+	assert.Equal(t, "first line\nsecond line", string(data))
+
+	// nothing is written without description
+	path = filepath.Join(dir, "Empty.mp4")
+	_, err = WriteDescription{}.PostProcess(context.Background(), &Downloader{}, &youtube.Video{ID: "empty"}, path)
+	require.NoError(t, err)
+	assert.NoFileExists(t, filepath.Join(dir, "Empty.description"))
+}
+
+func TestWriteThumbnail(t *testing.T) {
+	webp := []byte("RIFF\x00\x00\x00\x00WEBPVP8 thumbnail")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/vi_webp/BaW_jenozKc/sddefault.webp" {
+			w.Write(webp) //nolint:errcheck
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+	setThumbnailBaseURL(t, server.URL)
+
+	dir := t.TempDir()
+	dl := &Downloader{}
+
+	// the jpg thumbnails are missing, the webp one is kept as it is
+	path := filepath.Join(dir, "Title.mp4")
+	_, err := WriteThumbnail{}.PostProcess(context.Background(), dl, &youtube.Video{ID: "BaW_jenozKc"}, path)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(dir, "Title.webp"))
+	require.NoError(t, err)
+	assert.Equal(t, webp, data)
+	assert.NoFileExists(t, filepath.Join(dir, "Title.jpg"))
+
+	// videos without thumbnail are skipped
+	path = filepath.Join(dir, "Missing.mp4")
+	_, err = WriteThumbnail{}.PostProcess(context.Background(), dl, &youtube.Video{ID: "missing"}, path)
+	require.NoError(t, err)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
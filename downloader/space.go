@@ -0,0 +1,57 @@
+package downloader
+
+import (
+	"errors"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// errFreeSpaceUnknown is returned by freeSpace on platforms it isn't implemented for
+var errFreeSpaceUnknown = errors.New("free space is unknown on this platform")
+
+// compositeSpaceFactor is the space of a composite download relative to the size of its streams:
+// the downloaded streams plus the merged output of about their size
+const compositeSpaceFactor = 2
+
+// checkSpace returns ErrInsufficientSpace if dir has less than size bytes free.
+// It passes if the size or the free space is unknown, with SkipSpaceCheck and in TestMode.
+func (dl *Downloader) checkSpace(dir string, size int64) error {
+	if dl.SkipSpaceCheck || dl.TestMode || size <= 0 {
+		return nil
+	}
+
+	free, err := freeSpace(dir)
+	if err != nil {
+		youtube.Logger.Debug("can't check the free space", "dir", dir, "error", err)
+		return nil
+	}
+
+	if uint64(size) > free {
+		return &ErrInsufficientSpace{
+			Dir:       dir,
+			Needed:    size,
+			Available: int64(free),
+		}
+	}
+
+	return nil
+}
+
+// checkCompositeSpace checks the space of a composite download of the formats
+// with its temporary files in tempDir and the merged output in destDir
+func (dl *Downloader) checkCompositeSpace(tempDir, destDir string, videoFormat, audioFormat *youtube.Format) error {
+	if videoFormat.ContentLength == 0 || audioFormat.ContentLength == 0 {
+		return nil
+	}
+
+	size := videoFormat.ContentLength + audioFormat.ContentLength
+	if tempDir == destDir {
+		return dl.checkSpace(destDir, compositeSpaceFactor*size)
+	}
+
+	if err := dl.checkSpace(tempDir, size); err != nil {
+		return err
+	}
+
+	return dl.checkSpace(destDir, size)
+}
@@ -0,0 +1,49 @@
+package downloader
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func TestDownloader_checkSpace(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := freeSpace(dir); err != nil {
+		t.Skip(err)
+	}
+
+	dl := Downloader{}
+	assert.NoError(t, dl.checkSpace(dir, youtube.Size1Kb))
+	assert.NoError(t, dl.checkSpace(dir, 0), "unknown size")
+
+	err := dl.checkSpace(dir, 1<<62)
+	var spaceErr *ErrInsufficientSpace
+	require.ErrorAs(t, err, &spaceErr)
+	assert.Equal(t, dir, spaceErr.Dir)
+	assert.EqualValues(t, 1<<62, spaceErr.Needed)
+	assert.ErrorContains(t, err, "not enough space in "+dir)
+
+	dl.SkipSpaceCheck = true
+	assert.NoError(t, dl.checkSpace(dir, 1<<62))
+}
+
+func TestDownloader_checkCompositeSpace(t *testing.T) {
+	dir := t.TempDir()
+	free, err := freeSpace(dir)
+	if err != nil {
+		t.Skip(err)
+	}
+
+	// the streams fit, but not together with the merged output
+	half := &youtube.Format{ContentLength: int64(free/3) + 1}
+
+	dl := Downloader{}
+	assert.NoError(t, dl.checkSpace(dir, 2*half.ContentLength))
+	assert.ErrorAs(t, dl.checkCompositeSpace(dir, dir, half, half), new(*ErrInsufficientSpace))
+
+	// streams of unknown size are not checked
+	assert.NoError(t, dl.checkCompositeSpace(dir, dir, half, &youtube.Format{}))
+}
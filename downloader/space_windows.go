@@ -0,0 +1,20 @@
+//go:build windows
+
+package downloader
+
+import "golang.org/x/sys/windows"
+
+// freeSpace returns the bytes of the volume of dir available to the user
+func freeSpace(dir string) (uint64, error) {
+	path, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var free uint64
+	if err = windows.GetDiskFreeSpaceEx(path, &free, nil, nil); err != nil {
+		return 0, err
+	}
+
+	return free, nil
+}
@@ -0,0 +1,67 @@
+package downloader
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// vttTags matches the markup of WebVTT cue text, like <c> or the word timestamps of generated captions
+var vttTags = regexp.MustCompile(`<[^>]*>`)
+
+// vttToSRT converts WebVTT captions to SubRip.
+// Cue settings, styles and markup are dropped, as SubRip has no equivalent for most of them.
+func vttToSRT(data []byte) []byte {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+
+	var out bytes.Buffer
+	cue := 0
+
+	for _, block := range strings.Split(text, "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+
+		// the header, notes and styles have no timing line, cues may have an identifier before it
+		timing := -1
+		for i, line := range lines {
+			if strings.Contains(line, "-->") {
+				timing = i
+				break
+			}
+		}
+		if timing < 0 {
+			continue
+		}
+
+		fields := strings.Fields(lines[timing])
+		if len(fields) < 3 {
+			continue
+		}
+
+		var cueText []string
+		for _, line := range lines[timing+1:] {
+			line = strings.TrimSpace(html.UnescapeString(vttTags.ReplaceAllString(line, "")))
+			if line != "" {
+				cueText = append(cueText, line)
+			}
+		}
+		if len(cueText) == 0 {
+			continue
+		}
+
+		cue++
+		fmt.Fprintf(&out, "%d\n%s --> %s\n%s\n\n", cue, srtTimestamp(fields[0]), srtTimestamp(fields[2]), strings.Join(cueText, "\n"))
+	}
+
+	return out.Bytes()
+}
+
+// srtTimestamp converts a WebVTT timestamp like 01:02.500 into the SubRip 00:01:02,500
+func srtTimestamp(timestamp string) string {
+	if strings.Count(timestamp, ":") == 1 {
+		timestamp = "00:" + timestamp
+	}
+
+	return strings.Replace(timestamp, ".", ",", 1)
+}
@@ -0,0 +1,116 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// OutputTemplateTokens describes the placeholders of OutputTemplate
+var OutputTemplateTokens = map[string]string{
+	"title":          "the title of the video",
+	"id":             "the ID of the video",
+	"author":         "the author of the video",
+	"date":           "the publish date, e.g. 2021-03-04",
+	"duration":       "the duration, e.g. 4m13s",
+	"resolution":     "the resolution of the format, e.g. 1080p60, empty for audio",
+	"itag":           "the itag of the format",
+	"ext":            "the file extension without dot",
+	"playlist":       "the title of the playlist, empty outside of playlists",
+	"playlist_index": "the position in the playlist zero-padded to its length, e.g. 007, empty outside of playlists",
+}
+
+var templateToken = regexp.MustCompile(`\{(\w+)\}`)
+
+// getDefaultFile returns the name of a file without an explicit name, by OutputTemplate or the title,
+// in the directory of the playlist of the PlaylistEntry of ctx with PlaylistDir.
+// The extension includes the dot.
+func (dl *Downloader) getDefaultFile(ctx context.Context, v *youtube.Video, format *youtube.Format, ext string) (string, error) {
+	playlist := playlistEntryFrom(ctx)
+
+	name, err := dl.defaultName(v, format, ext, playlist)
+	if err != nil {
+		return "", err
+	}
+
+	if dl.PlaylistDir && playlist != nil {
+		dir := strings.TrimSpace(SanitizeFilename(playlist.Title))
+		if strings.Trim(dir, ".") == "" {
+			dir = "_"
+		}
+		name = filepath.Join(dir, name)
+	}
+
+	return name, nil
+}
+
+// defaultName returns the name of a file by OutputTemplate or the title
+func (dl *Downloader) defaultName(v *youtube.Video, format *youtube.Format, ext string, playlist *PlaylistEntry) (string, error) {
+	if dl.OutputTemplate != "" {
+		return expandTemplate(dl.OutputTemplate, v, format, ext, playlist)
+	}
+
+	name := SanitizeFilename(v.Title)
+	if res := resolution(format); dl.ResolutionSuffix && res != "" {
+		name += " [" + SanitizeFilename(res) + "]"
+	}
+
+	return name + ext, nil
+}
+
+// expandTemplate replaces the tokens of the template with the values of the video and format,
+// and of the playlist entry unless it is nil.
+// Path segments of the template with tokens are sanitized, so slashes in titles don't create directories.
+func expandTemplate(template string, v *youtube.Video, format *youtube.Format, ext string, playlist *PlaylistEntry) (string, error) {
+	values := map[string]string{
+		"title":      v.Title,
+		"id":         v.ID,
+		"author":     v.Author,
+		"duration":   v.Duration.String(),
+		"resolution": resolution(format),
+		"itag":       strconv.Itoa(format.ItagNo),
+		"ext":        strings.TrimPrefix(ext, "."),
+	}
+	if !v.PublishDate.IsZero() {
+		values["date"] = v.PublishDate.Format("2006-01-02")
+	}
+	if playlist != nil {
+		values["playlist"] = playlist.Title
+		values["playlist_index"] = playlist.paddedIndex()
+	}
+
+	var unknown string
+
+	segments := strings.Split(filepath.ToSlash(template), "/")
+	for i, segment := range segments {
+		if !templateToken.MatchString(segment) {
+			continue
+		}
+
+		expanded := templateToken.ReplaceAllStringFunc(segment, func(token string) string {
+			name := token[1 : len(token)-1]
+			if _, ok := OutputTemplateTokens[name]; !ok && unknown == "" {
+				unknown = token
+			}
+			return values[name]
+		})
+
+		expanded = strings.TrimSpace(SanitizeFilename(expanded))
+		if strings.Trim(expanded, ".") == "" {
+			// an empty value must not turn into the current or the parent directory
+			expanded = "_"
+		}
+		segments[i] = expanded
+	}
+
+	if unknown != "" {
+		return "", fmt.Errorf("unknown token %s in output template", unknown)
+	}
+
+	return filepath.FromSlash(strings.Join(segments, "/")), nil
+}
@@ -0,0 +1,99 @@
+package downloader
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	video := &youtube.Video{
+		ID:          "BaW_jenozKc",
+		Title:       "AC/DC: Live",
+		Author:      "Some Channel",
+		Duration:    253 * time.Second,
+		PublishDate: time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC),
+	}
+	format := &youtube.Format{ItagNo: 137, QualityLabel: "1080p"}
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"{title}.{ext}", "ACDC Live.mp4"},
+		{"{author}/{title}-{id}.{ext}", "Some Channel/ACDC Live-BaW_jenozKc.mp4"},
+		{"videos/{date} {title} [{resolution}, {itag}].{ext}", "videos/2021-03-04 ACDC Live [1080p, 137].mp4"},
+		{"{duration}.{ext}", "4m13s.mp4"},
+		{"/{id}", "/BaW_jenozKc"},
+		{"{resolution}/../{title}", "1080p/../ACDC Live"},
+	}
+
+	for _, tt := range tests {
+		got, err := expandTemplate(tt.template, video, format, ".mp4", nil)
+		require.NoError(t, err)
+		assert.Equal(t, filepath.FromSlash(tt.want), got, tt.template)
+	}
+
+	// empty values don't turn into directories
+	got, err := expandTemplate("{date}/{title}", &youtube.Video{Title: ".."}, format, ".mp4", nil)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.FromSlash("_/_"), got)
+
+	_, err = expandTemplate("{title}-{views}.{ext}", video, format, ".mp4", nil)
+	assert.EqualError(t, err, "unknown token {views} in output template")
+}
+
+func TestDownloader_getOutputFile_template(t *testing.T) {
+	dl := Downloader{OutputDir: t.TempDir(), OutputTemplate: "{author}/{title}.{ext}"}
+	video := &youtube.Video{Title: "Title", Author: "Author"}
+
+	got, err := dl.getOutputFile(context.Background(), video, &youtube.Format{MimeType: "audio/mp4"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dl.OutputDir, "Author", "Title.m4a"), got)
+	assert.DirExists(t, filepath.Join(dl.OutputDir, "Author"))
+}
+
+func TestExpandTemplate_playlist(t *testing.T) {
+	video := &youtube.Video{ID: "BaW_jenozKc", Title: "Title"}
+	format := &youtube.Format{ItagNo: 18}
+
+	got, err := expandTemplate("{playlist}/{playlist_index} - {title}.{ext}", video, format, ".mp4", &PlaylistEntry{Title: "Go: Talks", Index: 7, Count: 120})
+	require.NoError(t, err)
+	assert.Equal(t, filepath.FromSlash("Go Talks/007 - Title.mp4"), got)
+
+	got, err = expandTemplate("{playlist_index}-{title}.{ext}", video, format, ".mp4", &PlaylistEntry{Index: 3, Count: 9})
+	require.NoError(t, err)
+	assert.Equal(t, "3-Title.mp4", got)
+
+	// outside of playlists the tokens are empty
+	got, err = expandTemplate("{playlist_index}{title}.{ext}", video, format, ".mp4", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Title.mp4", got)
+}
+
+func TestDownloader_getOutputFile_playlistDir(t *testing.T) {
+	dl := Downloader{OutputDir: t.TempDir(), PlaylistDir: true}
+	video := &youtube.Video{Title: "Title"}
+	format := &youtube.Format{MimeType: "video/mp4"}
+
+	got, err := dl.getOutputFile(context.Background(), video, format, "")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dl.OutputDir, "Title.mp4"), got, "not in a playlist")
+
+	ctx := WithPlaylistEntry(context.Background(), PlaylistEntry{Title: "AC/DC: Live", Index: 2, Count: 10})
+	got, err = dl.getOutputFile(ctx, video, format, "")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dl.OutputDir, "ACDC Live", "Title.mp4"), got)
+	assert.DirExists(t, filepath.Join(dl.OutputDir, "ACDC Live"))
+
+	dl.OutputTemplate = "{playlist_index} {title}.{ext}"
+	got, err = dl.getOutputFile(ctx, video, format, "")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dl.OutputDir, "ACDC Live", "02 Title.mp4"), got)
+}
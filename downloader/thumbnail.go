@@ -0,0 +1,154 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// Thumbnail formats, see Downloader.ThumbnailFormat
+const (
+	ThumbnailJPG  = "jpg"
+	ThumbnailWebP = "webp"
+)
+
+// thumbnailBaseURL serves the thumbnails, it is changed by tests
+var thumbnailBaseURL = "https://i.ytimg.com"
+
+// thumbnail sizes served for every video, largest first.
+// The larger ones are often missing from Video.Thumbnails or not available at all.
+var thumbnailSizes = []string{"maxresdefault", "sddefault", "hqdefault"}
+
+// DownloadThumbnail downloads the largest thumbnail of the video in ThumbnailFormat and returns its path.
+// If the thumbnail is only available in the other format it is converted with ffmpeg.
+// Without an outputFile the file is named after the video title.
+func (dl *Downloader) DownloadThumbnail(ctx context.Context, v *youtube.Video, outputFile string) (string, error) {
+	format := dl.thumbnailFormat()
+
+	youtube.Logger.Info("Downloading thumbnail", "id", v.ID, "format", format)
+
+	if outputFile == "" {
+		outputFile = SanitizeFilename(v.Title) + "." + format
+	}
+
+	destFile, err := dl.joinOutputDir(outputFile)
+	if err != nil {
+		return "", err
+	}
+
+	if err = dl.writeThumbnail(ctx, v, format, destFile); err != nil {
+		return "", err
+	}
+
+	return destFile, nil
+}
+
+// writeThumbnail writes the largest thumbnail of the video in the format into destFile
+func (dl *Downloader) writeThumbnail(ctx context.Context, v *youtube.Video, format string, destFile string) error {
+	data, thumbnailURL, err := dl.fetchThumbnail(ctx, v, format)
+	if err != nil {
+		return err
+	}
+
+	if thumbnailExtension(thumbnailURL) == format {
+		return os.WriteFile(destFile, data, 0o644)
+	}
+
+	return dl.convertThumbnail(ctx, data, thumbnailExtension(thumbnailURL), destFile)
+}
+
+// fetchThumbnail returns the largest thumbnail of the video, preferably in the format, and the URL it was fetched from
+func (dl *Downloader) fetchThumbnail(ctx context.Context, v *youtube.Video, format string) ([]byte, string, error) {
+	for _, thumbnailURL := range thumbnailURLs(v, format) {
+		data, err := dl.httpGetBodyBytes(ctx, thumbnailURL)
+		if err != nil {
+			var statusErr youtube.ErrUnexpectedStatusCode
+			if errors.As(err, &statusErr) && statusErr == http.StatusNotFound {
+				youtube.Logger.Debug("thumbnail not found", "url", thumbnailURL)
+				continue
+			}
+			return nil, "", err
+		}
+
+		return data, thumbnailURL, nil
+	}
+
+	return nil, "", fmt.Errorf("%w for video %s", ErrNoThumbnail, v.ID)
+}
+
+func (dl *Downloader) thumbnailFormat() string {
+	if dl.ThumbnailFormat == "" {
+		return ThumbnailJPG
+	}
+
+	return dl.ThumbnailFormat
+}
+
+// thumbnailURLs returns the URLs to try, the preferred format first for each size.
+// The thumbnails listed by the video are the last resort.
+func thumbnailURLs(v *youtube.Video, format string) []string {
+	formats := []string{ThumbnailJPG, ThumbnailWebP}
+	if format == ThumbnailWebP {
+		formats = []string{ThumbnailWebP, ThumbnailJPG}
+	}
+
+	var urls []string
+	for _, size := range thumbnailSizes {
+		for _, f := range formats {
+			dir := "vi"
+			if f == ThumbnailWebP {
+				dir = "vi_webp"
+			}
+			urls = append(urls, fmt.Sprintf("%s/%s/%s/%s.%s", thumbnailBaseURL, dir, v.ID, size, f))
+		}
+	}
+
+	listed := append(youtube.Thumbnails(nil), v.Thumbnails...)
+	sort.SliceStable(listed, func(i, j int) bool {
+		return listed[i].Width > listed[j].Width
+	})
+	for _, thumbnail := range listed {
+		urls = append(urls, thumbnail.URL)
+	}
+
+	return urls
+}
+
+// thumbnailExtension returns the format of the thumbnail by the extension of the URL path
+func thumbnailExtension(thumbnailURL string) string {
+	uri, err := url.Parse(thumbnailURL)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimPrefix(path.Ext(uri.Path), ".")
+}
+
+// convertThumbnail converts the image with ffmpeg, the format is taken from the extension of destFile
+func (dl *Downloader) convertThumbnail(ctx context.Context, data []byte, ext string, destFile string) error {
+	tmpFile, err := os.CreateTemp("", "youtube_*."+ext)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	_, err = tmpFile.Write(data)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	youtube.Logger.Debug("converting thumbnail", "from", ext, "path", destFile)
+
+	return dl.runFFmpeg(ctx, "-y", "-loglevel", "warning", "-i", tmpFile.Name(), destFile)
+}
@@ -0,0 +1,75 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func setThumbnailBaseURL(t *testing.T, baseURL string) {
+	previous := thumbnailBaseURL
+	thumbnailBaseURL = baseURL
+	t.Cleanup(func() { thumbnailBaseURL = previous })
+}
+
+func TestThumbnailURLs(t *testing.T) {
+	setThumbnailBaseURL(t, "https://i.ytimg.com")
+
+	v := &youtube.Video{ID: "BaW_jenozKc", Thumbnails: youtube.Thumbnails{
+		{URL: "https://i.ytimg.com/vi/BaW_jenozKc/default.jpg", Width: 120},
+		{URL: "https://i.ytimg.com/vi/BaW_jenozKc/mqdefault.jpg", Width: 320},
+	}}
+
+	assert.Equal(t, []string{
+		"https://i.ytimg.com/vi_webp/BaW_jenozKc/maxresdefault.webp",
+		"https://i.ytimg.com/vi/BaW_jenozKc/maxresdefault.jpg",
+		"https://i.ytimg.com/vi_webp/BaW_jenozKc/sddefault.webp",
+		"https://i.ytimg.com/vi/BaW_jenozKc/sddefault.jpg",
+		"https://i.ytimg.com/vi_webp/BaW_jenozKc/hqdefault.webp",
+		"https://i.ytimg.com/vi/BaW_jenozKc/hqdefault.jpg",
+		"https://i.ytimg.com/vi/BaW_jenozKc/mqdefault.jpg",
+		"https://i.ytimg.com/vi/BaW_jenozKc/default.jpg",
+	}, thumbnailURLs(v, ThumbnailWebP))
+
+	assert.Equal(t, "webp", thumbnailExtension("https://i.ytimg.com/vi_webp/BaW_jenozKc/sddefault.webp?v=1"))
+}
+
+func TestDownloader_DownloadThumbnail(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/vi/BaW_jenozKc/sddefault.jpg":
+			w.Write([]byte("sd jpg")) //nolint:errcheck
+		case "/vi/broken/maxresdefault.jpg":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	setThumbnailBaseURL(t, server.URL)
+
+	dl := Downloader{OutputDir: t.TempDir()}
+
+	path, err := dl.DownloadThumbnail(context.Background(), &youtube.Video{ID: "BaW_jenozKc", Title: "Title"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dl.OutputDir, "Title.jpg"), path)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "sd jpg", string(data))
+
+	// only 404 falls back to the next size
+	_, err = dl.DownloadThumbnail(context.Background(), &youtube.Video{ID: "broken", Title: "Broken"}, "")
+	assert.Equal(t, youtube.ErrUnexpectedStatusCode(http.StatusForbidden), err)
+
+	_, err = dl.DownloadThumbnail(context.Background(), &youtube.Video{ID: "missing", Title: "Missing"}, "")
+	assert.EqualError(t, err, "no thumbnail found for video missing")
+}
@@ -0,0 +1,84 @@
+package downloader
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const redacted = "REDACTED"
+
+// query parameters and headers left out of the traffic log
+var (
+	sensitiveParams  = []string{"sig", "lsig", "signature", "key", "pot", "ip"}
+	sensitiveHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+)
+
+// trafficLogger is an http.RoundTripper writing requests and responses to a log, see Downloader.TrafficLog
+type trafficLogger struct {
+	next http.RoundTripper
+
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func (t *trafficLogger) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "> %s %s\n", req.Method, redactURL(req.URL))
+	writeHeaders(&b, ">", req.Header)
+
+	if err != nil {
+		fmt.Fprintf(&b, "< error: %v (%v)\n", err, time.Since(start).Round(time.Millisecond))
+	} else {
+		fmt.Fprintf(&b, "< %s (%v)\n", resp.Status, time.Since(start).Round(time.Millisecond))
+		writeHeaders(&b, "<", resp.Header)
+	}
+
+	// chunks are requested concurrently, keep each exchange together
+	t.mu.Lock()
+	io.WriteString(t.out, b.String()) //nolint:errcheck
+	t.mu.Unlock()
+
+	return resp, err
+}
+
+// redactURL returns the URL with the values of sensitive query parameters replaced
+func redactURL(u *url.URL) string {
+	query := u.Query()
+	for _, param := range sensitiveParams {
+		if query.Has(param) {
+			query.Set(param, redacted)
+		}
+	}
+
+	redactedURL := *u
+	redactedURL.RawQuery = query.Encode()
+
+	return redactedURL.String()
+}
+
+func writeHeaders(b *strings.Builder, prefix string, header http.Header) {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := strings.Join(header[key], ", ")
+		for _, sensitive := range sensitiveHeaders {
+			if key == sensitive {
+				value = redacted
+			}
+		}
+		fmt.Fprintf(b, "%s %s: %s\n", prefix, key, value)
+	}
+}
@@ -0,0 +1,48 @@
+package downloader
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrafficLogger(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "VISITOR_INFO1_LIVE", Value: "secret"})
+		w.Header().Set("Content-Type", "video/mp4")
+		w.WriteHeader(http.StatusPartialContent)
+	}))
+	defer server.Close()
+
+	var log bytes.Buffer
+	dl := Downloader{TrafficLog: &log}
+	require.NoError(t, dl.SetupHTTPClient())
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/videoplayback?itag=137&sig=AOq0QJ8w&range=0-99", nil)
+	require.NoError(t, err)
+	req.Header.Set("Cookie", "SID=secret")
+
+	resp, err := dl.HTTPClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	output := log.String()
+	assert.Contains(t, output, "> GET "+server.URL+"/videoplayback?itag=137&range=0-99&sig=REDACTED\n")
+	assert.Contains(t, output, "> Cookie: REDACTED\n")
+	assert.Contains(t, output, "< 206 Partial Content (")
+	assert.Contains(t, output, "< Content-Type: video/mp4\n")
+	assert.Contains(t, output, "< Set-Cookie: REDACTED\n")
+	assert.NotContains(t, output, "secret")
+	assert.NotContains(t, output, "AOq0QJ8w")
+}
+
+func Test_redactURL(t *testing.T) {
+	u, err := url.Parse("https://www.youtube.com/youtubei/v1/player?key=AIzaSy&prettyPrint=false")
+	require.NoError(t, err)
+	assert.Equal(t, "https://www.youtube.com/youtubei/v1/player?key=REDACTED&prettyPrint=false", redactURL(u))
+}
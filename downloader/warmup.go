@@ -0,0 +1,76 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// warmConnections opens the connections used by the chunked download of the format up front,
+// so all chunk requests start without waiting for TCP and TLS handshakes.
+//
+// The transport has to keep enough idle connections per host, see http.Transport.MaxIdleConnsPerHost.
+// With HTTP/2 a single connection is shared by all chunk requests.
+func (dl *Downloader) warmConnections(ctx context.Context, video *youtube.Video, format *youtube.Format) {
+	streamURL, err := dl.GetStreamURLContext(ctx, video, format)
+	if err != nil {
+		youtube.Logger.Debug("unable to warm up connections", "error", err)
+		return
+	}
+
+	client := dl.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	warmHost(ctx, client, streamURL, dl.chunkConnections(format.ContentLength))
+}
+
+// warmHost sends n simultaneous HEAD requests to the url, leaving n idle connections in the pool
+func warmHost(ctx context.Context, client *http.Client, url string, n int) {
+	var wg sync.WaitGroup
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+			if err != nil {
+				return
+			}
+
+			resp, err := client.Do(req)
+			if err != nil {
+				youtube.Logger.Debug("warm up request failed", "error", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+
+	wg.Wait()
+}
+
+// chunkConnections returns the number of connections youtube.Client uses to download a stream in chunks
+func (dl *Downloader) chunkConnections(contentLength int64) int {
+	chunkSize := dl.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = youtube.Size10Mb
+	}
+
+	chunks := int((contentLength + chunkSize - 1) / chunkSize)
+
+	routines := 10
+	if dl.MaxRoutines > 0 {
+		routines = dl.MaxRoutines
+	}
+
+	if chunks < routines {
+		return chunks
+	}
+
+	return routines
+}
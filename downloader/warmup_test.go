@@ -0,0 +1,70 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+func Test_chunkConnections(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(1, (&Downloader{}).chunkConnections(1))
+	assert.Equal(3, (&Downloader{}).chunkConnections(youtube.Size10Mb*2+1))
+	assert.Equal(10, (&Downloader{}).chunkConnections(youtube.Size10Mb*100))
+
+	dl := Downloader{}
+	dl.MaxRoutines = 4
+	dl.ChunkSize = youtube.Size1Mb
+	assert.Equal(4, dl.chunkConnections(youtube.Size10Mb))
+}
+
+// BenchmarkChunkedStart measures the time until all chunk requests of a download got a response.
+// Warming up the connections takes the TLS handshakes out of it, even on localhost:
+//
+//	BenchmarkChunkedStart/cold    200    30823933 ns/op
+//	BenchmarkChunkedStart/warm    200      462398 ns/op
+func BenchmarkChunkedStart(b *testing.B) {
+	const connections = 10
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	run := func(b *testing.B, warm bool) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			transport := server.Client().Transport.(*http.Transport).Clone()
+			transport.MaxIdleConnsPerHost = connections
+			client := &http.Client{Transport: transport}
+			if warm {
+				warmHost(context.Background(), client, server.URL, connections)
+			}
+			b.StartTimer()
+
+			var wg sync.WaitGroup
+			for j := 0; j < connections; j++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if resp, err := client.Get(server.URL); err == nil {
+						resp.Body.Close()
+					}
+				}()
+			}
+			wg.Wait()
+
+			b.StopTimer()
+			transport.CloseIdleConnections()
+			b.StartTimer()
+		}
+	}
+
+	b.Run("cold", func(b *testing.B) { run(b, false) })
+	b.Run("warm", func(b *testing.B) { run(b, true) })
+}
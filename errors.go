@@ -2,6 +2,7 @@ package youtube
 
 import (
 	"fmt"
+	"strings"
 )
 
 const (
@@ -14,6 +15,14 @@ const (
 	ErrLoginRequired              = constError("login required to confirm your age")
 	ErrVideoPrivate               = constError("user restricted access to this video")
 	ErrInvalidPlaylist            = constError("no playlist detected or invalid playlist ID")
+	ErrRangeNotSupported          = constError("ranged requests are not supported for this stream")
+	ErrInvalidChunkSize           = constError("chunk has invalid size")
+
+	// the reasons a video is unavailable, see ErrPlayabiltyStatus
+	ErrGeoBlocked    = constError("the video is not available in your country")
+	ErrRemoved       = constError("the video has been removed or doesn't exist")
+	ErrAgeRestricted = constError("the video is age-restricted")
+	ErrPrivate       = ErrVideoPrivate
 )
 
 type constError string
@@ -31,6 +40,39 @@ func (err ErrPlayabiltyStatus) Error() string {
 	return fmt.Sprintf("cannot playback and download, status: %s, reason: %s", err.Status, err.Reason)
 }
 
+// Unwrap returns ErrGeoBlocked, ErrPrivate, ErrAgeRestricted or ErrRemoved if the status tells why the video is unavailable
+func (err ErrPlayabiltyStatus) Unwrap() error {
+	reason := strings.ToLower(err.Reason)
+
+	switch {
+	case strings.Contains(reason, "country"):
+		// e.g. "The uploader has not made this video available in your country"
+		return ErrGeoBlocked
+	case strings.Contains(reason, "private"):
+		return ErrPrivate
+	case strings.Contains(reason, "your age") || strings.Contains(reason, "inappropriate"):
+		return ErrAgeRestricted
+	case err.Status == "ERROR":
+		// e.g. "Video unavailable" or "This video has been removed by the uploader"
+		return ErrRemoved
+	}
+
+	return nil
+}
+
+// ageRestrictionError is returned when the age restriction of a video can't be bypassed, it is ErrAgeRestricted
+type ageRestrictionError struct {
+	err error
+}
+
+func (err ageRestrictionError) Error() string {
+	return "can't bypass age restriction: " + err.err.Error()
+}
+
+func (err ageRestrictionError) Unwrap() []error {
+	return []error{ErrAgeRestricted, err.err}
+}
+
 // ErrUnexpectedStatusCode is returned on unexpected HTTP status codes
 type ErrUnexpectedStatusCode int
 
@@ -22,3 +22,33 @@ func TestErrors(t *testing.T) {
 		})
 	}
 }
+
+func TestErrPlayabiltyStatus_unwrap(t *testing.T) {
+	tests := []struct {
+		status   ErrPlayabiltyStatus
+		expected error
+	}{
+		{ErrPlayabiltyStatus{"UNPLAYABLE", "The uploader has not made this video available in your country"}, ErrGeoBlocked},
+		{ErrPlayabiltyStatus{"UNPLAYABLE", "This video is private"}, ErrPrivate},
+		{ErrPlayabiltyStatus{"UNPLAYABLE", "Sign in to confirm your age"}, ErrAgeRestricted},
+		{ErrPlayabiltyStatus{"ERROR", "This video has been removed by the uploader"}, ErrRemoved},
+		{ErrPlayabiltyStatus{"ERROR", "Video unavailable"}, ErrRemoved},
+		{ErrPlayabiltyStatus{"UNPLAYABLE", "for that reason"}, nil},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.status.Unwrap())
+			if tt.expected != nil {
+				assert.ErrorIs(t, &tt.status, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAgeRestrictionError(t *testing.T) {
+	err := ageRestrictionError{ErrNotPlayableInEmbed}
+
+	assert.EqualError(t, err, "can't bypass age restriction: embedding of this video has been disabled")
+	assert.ErrorIs(t, err, ErrAgeRestricted)
+	assert.ErrorIs(t, err, ErrNotPlayableInEmbed)
+}
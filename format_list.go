@@ -72,6 +72,54 @@ func (list FormatList) WithAudioChannels() (result FormatList) {
 	return result
 }
 
+// AudioQuality returns a new FormatList filtered by the audio quality,
+// either as reported like "AUDIO_QUALITY_MEDIUM" or without the prefix like "medium"
+func (list FormatList) AudioQuality(quality string) (result FormatList) {
+	for _, f := range list {
+		if strings.EqualFold(f.AudioQuality, quality) || strings.EqualFold(f.AudioQuality, "AUDIO_QUALITY_"+quality) {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// AudioLanguage returns a new FormatList filtered by the language of the audio track.
+// A language without region like "es" also matches regional tracks like "es-419".
+func (list FormatList) AudioLanguage(language string) (result FormatList) {
+	for _, f := range list {
+		if f.AudioTrack == nil {
+			continue
+		}
+		if code := f.AudioTrack.Language(); code == language || strings.HasPrefix(code, language+"-") {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// DefaultAudioTrack returns a new FormatList with the formats of the default audio track
+// and the formats without an audio track
+func (list FormatList) DefaultAudioTrack() (result FormatList) {
+	for _, f := range list {
+		if f.AudioTrack == nil || f.AudioTrack.AudioIsDefault {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// EstimatedSize returns a new FormatList filtered by the estimated size in bytes, see Format.EstimatedSize.
+// A max of 0 means no upper limit. Formats of unknown size are filtered out.
+func (list FormatList) EstimatedSize(min, max int64) (result FormatList) {
+	for i := range list {
+		size := list[i].EstimatedSize()
+		if size > 0 && size >= min && (max == 0 || size <= max) {
+			result = append(result, list[i])
+		}
+	}
+	return result
+}
+
 // FilterQuality reduces the format list to formats matching the quality
 func (v *Video) FilterQuality(quality string) {
 	v.Formats = v.Formats.Quality(quality)
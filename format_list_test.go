@@ -208,3 +208,58 @@ func TestFormatList_WithAudioChannels(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatList_AudioLanguage(t *testing.T) {
+	list := FormatList{
+		{ItagNo: 1, AudioTrack: &AudioTrack{ID: "en.4", AudioIsDefault: true}},
+		{ItagNo: 2, AudioTrack: &AudioTrack{ID: "es-419.3"}},
+		{ItagNo: 3, AudioTrack: &AudioTrack{ID: "es-ES.3"}},
+		{ItagNo: 4},
+	}
+
+	assert.Equal(t, []int{2, 3}, formatItags(list.AudioLanguage("es")))
+	assert.Equal(t, []int{2}, formatItags(list.AudioLanguage("es-419")))
+	assert.Empty(t, list.AudioLanguage("fr"))
+	assert.Equal(t, []int{1, 4}, formatItags(list.DefaultAudioTrack()))
+}
+
+func TestFormatList_AudioQuality(t *testing.T) {
+	list := FormatList{
+		{ItagNo: 1, AudioQuality: "AUDIO_QUALITY_MEDIUM"},
+		{ItagNo: 2, AudioQuality: "AUDIO_QUALITY_LOW"},
+		{ItagNo: 3},
+	}
+
+	assert.Equal(t, []int{1}, formatItags(list.AudioQuality("medium")))
+	assert.Equal(t, []int{2}, formatItags(list.AudioQuality("AUDIO_QUALITY_LOW")))
+	assert.Empty(t, list.AudioQuality("high"))
+}
+
+func TestFormat_EstimatedSize(t *testing.T) {
+	assert.Equal(t, int64(1000), (&Format{ContentLength: 1000, AverageBitrate: 8000, ApproxDurationMs: "5000"}).EstimatedSize())
+	assert.Equal(t, int64(5000), (&Format{AverageBitrate: 8000, Bitrate: 16000, ApproxDurationMs: "5000"}).EstimatedSize())
+	assert.Equal(t, int64(10000), (&Format{Bitrate: 16000, ApproxDurationMs: "5000"}).EstimatedSize())
+	assert.Equal(t, int64(0), (&Format{Bitrate: 16000}).EstimatedSize())
+}
+
+func TestFormatList_EstimatedSize(t *testing.T) {
+	list := FormatList{
+		{ItagNo: 1, ContentLength: 100},
+		{ItagNo: 2, ContentLength: 200},
+		{ItagNo: 3, ContentLength: 300},
+		{ItagNo: 4},
+	}
+
+	assert.Equal(t, []int{2, 3}, formatItags(list.EstimatedSize(150, 0)))
+	assert.Equal(t, []int{1, 2}, formatItags(list.EstimatedSize(0, 200)))
+	assert.Equal(t, []int{2}, formatItags(list.EstimatedSize(200, 200)))
+	assert.Empty(t, list.EstimatedSize(400, 0))
+}
+
+// formatItags returns the itags of the formats, for comparing lists
+func formatItags(list FormatList) (result []int) {
+	for _, f := range list {
+		result = append(result, f.ItagNo)
+	}
+	return result
+}
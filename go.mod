@@ -3,6 +3,7 @@ module github.com/kkdai/youtube/v2
 go 1.21
 
 require (
+	github.com/VividCortex/ewma v1.2.0
 	github.com/bitly/go-simplejson v0.5.1
 	github.com/dop251/goja v0.0.0-20231027120936-b396bb4c349d
 	github.com/mitchellh/go-homedir v1.1.0
@@ -13,10 +14,10 @@ require (
 	github.com/stretchr/testify v1.8.4
 	github.com/vbauerster/mpb/v5 v5.4.0
 	golang.org/x/net v0.17.0
+	golang.org/x/sys v0.14.0
 )
 
 require (
-	github.com/VividCortex/ewma v1.2.0 // indirect
 	github.com/acarl005/stripansi v0.0.0-20180116102854-5a71ef0e047d // indirect
 	github.com/davecgh/go-spew v1.1.2-0.20180830191138-d8f796af33cc // indirect
 	github.com/dlclark/regexp2 v1.10.0 // indirect
@@ -40,7 +41,6 @@ require (
 	github.com/subosito/gotenv v1.6.0 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
 	golang.org/x/exp v0.0.0-20231006140011-7918f672742d // indirect
-	golang.org/x/sys v0.14.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
 	gopkg.in/ini.v1 v1.67.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
@@ -1,19 +1,33 @@
 package youtube
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 )
 
+var (
+	logLevel = os.Getenv("LOGLEVEL")
+	logColor bool
+)
+
 // The global logger for all Client instances
-var Logger = getLogger(os.Getenv("LOGLEVEL"))
+var Logger = getLogger(logLevel, logColor)
 
 func SetLogLevel(value string) {
-	Logger = getLogger(value)
+	logLevel = value
+	Logger = getLogger(logLevel, logColor)
+}
+
+// SetLogColor enables or disables colored log levels, it is disabled by default
+func SetLogColor(enabled bool) {
+	logColor = enabled
+	Logger = getLogger(logLevel, logColor)
 }
 
-func getLogger(logLevel string) *slog.Logger {
+func getLogger(logLevel string, color bool) *slog.Logger {
 	levelVar := slog.LevelVar{}
 
 	if logLevel != "" {
@@ -22,7 +36,64 @@ func getLogger(logLevel string) *slog.Logger {
 		}
 	}
 
-	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+	var w io.Writer = os.Stderr
+	if color {
+		w = colorWriter{w}
+	}
+
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{
 		Level: levelVar.Level(),
 	}))
 }
+
+// ANSI colors of the log levels
+var levelColors = []struct {
+	level []byte
+	color []byte
+}{
+	{[]byte("level=DEBUG"), []byte("\x1b[90m")},
+	{[]byte("level=INFO"), []byte("\x1b[32m")},
+	{[]byte("level=WARN"), []byte("\x1b[33m")},
+	{[]byte("level=ERROR"), []byte("\x1b[31m")},
+}
+
+var (
+	levelKey   = []byte(slog.LevelKey + "=")
+	colorReset = []byte("\x1b[0m")
+)
+
+// colorWriter colors the level of the lines written by slog.TextHandler.
+// The handler quotes escape sequences in attributes, so coloring has to happen after formatting.
+// It writes each record with a single call.
+type colorWriter struct {
+	w io.Writer
+}
+
+func (cw colorWriter) Write(p []byte) (int, error) {
+	// the level precedes the message and attributes
+	i := bytes.Index(p, levelKey)
+	if i < 0 {
+		return cw.w.Write(p)
+	}
+
+	for _, lc := range levelColors {
+		if !bytes.HasPrefix(p[i:], lc.level) {
+			continue
+		}
+
+		end := i + len(lc.level)
+		line := make([]byte, 0, len(p)+len(lc.color)+len(colorReset))
+		line = append(line, p[:i]...)
+		line = append(line, lc.color...)
+		line = append(line, p[i:end]...)
+		line = append(line, colorReset...)
+		line = append(line, p[end:]...)
+
+		if _, err := cw.w.Write(line); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	return cw.w.Write(p)
+}
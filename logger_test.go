@@ -0,0 +1,28 @@
+package youtube
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColorWriter(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(colorWriter{&buf}, &slog.HandlerOptions{
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	logger.Warn("retrying", "reason", "level=INFO")
+	assert.Equal(t, "\x1b[33mlevel=WARN\x1b[0m msg=retrying reason=\"level=INFO\"\n", buf.String())
+
+	buf.Reset()
+	logger.Error("failed")
+	assert.Equal(t, "\x1b[31mlevel=ERROR\x1b[0m msg=failed\n", buf.String())
+}
@@ -1,5 +1,10 @@
 package youtube
 
+import (
+	"strconv"
+	"strings"
+)
+
 type playerResponseData struct {
 	Captions struct {
 		PlayerCaptionsTracklistRenderer struct {
@@ -54,6 +59,7 @@ type playerResponseData struct {
 		IsPrivate         bool    `json:"isPrivate"`
 		IsUnpluggedCorpus bool    `json:"isUnpluggedCorpus"`
 		IsLiveContent     bool    `json:"isLiveContent"`
+		IsLive            bool    `json:"isLive"`
 	} `json:"videoDetails"`
 	Microformat struct {
 		PlayerMicroformatRenderer struct {
@@ -106,6 +112,9 @@ type Format struct {
 	AudioSampleRate  string `json:"audioSampleRate"`
 	AudioChannels    int    `json:"audioChannels"`
 
+	// AudioTrack is only available for videos with multiple audio tracks, e.g. dubs
+	AudioTrack *AudioTrack `json:"audioTrack"`
+
 	// InitRange is only available for adaptive formats
 	InitRange *struct {
 		Start string `json:"start"`
@@ -119,6 +128,36 @@ type Format struct {
 	} `json:"indexRange"`
 }
 
+// EstimatedSize returns the size of the format in bytes.
+// If the content length is unknown, it is estimated by the bitrate and duration. It returns 0 if both are unknown.
+func (f *Format) EstimatedSize() int64 {
+	if f.ContentLength > 0 {
+		return f.ContentLength
+	}
+
+	bitrate := f.AverageBitrate
+	if bitrate == 0 {
+		bitrate = f.Bitrate
+	}
+
+	durationMs, _ := strconv.ParseInt(f.ApproxDurationMs, 10, 64)
+
+	return int64(bitrate) * durationMs / 8000
+}
+
+// AudioTrack describes one of multiple audio tracks of a video
+type AudioTrack struct {
+	DisplayName    string `json:"displayName"` // e.g. "Spanish (Latin America)"
+	ID             string `json:"id"`          // language and track number, e.g. "es-419.4"
+	AudioIsDefault bool   `json:"audioIsDefault"`
+}
+
+// Language returns the language code of the track, e.g. "es-419"
+func (track *AudioTrack) Language() string {
+	language, _, _ := strings.Cut(track.ID, ".")
+	return language
+}
+
 type Thumbnails []Thumbnail
 
 type Thumbnail struct {
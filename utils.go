@@ -12,10 +12,10 @@ type chunk struct {
 	data  chan []byte
 }
 
-func getChunks(totalSize, chunkSize int64) []chunk {
+func getChunks(offset, totalSize, chunkSize int64) []chunk {
 	var chunks []chunk
 
-	for start := int64(0); start < totalSize; start += chunkSize {
+	for start := offset; start < totalSize; start += chunkSize {
 		end := chunkSize + start - 1
 		if end > totalSize-1 {
 			end = totalSize - 1
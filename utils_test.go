@@ -8,7 +8,7 @@ import (
 
 func TestGetChunks1(t *testing.T) {
 	require := require.New(t)
-	chunks := getChunks(13, 5)
+	chunks := getChunks(0, 13, 5)
 
 	require.Len(chunks, 3)
 	require.EqualValues(0, chunks[0].start)
@@ -21,7 +21,18 @@ func TestGetChunks1(t *testing.T) {
 
 func TestGetChunks_length(t *testing.T) {
 	require := require.New(t)
-	require.Len(getChunks(10, 9), 2)
-	require.Len(getChunks(10, 10), 1)
-	require.Len(getChunks(10, 11), 1)
+	require.Len(getChunks(0, 10, 9), 2)
+	require.Len(getChunks(0, 10, 10), 1)
+	require.Len(getChunks(0, 10, 11), 1)
+}
+
+func TestGetChunks_offset(t *testing.T) {
+	require := require.New(t)
+	chunks := getChunks(7, 13, 5)
+
+	require.Len(chunks, 2)
+	require.EqualValues(7, chunks[0].start)
+	require.EqualValues(11, chunks[0].end)
+	require.EqualValues(12, chunks[1].start)
+	require.EqualValues(12, chunks[1].end)
 }
@@ -26,7 +26,9 @@ type Video struct {
 	Thumbnails      Thumbnails
 	DASHManifestURL string // URI of the DASH manifest file
 	HLSManifestURL  string // URI of the HLS manifest file
+	IsLive          bool   // an ongoing live stream, recorded from the HLSManifestURL
 	CaptionTracks   []CaptionTrack
+	Chapters        []Chapter // parsed from the timestamps in the description
 }
 
 const dateFormat = "2006-01-02"
@@ -115,6 +117,8 @@ func (v *Video) extractDataFromPlayerResponse(prData playerResponseData) error {
 		v.Duration = time.Duration(seconds) * time.Second
 	}
 
+	v.Chapters = parseChapters(v.Description, v.Duration)
+
 	if str := prData.Microformat.PlayerMicroformatRenderer.PublishDate; str != "" {
 		v.PublishDate, _ = time.Parse(dateFormat, str)
 	}
@@ -123,18 +127,20 @@ func (v *Video) extractDataFromPlayerResponse(prData playerResponseData) error {
 		v.ChannelHandle = profileURL.Path[1:]
 	}
 
+	v.HLSManifestURL = prData.StreamingData.HlsManifestURL
+	v.DASHManifestURL = prData.StreamingData.DashManifestURL
+	v.IsLive = prData.VideoDetails.IsLive && v.HLSManifestURL != ""
+
 	// Assign Streams
 	v.Formats = append(prData.StreamingData.Formats, prData.StreamingData.AdaptiveFormats...)
-	if len(v.Formats) == 0 {
+	// live streams may only have the manifests
+	if len(v.Formats) == 0 && !v.IsLive {
 		return errors.New("no formats found in the server's answer")
 	}
 
 	// Sort formats by bitrate
 	sort.SliceStable(v.Formats, v.SortBitrateDesc)
 
-	v.HLSManifestURL = prData.StreamingData.HlsManifestURL
-	v.DASHManifestURL = prData.StreamingData.DashManifestURL
-
 	return nil
 }
 
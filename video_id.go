@@ -1,6 +1,7 @@
 package youtube
 
 import (
+	"net/url"
 	"regexp"
 	"strings"
 )
@@ -11,9 +12,14 @@ var videoRegexpList = []*regexp.Regexp{
 	regexp.MustCompile(`([^"&?/=%]{11})`),
 }
 
+// videoPathPrefixes are the paths of URLs followed by the video ID, e.g. youtube.com/shorts/<id>
+var videoPathPrefixes = []string{"/shorts/", "/embed/", "/live/", "/v/", "/e/"}
+
 // ExtractVideoID extracts the videoID from the given string
 func ExtractVideoID(videoID string) (string, error) {
-	if strings.Contains(videoID, "youtu") || strings.ContainsAny(videoID, "\"?&/<%=") {
+	if id := videoIDFromURL(videoID); id != "" {
+		videoID = id
+	} else if strings.Contains(videoID, "youtu") || strings.ContainsAny(videoID, "\"?&/<%=") {
 		for _, re := range videoRegexpList {
 			if isMatch := re.MatchString(videoID); isMatch {
 				subs := re.FindStringSubmatch(videoID)
@@ -32,3 +38,37 @@ func ExtractVideoID(videoID string) (string, error) {
 
 	return videoID, nil
 }
+
+// videoIDFromURL returns the video ID of URLs like youtu.be/<id>, youtube.com/shorts/<id>
+// or youtube.com/watch?v=<id>&list=<playlist>&t=42, with or without scheme, or "" for other strings
+func videoIDFromURL(s string) string {
+	if !strings.Contains(s, "://") {
+		s = "https://" + s
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return ""
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+
+	switch {
+	case host == "youtu.be":
+		id, _, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+		return id
+	case host == "youtube.com", strings.HasSuffix(host, ".youtube.com"), host == "youtube-nocookie.com":
+		if id := u.Query().Get("v"); id != "" {
+			return id
+		}
+
+		for _, prefix := range videoPathPrefixes {
+			if rest, ok := strings.CutPrefix(u.Path, prefix); ok {
+				id, _, _ := strings.Cut(rest, "/")
+				return id
+			}
+		}
+	}
+
+	return ""
+}
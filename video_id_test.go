@@ -0,0 +1,42 @@
+package youtube
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractVideoID_urls(t *testing.T) {
+	tests := map[string]string{
+		"id":                "XbNghLqsVwU",
+		"watch":             "https://www.youtube.com/watch?v=XbNghLqsVwU",
+		"watch in playlist": "https://www.youtube.com/watch?v=XbNghLqsVwU&list=PLqQ1RwlxOgeLTJ1f3fNMSwhjVgaWKo_9Z&index=2",
+		"list first":        "https://www.youtube.com/watch?list=PLqQ1RwlxOgeLTJ1f3fNMSwhjVgaWKo_9Z&v=XbNghLqsVwU",
+		"timestamp":         "https://www.youtube.com/watch?v=XbNghLqsVwU&t=42s",
+		"mobile":            "https://m.youtube.com/watch?v=XbNghLqsVwU&feature=share",
+		"music":             "https://music.youtube.com/watch?v=XbNghLqsVwU",
+		"without scheme":    "youtube.com/watch?v=XbNghLqsVwU",
+		"short link":        "https://youtu.be/XbNghLqsVwU",
+		"short link time":   "https://youtu.be/XbNghLqsVwU?t=42",
+		"short link list":   "youtu.be/XbNghLqsVwU?list=PLqQ1RwlxOgeLTJ1f3fNMSwhjVgaWKo_9Z",
+		"shorts":            "https://www.youtube.com/shorts/XbNghLqsVwU",
+		"shorts shared":     "https://youtube.com/shorts/XbNghLqsVwU?feature=share",
+		"embed":             "https://www.youtube.com/embed/XbNghLqsVwU?start=10",
+		"embed no cookie":   "https://www.youtube-nocookie.com/embed/XbNghLqsVwU",
+		"live":              "https://www.youtube.com/live/XbNghLqsVwU?si=abc",
+	}
+
+	for name, url := range tests {
+		t.Run(name, func(t *testing.T) {
+			id, err := ExtractVideoID(url)
+			require.NoError(t, err)
+			assert.Equal(t, "XbNghLqsVwU", id)
+		})
+	}
+}
+
+func TestExtractVideoID_invalidURL(t *testing.T) {
+	_, err := ExtractVideoID("https://youtu.be/short")
+	assert.ErrorIs(t, err, ErrVideoIDMinLength)
+}
@@ -158,3 +158,27 @@ func TestDownload_SensitiveContent(t *testing.T) {
 	_, err := testClient.GetVideo("MS91knuzoOA")
 	require.EqualError(t, err, "can't bypass age restriction: embedding of this video has been disabled")
 }
+
+func TestVideo_parseVideoInfo_live(t *testing.T) {
+	body := `{
+		"playabilityStatus": {"status": "OK"},
+		"videoDetails": {"videoId": "jfKfPfyJRdk", "title": "lofi hip hop radio", "isLive": true, "isLiveContent": true},
+		"streamingData": {"hlsManifestUrl": "https://manifest.googlevideo.com/api/manifest/hls_variant/index.m3u8"}
+	}`
+
+	var video Video
+	require.NoError(t, video.parseVideoInfo([]byte(body)), "live streams may have no formats")
+	require.True(t, video.IsLive)
+	require.Equal(t, "https://manifest.googlevideo.com/api/manifest/hls_variant/index.m3u8", video.HLSManifestURL)
+
+	// a past live stream is a regular video
+	body = `{
+		"playabilityStatus": {"status": "OK"},
+		"videoDetails": {"videoId": "jfKfPfyJRdk", "isLiveContent": true},
+		"streamingData": {"hlsManifestUrl": "https://manifest.googlevideo.com/api/manifest/hls_variant/index.m3u8"}
+	}`
+
+	video = Video{}
+	require.EqualError(t, video.parseVideoInfo([]byte(body)), "no formats found in the server's answer")
+	require.False(t, video.IsLive)
+}